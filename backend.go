@@ -0,0 +1,66 @@
+package iscsidsc
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Backend performs the operations exposed by `Client`s.
+// The default backend makes calls to Windows' API; custom backends can be used e.g. for testing.
+type Backend interface {
+	ReportIScsiTargets(forceUpdate bool) ([]string, error)
+	LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *Portal,
+		securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret, isPersistent bool) (*SessionID, *ConnectionID, error)
+	LogoutIScsiTarget(sessionID SessionID) error
+
+	AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *LoginOptions, securityFlags *SecurityFlags, portal *Portal) error
+	ReportIScsiSendTargetPortals() ([]PortalInfo, error)
+	RemoveIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, portal *Portal) error
+
+	AddIScsiConnection(id SessionID, initiatorPortNumber *uint32, targetPortal *Portal,
+		securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret) (*ConnectionID, error)
+	GetDevicesForIScsiSession(id SessionID) ([]Device, error)
+	GetIScsiSessionList() ([]SessionInfo, error)
+}
+
+// BackendConfig is used to build the default backend.
+type BackendConfig struct {
+	// DLLPath is the name of, or path to, the DLL to load procs from; base names are
+	// looked up in the system directory.
+	DLLPath string
+	// ProcsPrefix is prepended to the name of each proc looked up in the DLL.
+	ProcsPrefix string
+	// InitialBufferSize is the size of the buffer used for the 1st call to APIs that need one.
+	InitialBufferSize uintptr
+}
+
+// BackendFactory builds the default backend.
+type BackendFactory func(config BackendConfig) Backend
+
+var (
+	defaultBackendFactory      BackendFactory
+	defaultBackendFactoryMutex sync.RWMutex
+
+	errNoBackend = errors.New("No backend available: either give one to the client with `WithBackend`, " +
+		"or import any of this library's sub-packages, which registers the default backend")
+)
+
+// RegisterDefaultBackendFactory registers the factory used to build the backend of clients
+// not given one with `WithBackend`.
+// It is called by this library's internal package, which implements the backend making calls to
+// Windows' API: this allows keeping that implementation out of this package, where it would
+// result in circular imports. There should be no need to call it otherwise.
+func RegisterDefaultBackendFactory(factory BackendFactory) {
+	defaultBackendFactoryMutex.Lock()
+	defer defaultBackendFactoryMutex.Unlock()
+
+	defaultBackendFactory = factory
+}
+
+func getDefaultBackendFactory() BackendFactory {
+	defaultBackendFactoryMutex.RLock()
+	defer defaultBackendFactoryMutex.RUnlock()
+
+	return defaultBackendFactory
+}
@@ -0,0 +1,453 @@
+package iscsidsc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Client performs operations on Windows' iSCSI initiator.
+// The package-level functions of this library's sub-packages all use the default client, see `DefaultClient`.
+type Client struct {
+	backend Backend
+	logger  Logger
+	hooks   Hooks
+}
+
+// Logger is used by clients to log the operations they perform; it's satisfied by `*log.Logger`.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Hooks are called around each operation a client performs.
+type Hooks struct {
+	// BeforeCall, if not nil, is called before each operation, with the operation's name,
+	// e.g. "LoginIscsiTarget".
+	BeforeCall func(operation string)
+	// AfterCall, if not nil, is called after each operation, with the operation's name,
+	// how long it took, and the error it returned if any.
+	AfterCall func(operation string, duration time.Duration, err error)
+}
+
+// The default values for clients' options.
+const (
+	DefaultDLLPath           = "iscsidsc.dll"
+	DefaultInitialBufferSize = 100000
+)
+
+type clientConfig struct {
+	backendConfig BackendConfig
+	backend       Backend
+	logger        Logger
+	hooks         Hooks
+}
+
+// ClientOption configures a `Client`, see `NewClient`.
+type ClientOption func(config *clientConfig)
+
+// WithDLLPath sets the name of, or path to, the DLL the default backend loads procs from.
+// Defaults to `DefaultDLLPath`.
+func WithDLLPath(path string) ClientOption {
+	return func(config *clientConfig) {
+		config.backendConfig.DLLPath = path
+	}
+}
+
+// WithProcsPrefix sets a prefix the default backend prepends to the name of each proc
+// it looks up in the DLL. Defaults to none.
+func WithProcsPrefix(prefix string) ClientOption {
+	return func(config *clientConfig) {
+		config.backendConfig.ProcsPrefix = prefix
+	}
+}
+
+// WithInitialBufferSize sets the size of the buffer the default backend uses for the 1st call to APIs
+// that need one. It should be big enough to ensure we won't need to make another call with a bigger buffer
+// in most situations; note however that on some versions of Windows, if this is too big, some API calls
+// might result in ERROR_NOACCESS errors (...?)
+// Defaults to `DefaultInitialBufferSize`.
+func WithInitialBufferSize(size uintptr) ClientOption {
+	return func(config *clientConfig) {
+		config.backendConfig.InitialBufferSize = size
+	}
+}
+
+// WithBackend makes the client use the given backend instead of the default one; the DLL path,
+// procs prefix and initial buffer size options are then ignored.
+func WithBackend(backend Backend) ClientOption {
+	return func(config *clientConfig) {
+		config.backend = backend
+	}
+}
+
+// WithLogger makes the client log each operation it performs to logger.
+func WithLogger(logger Logger) ClientOption {
+	return func(config *clientConfig) {
+		config.logger = logger
+	}
+}
+
+// WithHooks makes the client call the given hooks around each operation it performs.
+func WithHooks(hooks Hooks) ClientOption {
+	return func(config *clientConfig) {
+		config.hooks = hooks
+	}
+}
+
+// NewClient builds a new client.
+// Unless given a backend with `WithBackend`, the client uses the default backend, which makes calls to
+// Windows' API, and is registered when importing any of this library's sub-packages.
+func NewClient(opts ...ClientOption) *Client {
+	config := &clientConfig{
+		backendConfig: BackendConfig{
+			DLLPath:           DefaultDLLPath,
+			InitialBufferSize: DefaultInitialBufferSize,
+		},
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	backend := config.backend
+	if backend == nil {
+		if factory := getDefaultBackendFactory(); factory != nil {
+			backend = factory(config.backendConfig)
+		}
+	}
+
+	return &Client{
+		backend: backend,
+		logger:  config.logger,
+		hooks:   config.hooks,
+	}
+}
+
+var (
+	defaultClient      *Client
+	defaultClientMutex sync.Mutex
+)
+
+// DefaultClient returns the client used by the package-level functions of this library's sub-packages.
+// Unless replaced with `SetDefaultClient`, it is built with `NewClient`'s default options.
+func DefaultClient() *Client {
+	defaultClientMutex.Lock()
+	defer defaultClientMutex.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = NewClient()
+	}
+	return defaultClient
+}
+
+// SetDefaultClient replaces the default client, and returns the previous one.
+// Passing nil resets the default client to one built with `NewClient`'s default options.
+func SetDefaultClient(client *Client) *Client {
+	defaultClientMutex.Lock()
+	defer defaultClientMutex.Unlock()
+
+	previous := defaultClient
+	defaultClient = client
+	return previous
+}
+
+// ReportIScsiTargets retrieves the list of targets that the iSCSI initiator service has discovered.
+// if forceUpdate is true,  the iSCSI initiator service updates the list of discovered targets before
+// returning the target list data to the caller.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-reportiscsitargetsw
+func (c *Client) ReportIScsiTargets(forceUpdate bool) (targets []string, err error) {
+	err = c.call("ReportIScsiTargets", func(backend Backend) (err error) {
+		targets, err = backend.ReportIScsiTargets(forceUpdate)
+		return
+	})
+	return
+}
+
+// LoginIscsiTarget establishes a full featured login session with the indicated target.
+// All pointer arguments are optional.
+// TODO: we don't support passing custom mappings yet.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-loginiscsitargetw
+func (c *Client) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *Portal,
+	securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret, isPersistent bool) (sessionID *SessionID, connectionID *ConnectionID, err error) {
+	return c.Login(&LoginRequest{
+		TargetName:             targetName,
+		IsInformationalSession: isInformationalSession,
+		InitiatorInstance:      initiatorInstance,
+		InitiatorPortNumber:    initiatorPortNumber,
+		TargetPortal:           targetPortal,
+		SecurityFlags:          securityFlags,
+		LoginOptions:           loginOptions,
+		Key:                    key,
+		IsPersistent:           isPersistent,
+	})
+}
+
+// Login is the same as `LoginIscsiTarget`, with its arguments gathered in a request.
+// The request is validated before calling the backend.
+func (c *Client) Login(request *LoginRequest) (sessionID *SessionID, connectionID *ConnectionID, err error) {
+	err = c.call("LoginIscsiTarget", func(backend Backend) (err error) {
+		if err = request.Validate(); err != nil {
+			return errors.Wrap(err, "invalid login request")
+		}
+		sessionID, connectionID, err = backend.LoginIscsiTarget(request.TargetName, request.IsInformationalSession, request.InitiatorInstance,
+			request.InitiatorPortNumber, request.TargetPortal, request.SecurityFlags, request.LoginOptions, request.Key, request.IsPersistent)
+		return
+	})
+	return
+}
+
+// LogoutIScsiTarget closes the specified login session.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-logoutiscsitarget
+func (c *Client) LogoutIScsiTarget(sessionID SessionID) error {
+	return c.call("LogoutIScsiTarget", func(backend Backend) error {
+		return backend.LogoutIScsiTarget(sessionID)
+	})
+}
+
+// AddIScsiSendTargetPortal adds a static target portal to the list of target portals to which the iSCSI initiator service transmits SendTargets requests.
+// Only the `portal` is a required argument - all others can be left `nil`.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-addiscsisendtargetportalw
+func (c *Client) AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *LoginOptions, securityFlags *SecurityFlags, portal *Portal) error {
+	return c.AddPortal(&AddPortalRequest{
+		InitiatorInstance:   initiatorInstance,
+		InitiatorPortNumber: initiatorPortNumber,
+		LoginOptions:        loginOptions,
+		SecurityFlags:       securityFlags,
+		Portal:              portal,
+	})
+}
+
+// AddPortal is the same as `AddIScsiSendTargetPortal`, with its arguments gathered in a request.
+// The request is validated before calling the backend.
+func (c *Client) AddPortal(request *AddPortalRequest) error {
+	return c.call("AddIScsiSendTargetPortal", func(backend Backend) error {
+		if err := request.Validate(); err != nil {
+			return errors.Wrap(err, "invalid add portal request")
+		}
+		return backend.AddIScsiSendTargetPortal(request.InitiatorInstance, request.InitiatorPortNumber, request.LoginOptions, request.SecurityFlags, request.Portal)
+	})
+}
+
+// ReportIScsiSendTargetPortals retrieves a list of static target portals that the iSCSI initiator
+// service uses to perform automatic discovery with SendTarget requests.
+// Windows keeps portal addresses as they were given, so the list can contain several registrations of
+// the same portal as per `Portal.Equal`; see `DedupePortalInfos`.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-reportiscsisendtargetportalsexw
+func (c *Client) ReportIScsiSendTargetPortals() (portalInfos []PortalInfo, err error) {
+	err = c.call("ReportIScsiSendTargetPortals", func(backend Backend) (err error) {
+		portalInfos, err = backend.ReportIScsiSendTargetPortals()
+		return
+	})
+	return
+}
+
+// RemoveIScsiSendTargetPortal removes a portal from the list of portals to which the iSCSI initiator service sends
+// SendTargets requests for target discovery.
+// Only portal is a required argument.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-removeiscsisendtargetportalw
+func (c *Client) RemoveIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, portal *Portal) error {
+	return c.call("RemoveIScsiSendTargetPortal", func(backend Backend) error {
+		return backend.RemoveIScsiSendTargetPortal(initiatorInstance, initiatorPortNumber, portal)
+	})
+}
+
+// AddIScsiConnection adds a new iSCSI connection to an existing session.
+// Only the session ID and the targetPortal are required.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-addiscsiconnectionw
+func (c *Client) AddIScsiConnection(id SessionID, initiatorPortNumber *uint32, targetPortal *Portal,
+	securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret) (connectionID *ConnectionID, err error) {
+	return c.AddConnection(&AddConnectionRequest{
+		SessionID:           id,
+		InitiatorPortNumber: initiatorPortNumber,
+		TargetPortal:        targetPortal,
+		SecurityFlags:       securityFlags,
+		LoginOptions:        loginOptions,
+		Key:                 key,
+	})
+}
+
+// AddConnection is the same as `AddIScsiConnection`, with its arguments gathered in a request.
+// The request is validated before calling the backend.
+func (c *Client) AddConnection(request *AddConnectionRequest) (connectionID *ConnectionID, err error) {
+	err = c.call("AddIScsiConnection", func(backend Backend) (err error) {
+		if err = request.Validate(); err != nil {
+			return errors.Wrap(err, "invalid add connection request")
+		}
+		connectionID, err = backend.AddIScsiConnection(request.SessionID, request.InitiatorPortNumber, request.TargetPortal,
+			request.SecurityFlags, request.LoginOptions, request.Key)
+		return
+	})
+	return
+}
+
+// GetDevicesForIScsiSession retrieves information about the devices associated with an existing session.
+// see https://docs.microsoft.com/en-us/windows/win32/api/iscsidsc/nf-iscsidsc-getdevicesforiscsisessionw
+func (c *Client) GetDevicesForIScsiSession(id SessionID) (devices []Device, err error) {
+	err = c.call("GetDevicesForIScsiSession", func(backend Backend) (err error) {
+		devices, err = backend.GetDevicesForIScsiSession(id)
+		return
+	})
+	return
+}
+
+// GetIScsiSessionList retrieves the list of active iSCSI sessions.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/nf-iscsidsc-getiscsisessionlistw
+func (c *Client) GetIScsiSessionList() (sessions []SessionInfo, err error) {
+	err = c.call("GetIScsiSessionList", func(backend Backend) (err error) {
+		sessions, err = backend.GetIScsiSessionList()
+		return
+	})
+	return
+}
+
+// call performs an operation, calling hooks and logging around it.
+func (c *Client) call(operation string, f func(backend Backend) error) error {
+	if c.hooks.BeforeCall != nil {
+		c.hooks.BeforeCall(operation)
+	}
+	c.logf("Calling %s", operation)
+
+	start := time.Now()
+	err := errNoBackend
+	if c.backend != nil {
+		err = f(c.backend)
+	}
+	duration := time.Since(start)
+
+	if err == nil {
+		c.logf("%s succeeded in %v", operation, duration)
+	} else {
+		c.logf("%s failed after %v: %v", operation, duration, err)
+	}
+	if c.hooks.AfterCall != nil {
+		c.hooks.AfterCall(operation, duration, err)
+	}
+
+	return err
+}
+
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+// ReportIScsiTargetsContext is the same as `ReportIScsiTargets`, but it stops waiting when ctx is done.
+func (c *Client) ReportIScsiTargetsContext(ctx context.Context, forceUpdate bool) ([]string, error) {
+	var targets []string
+	err := callWithContext(ctx, func() (completion *Completion) {
+		completion = &Completion{Operation: "ReportIScsiTargets"}
+		targets, completion.Err = c.ReportIScsiTargets(forceUpdate)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// LoginIscsiTargetContext is the same as `LoginIscsiTarget`, but it stops waiting when ctx is done.
+// Sessions created after that are reported to ctx's orphan handler.
+func (c *Client) LoginIscsiTargetContext(ctx context.Context, targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *Portal,
+	securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret, isPersistent bool) (*SessionID, *ConnectionID, error) {
+
+	var (
+		sessionID    *SessionID
+		connectionID *ConnectionID
+	)
+	err := callWithContext(ctx, func() (completion *Completion) {
+		completion = &Completion{Operation: "LoginIscsiTarget"}
+		sessionID, connectionID, completion.Err = c.LoginIscsiTarget(targetName, isInformationalSession, initiatorInstance, initiatorPortNumber, targetPortal,
+			securityFlags, loginOptions, key, isPersistent)
+		completion.SessionID, completion.ConnectionID = sessionID, connectionID
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sessionID, connectionID, nil
+}
+
+// LogoutIScsiTargetContext is the same as `LogoutIScsiTarget`, but it stops waiting when ctx is done.
+func (c *Client) LogoutIScsiTargetContext(ctx context.Context, sessionID SessionID) error {
+	return callWithContext(ctx, func() *Completion {
+		return &Completion{Operation: "LogoutIScsiTarget", SessionID: &sessionID, Err: c.LogoutIScsiTarget(sessionID)}
+	})
+}
+
+// AddIScsiSendTargetPortalContext is the same as `AddIScsiSendTargetPortal`, but it stops waiting when ctx is done.
+func (c *Client) AddIScsiSendTargetPortalContext(ctx context.Context, initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *LoginOptions, securityFlags *SecurityFlags, portal *Portal) error {
+	return callWithContext(ctx, func() *Completion {
+		err := c.AddIScsiSendTargetPortal(initiatorInstance, initiatorPortNumber, loginOptions, securityFlags, portal)
+		return &Completion{Operation: "AddIScsiSendTargetPortal", Err: err}
+	})
+}
+
+// ReportIScsiSendTargetPortalsContext is the same as `ReportIScsiSendTargetPortals`, but it stops waiting when ctx is done.
+func (c *Client) ReportIScsiSendTargetPortalsContext(ctx context.Context) ([]PortalInfo, error) {
+	var portalInfos []PortalInfo
+	err := callWithContext(ctx, func() (completion *Completion) {
+		completion = &Completion{Operation: "ReportIScsiSendTargetPortals"}
+		portalInfos, completion.Err = c.ReportIScsiSendTargetPortals()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return portalInfos, nil
+}
+
+// RemoveIScsiSendTargetPortalContext is the same as `RemoveIScsiSendTargetPortal`, but it stops waiting when ctx is done.
+func (c *Client) RemoveIScsiSendTargetPortalContext(ctx context.Context, initiatorInstance *string, initiatorPortNumber *uint32, portal *Portal) error {
+	return callWithContext(ctx, func() *Completion {
+		err := c.RemoveIScsiSendTargetPortal(initiatorInstance, initiatorPortNumber, portal)
+		return &Completion{Operation: "RemoveIScsiSendTargetPortal", Err: err}
+	})
+}
+
+// AddIScsiConnectionContext is the same as `AddIScsiConnection`, but it stops waiting when ctx is done.
+// Connections created after that are reported to ctx's orphan handler.
+func (c *Client) AddIScsiConnectionContext(ctx context.Context, id SessionID, initiatorPortNumber *uint32, targetPortal *Portal,
+	securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret) (*ConnectionID, error) {
+
+	var connectionID *ConnectionID
+	err := callWithContext(ctx, func() (completion *Completion) {
+		completion = &Completion{Operation: "AddIScsiConnection", SessionID: &id}
+		connectionID, completion.Err = c.AddIScsiConnection(id, initiatorPortNumber, targetPortal, securityFlags, loginOptions, key)
+		completion.ConnectionID = connectionID
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connectionID, nil
+}
+
+// GetDevicesForIScsiSessionContext is the same as `GetDevicesForIScsiSession`, but it stops waiting when ctx is done.
+func (c *Client) GetDevicesForIScsiSessionContext(ctx context.Context, id SessionID) ([]Device, error) {
+	var devices []Device
+	err := callWithContext(ctx, func() (completion *Completion) {
+		completion = &Completion{Operation: "GetDevicesForIScsiSession", SessionID: &id}
+		devices, completion.Err = c.GetDevicesForIScsiSession(id)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// GetIScsiSessionListContext is the same as `GetIScsiSessionList`, but it stops waiting when ctx is done.
+func (c *Client) GetIScsiSessionListContext(ctx context.Context) ([]SessionInfo, error) {
+	var sessions []SessionInfo
+	err := callWithContext(ctx, func() (completion *Completion) {
+		completion = &Completion{Operation: "GetIScsiSessionList"}
+		sessions, completion.Err = c.GetIScsiSessionList()
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
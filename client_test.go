@@ -0,0 +1,159 @@
+package iscsidsc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend only implements the operations the tests below need.
+type fakeBackend struct {
+	Backend
+
+	sessions []SessionInfo
+	err      error
+	calls    int
+	unblock  chan struct{}
+}
+
+func (b *fakeBackend) GetIScsiSessionList() ([]SessionInfo, error) {
+	b.calls++
+	if b.unblock != nil {
+		<-b.unblock
+	}
+	return b.sessions, b.err
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestClient(t *testing.T) {
+	sessions := []SessionInfo{{TargetName: "iqn.1991-05.com.microsoft:target-1"}}
+
+	t.Run("it calls the backend, and the hooks and logger around it", func(t *testing.T) {
+		backend := &fakeBackend{sessions: sessions}
+		logger := &recordingLogger{}
+		var before []string
+		var after []string
+		client := NewClient(WithBackend(backend), WithLogger(logger), WithHooks(Hooks{
+			BeforeCall: func(operation string) {
+				before = append(before, operation)
+			},
+			AfterCall: func(operation string, duration time.Duration, err error) {
+				after = append(after, fmt.Sprintf("%s %v", operation, err))
+			},
+		}))
+
+		result, err := client.GetIScsiSessionList()
+
+		require.Nil(t, err)
+		assert.Equal(t, sessions, result)
+		assert.Equal(t, 1, backend.calls)
+		assert.Equal(t, []string{"GetIScsiSessionList"}, before)
+		assert.Equal(t, []string{"GetIScsiSessionList <nil>"}, after)
+		if assert.Equal(t, 2, len(logger.lines)) {
+			assert.Equal(t, "Calling GetIScsiSessionList", logger.lines[0])
+			assert.Contains(t, logger.lines[1], "GetIScsiSessionList succeeded in ")
+		}
+	})
+
+	t.Run("it reports errors to the hooks and logger", func(t *testing.T) {
+		backendErr := errors.New("dummy error")
+		logger := &recordingLogger{}
+		var hookErr error
+		client := NewClient(WithBackend(&fakeBackend{err: backendErr}), WithLogger(logger), WithHooks(Hooks{
+			AfterCall: func(operation string, duration time.Duration, err error) {
+				hookErr = err
+			},
+		}))
+
+		_, err := client.GetIScsiSessionList()
+
+		assert.Equal(t, backendErr, err)
+		assert.Equal(t, backendErr, hookErr)
+		if assert.Equal(t, 2, len(logger.lines)) {
+			assert.Contains(t, logger.lines[1], "GetIScsiSessionList failed after ")
+			assert.Contains(t, logger.lines[1], ": dummy error")
+		}
+	})
+
+	t.Run("without any backend, it errors out", func(t *testing.T) {
+		// this package's tests can't import the internal package, so no default backend is registered
+		_, err := NewClient().GetIScsiSessionList()
+
+		assert.Equal(t, errNoBackend, err)
+	})
+
+	t.Run("it builds the default backend with the right config", func(t *testing.T) {
+		var config BackendConfig
+		RegisterDefaultBackendFactory(func(c BackendConfig) Backend {
+			config = c
+			return &fakeBackend{sessions: sessions}
+		})
+		defer RegisterDefaultBackendFactory(nil)
+
+		result, err := NewClient(WithDLLPath(`C:\iscsidsc.dll`), WithProcsPrefix("Mock")).GetIScsiSessionList()
+
+		require.Nil(t, err)
+		assert.Equal(t, sessions, result)
+		assert.Equal(t, BackendConfig{DLLPath: `C:\iscsidsc.dll`, ProcsPrefix: "Mock", InitialBufferSize: DefaultInitialBufferSize}, config)
+
+		NewClient(WithInitialBufferSize(1))
+		assert.Equal(t, BackendConfig{DLLPath: DefaultDLLPath, InitialBufferSize: 1}, config)
+	})
+
+	t.Run("context-aware methods report orphaned completions", func(t *testing.T) {
+		backend := &fakeBackend{sessions: sessions, unblock: make(chan struct{})}
+		client := NewClient(WithBackend(backend))
+		orphans := make(chan *Completion, 1)
+		ctx, cancel := context.WithCancel(WithOrphanHandler(context.Background(), func(completion *Completion) {
+			orphans <- completion
+		}))
+		cancel()
+
+		// the context is already done, the call doesn't even get made
+		_, err := client.GetIScsiSessionListContext(ctx)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 0, backend.calls)
+
+		ctx, cancel = context.WithTimeout(WithOrphanHandler(context.Background(), func(completion *Completion) {
+			orphans <- completion
+		}), 10*time.Millisecond)
+		defer cancel()
+
+		_, err = client.GetIScsiSessionListContext(ctx)
+		assert.Equal(t, context.DeadlineExceeded, err)
+
+		close(backend.unblock)
+		select {
+		case orphan := <-orphans:
+			assert.Equal(t, "GetIScsiSessionList", orphan.Operation)
+			assert.Nil(t, orphan.Err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the orphaned completion")
+		}
+	})
+}
+
+func TestDefaultClient(t *testing.T) {
+	client := NewClient(WithBackend(&fakeBackend{}))
+
+	previous := SetDefaultClient(client)
+	defer SetDefaultClient(previous)
+
+	assert.True(t, client == DefaultClient())
+
+	SetDefaultClient(nil)
+	assert.NotNil(t, DefaultClient())
+	assert.False(t, client == DefaultClient())
+}
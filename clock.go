@@ -0,0 +1,28 @@
+package iscsidsc
+
+import (
+	"time"
+)
+
+// Clock tells the time, and waits. It's used by the parts of this library that poll, or wait between
+// retries, so that tests can control time; the default clock everywhere is `SystemClock`.
+type Clock interface {
+	Now() time.Time
+	// After is the same as `time.After`.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the system's clock.
+type SystemClock struct{}
+
+var _ Clock = SystemClock{}
+
+// Now implements `Clock`, and is the same as `time.Now`.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements `Clock`, and is the same as `time.After`.
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,95 @@
+package iscsidsc
+
+import (
+	"context"
+	"sync"
+)
+
+// Completion describes the outcome of a call to Windows' API made by one of the `...Context`
+// functions of this library.
+type Completion struct {
+	// Operation is the name of the function that made the call, e.g. "LoginIscsiTarget".
+	Operation string
+	// SessionID is set when the call created a session, i.e. for successful logins.
+	SessionID *SessionID
+	// ConnectionID is set when the call created a connection, i.e. for successful logins
+	// and added connections.
+	ConnectionID *ConnectionID
+	// Err is the error the call returned, if any.
+	Err error
+}
+
+// OrphanHandler is called with the completions of calls that finished after their context was done,
+// and whose outcome the caller hence never saw; e.g. a session created after the caller gave up
+// waiting for a login, that might need to be logged out of.
+// It is called on a background goroutine.
+type OrphanHandler func(completion *Completion)
+
+type orphanHandlerKey struct{}
+
+// WithOrphanHandler returns a copy of ctx that will make `...Context` functions report orphaned
+// completions to handler.
+func WithOrphanHandler(ctx context.Context, handler OrphanHandler) context.Context {
+	return context.WithValue(ctx, orphanHandlerKey{}, handler)
+}
+
+// OrphanHandlerFromContext returns the orphan handler set on ctx with `WithOrphanHandler`, or nil
+// if there is none.
+func OrphanHandlerFromContext(ctx context.Context) OrphanHandler {
+	handler, _ := ctx.Value(orphanHandlerKey{}).(OrphanHandler)
+	return handler
+}
+
+// callWithContext runs call on a background goroutine, and waits until either it completes or ctx
+// is done. In the latter case, it returns ctx's error straight away; and once call does complete,
+// its completion is passed to ctx's orphan handler, if any.
+// Since Windows' API calls can't be interrupted, call keeps running in the background until it returns.
+// Results other than those in the completion should be captured by call, and must only be read once
+// callWithContext has returned a nil error.
+func callWithContext(ctx context.Context, call func() *Completion) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var (
+		// guards abandoned, and ensures that a completion is either returned or orphaned, never both
+		mutex     sync.Mutex
+		abandoned bool
+		// buffered, so that the background goroutine never blocks
+		completions = make(chan *Completion, 1)
+	)
+
+	go func() {
+		completion := call()
+
+		mutex.Lock()
+		orphaned := abandoned
+		if !orphaned {
+			completions <- completion
+		}
+		mutex.Unlock()
+
+		if orphaned {
+			if handler := OrphanHandlerFromContext(ctx); handler != nil {
+				handler(completion)
+			}
+		}
+	}()
+
+	select {
+	case completion := <-completions:
+		return completion.Err
+	case <-ctx.Done():
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// the call might have completed at the same time
+		select {
+		case completion := <-completions:
+			return completion.Err
+		default:
+			abandoned = true
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package iscsidsc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallWithContext(t *testing.T) {
+	t.Run("when the call completes first, it returns its error", func(t *testing.T) {
+		expectedErr := errors.New("dummy error")
+
+		err := callWithContext(context.Background(), func() *Completion {
+			return &Completion{Err: expectedErr}
+		})
+
+		assert.Equal(t, expectedErr, err)
+	})
+
+	t.Run("when the context is already done, it doesn't make the call", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := callWithContext(ctx, func() *Completion {
+			t.Fatal("should not be called")
+			return nil
+		})
+
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("when the context times out first, it returns straight away, and reports the orphaned completion", func(t *testing.T) {
+		orphans := make(chan *Completion, 1)
+		ctx := WithOrphanHandler(context.Background(), func(completion *Completion) {
+			orphans <- completion
+		})
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		unblock := make(chan struct{})
+		sessionID := &SessionID{AdapterUnique: 1, AdapterSpecific: 2}
+
+		err := callWithContext(ctx, func() *Completion {
+			<-unblock
+			return &Completion{Operation: "LoginIscsiTarget", SessionID: sessionID}
+		})
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+		select {
+		case <-orphans:
+			t.Fatal("the call is not done yet")
+		default:
+		}
+
+		close(unblock)
+		select {
+		case orphan := <-orphans:
+			assert.Equal(t, "LoginIscsiTarget", orphan.Operation)
+			assert.Equal(t, sessionID, orphan.SessionID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the orphaned completion")
+		}
+	})
+}
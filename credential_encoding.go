@@ -0,0 +1,200 @@
+package iscsidsc
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// CredentialEncoding defines how a `Secret`'s value is encoded into the bytes passed to Windows' API,
+// and conversely how bytes returned by Windows' API are decoded into a secret.
+// Windows treats CHAP credentials and IPsec keys as opaque byte strings with explicit lengths.
+type CredentialEncoding uint8
+
+// The various credential encodings available.
+const (
+	// CredentialEncodingUTF8 passes the value's bytes as is; they must be valid UTF-8, and contain no null character.
+	// It's the default encoding.
+	CredentialEncodingUTF8 CredentialEncoding = iota
+	// CredentialEncodingLatin1 encodes each character of the (UTF-8) value as a single byte; all characters must
+	// be in the Latin-1 range (U+0001 to U+00FF).
+	CredentialEncodingLatin1
+	// CredentialEncodingRaw passes the value's bytes as is, without any check; this allows binary secrets.
+	CredentialEncodingRaw
+	// CredentialEncodingHex decodes the value as a hex string prefixed with "0x", the same way `iscsicli` does.
+	CredentialEncodingHex
+)
+
+var credentialEncodingNames = map[CredentialEncoding]string{
+	CredentialEncodingUTF8:   "utf-8",
+	CredentialEncodingLatin1: "latin-1",
+	CredentialEncodingRaw:    "raw",
+	CredentialEncodingHex:    "hex",
+}
+
+func (encoding CredentialEncoding) String() string {
+	if name, present := credentialEncodingNames[encoding]; present {
+		return name
+	}
+	return fmt.Sprintf("CredentialEncoding(%d)", encoding)
+}
+
+// hexPrefix is what hex-encoded secrets must start with.
+const hexPrefix = "0x"
+
+// NewEncodedSecret builds a new secret with a copy of value, and the given encoding.
+// It errors out if value isn't valid for that encoding.
+func NewEncodedSecret(value []byte, encoding CredentialEncoding) (*Secret, error) {
+	secret := NewSecret(value)
+	if err := secret.SetEncoding(encoding); err != nil {
+		secret.Wipe()
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Encoding returns the secret's encoding.
+func (s *Secret) Encoding() CredentialEncoding {
+	if s == nil {
+		return CredentialEncodingUTF8
+	}
+	return s.encoding
+}
+
+// SetEncoding changes the secret's encoding, after checking that its value is valid for that encoding.
+func (s *Secret) SetEncoding(encoding CredentialEncoding) error {
+	previous := s.encoding
+	s.encoding = encoding
+
+	encoded, err := s.Encode()
+	wipeBytes(encoded)
+	if err != nil {
+		s.encoding = previous
+	}
+	return err
+}
+
+// Encode returns a new slice containing the secret's value encoded according to its encoding, i.e. the
+// bytes to pass to Windows' API. Callers should wipe the result once they're done with it.
+// Note that errors never contain any part of the secret.
+func (s *Secret) Encode() ([]byte, error) {
+	value := s.Bytes()
+
+	switch s.Encoding() {
+	case CredentialEncodingUTF8:
+		if bytes.IndexByte(value, 0) != -1 {
+			return nil, errors.New("UTF-8 secret contains a null character")
+		}
+		if !utf8.Valid(value) {
+			return nil, errors.New("UTF-8 secret is not valid UTF-8")
+		}
+		return copyBytes(value), nil
+
+	case CredentialEncodingLatin1:
+		encoded := make([]byte, 0, len(value))
+		for len(value) != 0 {
+			r, size := utf8.DecodeRune(value)
+			if r == utf8.RuneError && size <= 1 {
+				wipeBytes(encoded)
+				return nil, errors.New("Latin-1 secret is not valid UTF-8")
+			}
+			if r == 0 || r > 0xFF {
+				wipeBytes(encoded)
+				return nil, errors.New("Latin-1 secret contains characters outside of the Latin-1 range")
+			}
+			encoded = append(encoded, byte(r))
+			value = value[size:]
+		}
+		return encoded, nil
+
+	case CredentialEncodingRaw:
+		return copyBytes(value), nil
+
+	case CredentialEncodingHex:
+		if !bytes.HasPrefix(value, []byte(hexPrefix)) && !bytes.HasPrefix(value, []byte("0X")) {
+			return nil, errors.Errorf("hex secret must start with %q", hexPrefix)
+		}
+		digits := value[len(hexPrefix):]
+		encoded := make([]byte, hex.DecodedLen(len(digits)))
+		if _, err := hex.Decode(encoded, digits); err != nil {
+			wipeBytes(encoded)
+			// hex errors can contain the offending byte, don't leak it
+			return nil, errors.New("hex secret is not valid hex")
+		}
+		return encoded, nil
+
+	default:
+		return nil, errors.Errorf("unknown credential encoding: %v", s.Encoding())
+	}
+}
+
+// Equal returns true iff both secrets encode to the same bytes, e.g. a Latin-1 secret and a raw one
+// holding its encoded value; comparisons are made in constant time. Secrets that can't be encoded are
+// only equal to themselves, and nil secrets only to each other.
+func (s *Secret) Equal(other *Secret) bool {
+	if s == nil || other == nil || s == other {
+		return s == other
+	}
+
+	encoded, err := s.Encode()
+	defer wipeBytes(encoded)
+	if err != nil {
+		return false
+	}
+	otherEncoded, err := other.Encode()
+	defer wipeBytes(otherEncoded)
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(encoded, otherEncoded) == 1
+}
+
+// DecodeSecret builds a new secret from encoded bytes as returned by Windows' API, such that
+// encoding the result with `Encode` yields the same bytes.
+func DecodeSecret(encoded []byte, encoding CredentialEncoding) (*Secret, error) {
+	var value []byte
+
+	switch encoding {
+	case CredentialEncodingUTF8, CredentialEncodingRaw:
+		value = copyBytes(encoded)
+
+	case CredentialEncodingLatin1:
+		value = make([]byte, 0, len(encoded))
+		for _, b := range encoded {
+			value = append(value, string(rune(b))...)
+		}
+
+	case CredentialEncodingHex:
+		value = make([]byte, len(hexPrefix)+hex.EncodedLen(len(encoded)))
+		copy(value, hexPrefix)
+		hex.Encode(value[len(hexPrefix):], encoded)
+
+	default:
+		return nil, errors.Errorf("unknown credential encoding: %v", encoding)
+	}
+
+	secret := &Secret{value: value, encoding: encoding}
+	// makes sure the value is valid for that encoding, e.g. no null characters for UTF-8
+	if err := secret.SetEncoding(encoding); err != nil {
+		secret.Wipe()
+		return nil, err
+	}
+	return secret, nil
+}
+
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
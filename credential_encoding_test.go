@@ -0,0 +1,188 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretEncoding(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		value           string
+		encoding        CredentialEncoding
+		expectedEncoded []byte
+		expectedError   string
+	}{
+		{
+			name:            "UTF-8",
+			value:           "café",
+			encoding:        CredentialEncodingUTF8,
+			expectedEncoded: []byte("caf\xc3\xa9"),
+		},
+		{
+			name:          "UTF-8 with a null character",
+			value:         "caf\x00é",
+			encoding:      CredentialEncodingUTF8,
+			expectedError: "UTF-8 secret contains a null character",
+		},
+		{
+			name:          "invalid UTF-8",
+			value:         "caf\xe9",
+			encoding:      CredentialEncodingUTF8,
+			expectedError: "UTF-8 secret is not valid UTF-8",
+		},
+		{
+			name:            "Latin-1",
+			value:           "café",
+			encoding:        CredentialEncodingLatin1,
+			expectedEncoded: []byte("caf\xe9"),
+		},
+		{
+			name:          "Latin-1 with characters out of range",
+			value:         "café€",
+			encoding:      CredentialEncodingLatin1,
+			expectedError: "Latin-1 secret contains characters outside of the Latin-1 range",
+		},
+		{
+			name:          "Latin-1 with invalid UTF-8",
+			value:         "caf\xe9",
+			encoding:      CredentialEncodingLatin1,
+			expectedError: "Latin-1 secret is not valid UTF-8",
+		},
+		{
+			name:            "raw",
+			value:           "\x00\xff\x10caf\xe9",
+			encoding:        CredentialEncodingRaw,
+			expectedEncoded: []byte("\x00\xff\x10caf\xe9"),
+		},
+		{
+			name:            "hex",
+			value:           "0x00fF10636166",
+			encoding:        CredentialEncodingHex,
+			expectedEncoded: []byte("\x00\xff\x10caf"),
+		},
+		{
+			name:            "hex with an upper case prefix",
+			value:           "0XABCD",
+			encoding:        CredentialEncodingHex,
+			expectedEncoded: []byte("\xab\xcd"),
+		},
+		{
+			name:            "empty hex",
+			value:           "0x",
+			encoding:        CredentialEncodingHex,
+			expectedEncoded: []byte{},
+		},
+		{
+			name:          "hex without a prefix",
+			value:         "abcd",
+			encoding:      CredentialEncodingHex,
+			expectedError: `hex secret must start with "0x"`,
+		},
+		{
+			name:          "invalid hex",
+			value:         "0xsecret",
+			encoding:      CredentialEncodingHex,
+			expectedError: "hex secret is not valid hex",
+		},
+		{
+			name:          "odd hex",
+			value:         "0xabc",
+			encoding:      CredentialEncodingHex,
+			expectedError: "hex secret is not valid hex",
+		},
+		{
+			name:          "unknown encoding",
+			value:         "secret",
+			encoding:      CredentialEncoding(12),
+			expectedError: "unknown credential encoding: CredentialEncoding(12)",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			secret, err := NewEncodedSecret([]byte(testCase.value), testCase.encoding)
+
+			if testCase.expectedError != "" {
+				assert.Nil(t, secret)
+				if assert.NotNil(t, err) {
+					assert.Equal(t, testCase.expectedError, err.Error())
+				}
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, testCase.encoding, secret.Encoding())
+
+			encoded, err := secret.Encode()
+			require.Nil(t, err)
+			assert.Equal(t, testCase.expectedEncoded, encoded)
+
+			t.Run("and back", func(t *testing.T) {
+				decoded, err := DecodeSecret(encoded, testCase.encoding)
+				require.Nil(t, err)
+				assert.Equal(t, testCase.encoding, decoded.Encoding())
+
+				reEncoded, err := decoded.Encode()
+				require.Nil(t, err)
+				assert.Equal(t, encoded, reEncoded)
+			})
+		})
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	t.Run("Latin-1", func(t *testing.T) {
+		secret, err := DecodeSecret([]byte("caf\xe9"), CredentialEncodingLatin1)
+		require.Nil(t, err)
+		assert.Equal(t, "café", string(secret.Bytes()))
+	})
+
+	t.Run("hex", func(t *testing.T) {
+		secret, err := DecodeSecret([]byte("\x00\xff"), CredentialEncodingHex)
+		require.Nil(t, err)
+		assert.Equal(t, "0x00ff", string(secret.Bytes()))
+	})
+
+	t.Run("bytes that aren't valid for the encoding", func(t *testing.T) {
+		secret, err := DecodeSecret([]byte("caf\xe9"), CredentialEncodingUTF8)
+		assert.Nil(t, secret)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "UTF-8 secret is not valid UTF-8", err.Error())
+		}
+	})
+}
+
+func TestSetEncoding(t *testing.T) {
+	secret := NewSecretFromString("0xsecret")
+	assert.Equal(t, CredentialEncodingUTF8, secret.Encoding())
+
+	assert.NotNil(t, secret.SetEncoding(CredentialEncodingHex))
+	assert.Equal(t, CredentialEncodingUTF8, secret.Encoding(), "should have kept the previous encoding")
+
+	assert.Nil(t, secret.SetEncoding(CredentialEncodingRaw))
+	assert.Equal(t, CredentialEncodingRaw, secret.Encoding())
+	assert.Equal(t, "raw", secret.Encoding().String())
+}
+
+func TestSecretEqual(t *testing.T) {
+	latin1, err := NewEncodedSecret([]byte("café"), CredentialEncodingLatin1)
+	require.NoError(t, err)
+	raw, err := NewEncodedSecret([]byte("caf\xe9"), CredentialEncodingRaw)
+	require.NoError(t, err)
+	hex, err := NewEncodedSecret([]byte("0x636166e9"), CredentialEncodingHex)
+	require.NoError(t, err)
+
+	assert.True(t, latin1.Equal(raw))
+	assert.True(t, raw.Equal(hex))
+	assert.True(t, NewSecretFromString("secret").Equal(NewSecretFromString("secret")))
+
+	assert.False(t, NewSecretFromString("secret").Equal(NewSecretFromString("Secret")))
+	assert.False(t, NewSecretFromString("café").Equal(latin1))
+	assert.False(t, NewSecretFromString("secret").Equal(nil))
+	assert.True(t, (*Secret)(nil).Equal(nil))
+
+	// can't be encoded as UTF-8
+	invalid := NewSecretFromString("\x00")
+	assert.True(t, invalid.Equal(invalid))
+	assert.False(t, invalid.Equal(NewSecretFromString("\x00")))
+}
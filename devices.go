@@ -0,0 +1,111 @@
+package iscsidsc
+
+// This file contains helpers to make sense of the devices reported on iSCSI sessions.
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// DeviceType is the `FILE_DEVICE_*` type of a device.
+// see https://docs.microsoft.com/en-us/windows-hardware/drivers/kernel/specifying-device-types
+type DeviceType uint32
+
+// The device types most relevant to storage; see the link above for the exhaustive list.
+const (
+	DeviceTypeCDROM       DeviceType = 0x00000002
+	DeviceTypeController  DeviceType = 0x00000004
+	DeviceTypeDisk        DeviceType = 0x00000007
+	DeviceTypeTape        DeviceType = 0x0000001f
+	DeviceTypeUnknown     DeviceType = 0x00000022
+	DeviceTypeVirtualDisk DeviceType = 0x00000024
+	DeviceTypeMassStorage DeviceType = 0x0000002d
+	DeviceTypeChanger     DeviceType = 0x00000030
+	DeviceTypeDVD         DeviceType = 0x00000033
+)
+
+var deviceTypeNames = map[DeviceType]string{
+	DeviceTypeCDROM:       "FILE_DEVICE_CD_ROM",
+	DeviceTypeController:  "FILE_DEVICE_CONTROLLER",
+	DeviceTypeDisk:        "FILE_DEVICE_DISK",
+	DeviceTypeTape:        "FILE_DEVICE_TAPE",
+	DeviceTypeUnknown:     "FILE_DEVICE_UNKNOWN",
+	DeviceTypeVirtualDisk: "FILE_DEVICE_VIRTUAL_DISK",
+	DeviceTypeMassStorage: "FILE_DEVICE_MASS_STORAGE",
+	DeviceTypeChanger:     "FILE_DEVICE_CHANGER",
+	DeviceTypeDVD:         "FILE_DEVICE_DVD",
+}
+
+func (deviceType DeviceType) String() string {
+	if name, present := deviceTypeNames[deviceType]; present {
+		return name
+	}
+	return fmt.Sprintf("DeviceType(0x%x)", uint32(deviceType))
+}
+
+// The well-known device interface classes for storage devices, as defined in `ntddstor.h`.
+// see https://docs.microsoft.com/en-us/windows-hardware/drivers/install/guid-devinterface-disk
+var (
+	DeviceInterfaceDisk          = uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceCDROM         = uuid.MustParse("53f56308-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfacePartition     = uuid.MustParse("53f5630a-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceTape          = uuid.MustParse("53f5630b-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceWriteOnceDisk = uuid.MustParse("53f5630c-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceVolume        = uuid.MustParse("53f5630d-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceMediumChanger = uuid.MustParse("53f56310-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceFloppy        = uuid.MustParse("53f56311-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceCDChanger     = uuid.MustParse("53f56312-b6bf-11d0-94f2-00a0c91efb8b")
+	DeviceInterfaceStoragePort   = uuid.MustParse("2accfe60-c130-11d2-b082-00a0c91efb8b")
+)
+
+var deviceInterfaceNames = map[uuid.UUID]string{
+	DeviceInterfaceDisk:          "GUID_DEVINTERFACE_DISK",
+	DeviceInterfaceCDROM:         "GUID_DEVINTERFACE_CDROM",
+	DeviceInterfacePartition:     "GUID_DEVINTERFACE_PARTITION",
+	DeviceInterfaceTape:          "GUID_DEVINTERFACE_TAPE",
+	DeviceInterfaceWriteOnceDisk: "GUID_DEVINTERFACE_WRITEONCEDISK",
+	DeviceInterfaceVolume:        "GUID_DEVINTERFACE_VOLUME",
+	DeviceInterfaceMediumChanger: "GUID_DEVINTERFACE_MEDIUMCHANGER",
+	DeviceInterfaceFloppy:        "GUID_DEVINTERFACE_FLOPPY",
+	DeviceInterfaceCDChanger:     "GUID_DEVINTERFACE_CDCHANGER",
+	DeviceInterfaceStoragePort:   "GUID_DEVINTERFACE_STORAGEPORT",
+}
+
+// DeviceInterfaceName returns the well-known name of the given device interface class, e.g.
+// "GUID_DEVINTERFACE_DISK", or its string representation if it's not a well-known one.
+func DeviceInterfaceName(class uuid.UUID) string {
+	if name, present := deviceInterfaceNames[class]; present {
+		return name
+	}
+	return class.String()
+}
+
+// DeviceInterfaceTypeName returns the well-known name of the device's interface class.
+func (device *Device) DeviceInterfaceTypeName() string {
+	return DeviceInterfaceName(device.DeviceInterfaceType)
+}
+
+// IsDisk returns true iff the device is a disk.
+func (device *Device) IsDisk() bool {
+	return device.StorageDeviceNumber.DeviceType == DeviceTypeDisk
+}
+
+// PhysicalDrivePath returns the path that can be used to open the whole disk, e.g. `\\.\PhysicalDrive2`;
+// it returns an empty string if the device is not a disk.
+func (device *Device) PhysicalDrivePath() string {
+	if !device.IsDisk() {
+		return ""
+	}
+	return fmt.Sprintf(`\\.\PhysicalDrive%d`, device.StorageDeviceNumber.DeviceNumber)
+}
+
+// PartitionPath returns the path that can be used to open the device's partition, e.g.
+// `\\.\Harddisk2Partition1`; partition 0 designates the whole disk.
+// It returns an empty string if the device is not a disk.
+func (device *Device) PartitionPath() string {
+	if !device.IsDisk() {
+		return ""
+	}
+	return fmt.Sprintf(`\\.\Harddisk%dPartition%d`, device.StorageDeviceNumber.DeviceNumber, device.StorageDeviceNumber.PartitionNumber)
+}
@@ -0,0 +1,70 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceTypeString(t *testing.T) {
+	assert.Equal(t, "FILE_DEVICE_DISK", DeviceTypeDisk.String())
+	assert.Equal(t, "FILE_DEVICE_CD_ROM", DeviceTypeCDROM.String())
+	assert.Equal(t, "FILE_DEVICE_TAPE", DeviceType(0x1f).String())
+	assert.Equal(t, "DeviceType(0x2c)", DeviceType(0x2c).String())
+}
+
+func TestDeviceInterfaceName(t *testing.T) {
+	assert.Equal(t, "GUID_DEVINTERFACE_DISK", DeviceInterfaceName(uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b")))
+	assert.Equal(t, "GUID_DEVINTERFACE_VOLUME", DeviceInterfaceName(DeviceInterfaceVolume))
+	assert.Equal(t, "01234567-89ab-cdef-0123-456789abcdef", DeviceInterfaceName(uuid.MustParse("01234567-89ab-cdef-0123-456789abcdef")))
+}
+
+func TestDeviceHelpers(t *testing.T) {
+	for _, testCase := range []struct {
+		name                      string
+		device                    *Device
+		expectedIsDisk            bool
+		expectedPhysicalDrivePath string
+		expectedPartitionPath     string
+		expectedInterfaceTypeName string
+	}{
+		{
+			name: "a disk",
+			device: &Device{
+				DeviceInterfaceType: DeviceInterfaceDisk,
+				StorageDeviceNumber: StorageDeviceNumber{DeviceType: DeviceTypeDisk, DeviceNumber: 2},
+			},
+			expectedIsDisk:            true,
+			expectedPhysicalDrivePath: `\\.\PhysicalDrive2`,
+			expectedPartitionPath:     `\\.\Harddisk2Partition0`,
+			expectedInterfaceTypeName: "GUID_DEVINTERFACE_DISK",
+		},
+		{
+			name: "a partition",
+			device: &Device{
+				DeviceInterfaceType: DeviceInterfacePartition,
+				StorageDeviceNumber: StorageDeviceNumber{DeviceType: DeviceTypeDisk, DeviceNumber: 12, PartitionNumber: 3},
+			},
+			expectedIsDisk:            true,
+			expectedPhysicalDrivePath: `\\.\PhysicalDrive12`,
+			expectedPartitionPath:     `\\.\Harddisk12Partition3`,
+			expectedInterfaceTypeName: "GUID_DEVINTERFACE_PARTITION",
+		},
+		{
+			name: "a tape",
+			device: &Device{
+				DeviceInterfaceType: DeviceInterfaceTape,
+				StorageDeviceNumber: StorageDeviceNumber{DeviceType: DeviceTypeTape, DeviceNumber: 1},
+			},
+			expectedInterfaceTypeName: "GUID_DEVINTERFACE_TAPE",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedIsDisk, testCase.device.IsDisk())
+			assert.Equal(t, testCase.expectedPhysicalDrivePath, testCase.device.PhysicalDrivePath())
+			assert.Equal(t, testCase.expectedPartitionPath, testCase.device.PartitionPath())
+			assert.Equal(t, testCase.expectedInterfaceTypeName, testCase.device.DeviceInterfaceTypeName())
+		})
+	}
+}
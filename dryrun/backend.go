@@ -0,0 +1,178 @@
+package dryrun
+
+import (
+	"sync"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/internal"
+)
+
+// ReadOnlyBackend performs the calls that don't change the initiator's state; it's satisfied
+// both by `iscsidsc.Backend`s and by `*iscsidsc.Client`s.
+type ReadOnlyBackend interface {
+	ReportIScsiTargets(forceUpdate bool) ([]string, error)
+	ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error)
+	GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error)
+	GetIScsiSessionList() ([]iscsidsc.SessionInfo, error)
+}
+
+// Backend is an `iscsidsc.Backend` that records the mutating calls it gets as `Operation`s instead
+// of performing them, and passes read-only calls through to another backend.
+// Mutating calls' arguments are validated and converted as the default backend does, and the same
+// errors are returned for invalid arguments; valid calls succeed without changing anything, and calls
+// returning IDs return zero IDs.
+// It is safe for concurrent use.
+type Backend struct {
+	readOnly ReadOnlyBackend
+
+	operations      []*Operation
+	operationsMutex sync.Mutex
+}
+
+var _ iscsidsc.Backend = &Backend{}
+
+// NewBackend builds a new dry-run backend, passing read-only calls through to readOnly; if nil, it
+// uses a client built with `iscsidsc.NewClient`'s default options, which makes calls to Windows' API.
+// Give it to clients with `iscsidsc.WithBackend`.
+func NewBackend(readOnly ReadOnlyBackend) *Backend {
+	if readOnly == nil {
+		readOnly = iscsidsc.NewClient()
+	}
+	return &Backend{readOnly: readOnly}
+}
+
+// Operations returns the operations recorded so far, in the order they were recorded.
+func (b *Backend) Operations() []*Operation {
+	b.operationsMutex.Lock()
+	defer b.operationsMutex.Unlock()
+
+	operations := make([]*Operation, len(b.operations))
+	copy(operations, b.operations)
+	return operations
+}
+
+// Reset forgets the operations recorded so far.
+func (b *Backend) Reset() {
+	b.operationsMutex.Lock()
+	defer b.operationsMutex.Unlock()
+
+	b.operations = nil
+}
+
+func (b *Backend) record(operation *Operation) {
+	b.operationsMutex.Lock()
+	defer b.operationsMutex.Unlock()
+
+	b.operations = append(b.operations, operation)
+}
+
+// ReportIScsiTargets implements `iscsidsc.Backend`, passing the call through.
+func (b *Backend) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	return b.readOnly.ReportIScsiTargets(forceUpdate)
+}
+
+// LoginIscsiTarget implements `iscsidsc.Backend`, recording the call.
+func (b *Backend) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	args, wipe, err := internal.ConvertLoginIscsiTargetArgs(targetName, isInformationalSession, initiatorInstance, initiatorPortNumber, targetPortal,
+		securityFlags, loginOptions, key, isPersistent)
+	if err != nil {
+		return nil, nil, err
+	}
+	// we only record the credentials' and key's lengths, their copies are of no use
+	defer wipe()
+
+	b.record(&Operation{
+		Name:                   "LoginIscsiTarget",
+		TargetName:             targetName,
+		IsInformationalSession: args.IsInformationalSession,
+		InitiatorInstance:      copyString(initiatorInstance),
+		InitiatorPortNumber:    &args.InitiatorPortNumber,
+		Portal:                 convertPortal(args.Portal),
+		SecurityFlags:          &args.SecurityFlags,
+		LoginOptions:           convertLoginOptions(args.LoginOptions),
+		KeySize:                args.KeySize,
+		IsPersistent:           args.IsPersistent,
+	})
+	return &iscsidsc.SessionID{}, &iscsidsc.ConnectionID{}, nil
+}
+
+// LogoutIScsiTarget implements `iscsidsc.Backend`, recording the call.
+func (b *Backend) LogoutIScsiTarget(sessionID iscsidsc.SessionID) error {
+	b.record(&Operation{
+		Name:      "LogoutIScsiTarget",
+		SessionID: &sessionID,
+	})
+	return nil
+}
+
+// AddIScsiSendTargetPortal implements `iscsidsc.Backend`, recording the call.
+func (b *Backend) AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions, securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) error {
+	args, wipe, err := internal.ConvertAddIScsiSendTargetPortalArgs(initiatorInstance, initiatorPortNumber, loginOptions, securityFlags, portal)
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	b.record(&Operation{
+		Name:                "AddIScsiSendTargetPortal",
+		InitiatorInstance:   copyString(initiatorInstance),
+		InitiatorPortNumber: &args.InitiatorPortNumber,
+		Portal:              convertPortal(args.Portal),
+		SecurityFlags:       &args.SecurityFlags,
+		LoginOptions:        convertLoginOptions(args.LoginOptions),
+	})
+	return nil
+}
+
+// ReportIScsiSendTargetPortals implements `iscsidsc.Backend`, passing the call through.
+func (b *Backend) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	return b.readOnly.ReportIScsiSendTargetPortals()
+}
+
+// RemoveIScsiSendTargetPortal implements `iscsidsc.Backend`, recording the call.
+func (b *Backend) RemoveIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, portal *iscsidsc.Portal) error {
+	args, err := internal.ConvertRemoveIScsiSendTargetPortalArgs(initiatorInstance, initiatorPortNumber, portal)
+	if err != nil {
+		return err
+	}
+
+	b.record(&Operation{
+		Name:                "RemoveIScsiSendTargetPortal",
+		InitiatorInstance:   copyString(initiatorInstance),
+		InitiatorPortNumber: &args.InitiatorPortNumber,
+		Portal:              convertPortal(args.Portal),
+	})
+	return nil
+}
+
+// AddIScsiConnection implements `iscsidsc.Backend`, recording the call.
+func (b *Backend) AddIScsiConnection(id iscsidsc.SessionID, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret) (*iscsidsc.ConnectionID, error) {
+	args, wipe, err := internal.ConvertAddIScsiConnectionArgs(id, initiatorPortNumber, targetPortal, securityFlags, loginOptions, key)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe()
+
+	b.record(&Operation{
+		Name:                "AddIScsiConnection",
+		SessionID:           &args.SessionID,
+		InitiatorPortNumber: &args.InitiatorPortNumber,
+		Portal:              convertPortal(args.Portal),
+		SecurityFlags:       &args.SecurityFlags,
+		LoginOptions:        convertLoginOptions(args.LoginOptions),
+		KeySize:             args.KeySize,
+	})
+	return &iscsidsc.ConnectionID{}, nil
+}
+
+// GetDevicesForIScsiSession implements `iscsidsc.Backend`, passing the call through.
+func (b *Backend) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	return b.readOnly.GetDevicesForIScsiSession(id)
+}
+
+// GetIScsiSessionList implements `iscsidsc.Backend`, passing the call through.
+func (b *Backend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return b.readOnly.GetIScsiSessionList()
+}
@@ -0,0 +1,189 @@
+package dryrun
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// fakeReadOnlyBackend returns canned sessions, and counts the calls it gets.
+type fakeReadOnlyBackend struct {
+	ReadOnlyBackend
+	sessions []iscsidsc.SessionInfo
+	calls    int
+}
+
+func (f *fakeReadOnlyBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	f.calls++
+	return f.sessions, nil
+}
+
+func uint16Ptr(i uint16) *uint16 {
+	return &i
+}
+
+func uint32Ptr(i uint32) *uint32 {
+	return &i
+}
+
+func TestLoginIsRecordedWithConvertedArguments(t *testing.T) {
+	backend := NewBackend(&fakeReadOnlyBackend{})
+	client := iscsidsc.NewClient(iscsidsc.WithBackend(backend))
+
+	authType := iscsidsc.MutualCHAPAuthType
+	securityFlags := iscsidsc.SecurityFlagIkeIpsecEnabled
+	sessionID, connectionID, err := client.Login(iscsidsc.NewLoginRequest(
+		"iqn.2010-01.com.example:target",
+		iscsidsc.WithTargetPortal(&iscsidsc.Portal{Address: "[FE80:0::1]"}),
+		iscsidsc.WithSecurityFlags(securityFlags),
+		iscsidsc.WithLoginOptions(&iscsidsc.LoginOptions{
+			LoginFlags: iscsidsc.LoginFlagMultipathEnabled,
+			AuthType:   &authType,
+			Username:   iscsidsc.NewSecretFromString("user"),
+			Password:   iscsidsc.NewSecretFromString("password1234"),
+		}),
+		iscsidsc.WithKey(iscsidsc.NewSecretFromString("key")),
+		iscsidsc.WithPersistence(),
+	))
+	require.NoError(t, err)
+	assert.True(t, sessionID.IsZero())
+	assert.True(t, connectionID.IsZero())
+
+	operations := backend.Operations()
+	require.Equal(t, 1, len(operations))
+	assert.Equal(t, &Operation{
+		Name:                "LoginIscsiTarget",
+		TargetName:          "iqn.2010-01.com.example:target",
+		InitiatorPortNumber: uint32Ptr(AllInitiatorPorts),
+		Portal:              &Portal{Address: "FE80:0::1", Socket: 3260},
+		SecurityFlags:       &securityFlags,
+		LoginOptions: &LoginOptions{
+			// AuthType, Username and Password
+			InformationSpecified: 0xe0,
+			LoginFlags:           iscsidsc.LoginFlagMultipathEnabled,
+			AuthType:             iscsidsc.MutualCHAPAuthType,
+			UsernameLength:       4,
+			PasswordLength:       12,
+		},
+		KeySize:      3,
+		IsPersistent: true,
+	}, operations[0])
+
+	assert.Equal(t, `LoginIscsiTarget(targetName="iqn.2010-01.com.example:target", initiatorPortNumber=all, portal=[FE80:0::1]:3260, `+
+		`securityFlags=ike-ipsec, loginOptions={Version:0 InformationSpecified:224 LoginFlags:multipath AuthType:mutual-chap HeaderDigest:none `+
+		`DataDigest:none MaximumConnections:0 DefaultTime2Wait:0 DefaultTime2Retain:0 UsernameLength:4 PasswordLength:12}, keySize=3, isPersistent=true)`,
+		operations[0].String())
+}
+
+func TestOtherMutatingCallsAreRecorded(t *testing.T) {
+	backend := NewBackend(&fakeReadOnlyBackend{})
+
+	initiatorInstance := `ROOT\ISCSIPRT\0000_0`
+	require.NoError(t, backend.AddIScsiSendTargetPortal(&initiatorInstance, uint32Ptr(1), nil, nil,
+		&iscsidsc.Portal{SymbolicName: "array", Address: "Array.Example.com.", Socket: uint16Ptr(3261)}))
+	require.NoError(t, backend.RemoveIScsiSendTargetPortal(nil, nil, &iscsidsc.Portal{Address: "10.0.0.5"}))
+
+	sessionID := iscsidsc.SessionID{AdapterUnique: 1, AdapterSpecific: 2}
+	maximumConnections := uint32(4)
+	connectionID, err := backend.AddIScsiConnection(sessionID, nil, &iscsidsc.Portal{Address: "10.0.0.6"}, nil,
+		&iscsidsc.LoginOptions{MaximumConnections: &maximumConnections}, nil)
+	require.NoError(t, err)
+	assert.True(t, connectionID.IsZero())
+
+	require.NoError(t, backend.LogoutIScsiTarget(sessionID))
+
+	noSecurityFlags := iscsidsc.SecurityFlags(0)
+	assert.Equal(t, []*Operation{
+		{
+			Name:                "AddIScsiSendTargetPortal",
+			InitiatorInstance:   &initiatorInstance,
+			InitiatorPortNumber: uint32Ptr(1),
+			Portal:              &Portal{SymbolicName: "array", Address: "Array.Example.com.", Socket: 3261},
+			SecurityFlags:       &noSecurityFlags,
+			LoginOptions:        &LoginOptions{},
+		},
+		{
+			Name:                "RemoveIScsiSendTargetPortal",
+			InitiatorPortNumber: uint32Ptr(AllInitiatorPorts),
+			Portal:              &Portal{Address: "10.0.0.5", Socket: 3260},
+		},
+		{
+			Name:                "AddIScsiConnection",
+			SessionID:           &sessionID,
+			InitiatorPortNumber: uint32Ptr(AllInitiatorPorts),
+			Portal:              &Portal{Address: "10.0.0.6", Socket: 3260},
+			SecurityFlags:       &noSecurityFlags,
+			LoginOptions: &LoginOptions{
+				InformationSpecified: 0x04,
+				MaximumConnections:   4,
+			},
+		},
+		{
+			Name:      "LogoutIScsiTarget",
+			SessionID: &sessionID,
+		},
+	}, backend.Operations())
+
+	assert.Equal(t, `AddIScsiSendTargetPortal(initiatorInstance="ROOT\\ISCSIPRT\\0000_0", initiatorPortNumber=1, `+
+		`portal=Array.Example.com.:3261 (array), securityFlags=none, loginOptions={Version:0 InformationSpecified:0 LoginFlags:none `+
+		`AuthType:none HeaderDigest:none DataDigest:none MaximumConnections:0 DefaultTime2Wait:0 DefaultTime2Retain:0 UsernameLength:0 PasswordLength:0})`,
+		backend.Operations()[0].String())
+	assert.Equal(t, "LogoutIScsiTarget(sessionID=0000000000000001-0000000000000002)", backend.Operations()[3].String())
+
+	backend.Reset()
+	assert.Empty(t, backend.Operations())
+}
+
+func TestInvalidCallsAreNotRecorded(t *testing.T) {
+	backend := NewBackend(&fakeReadOnlyBackend{})
+
+	_, _, err := backend.LoginIscsiTarget("iqn.2010-01.com.example:target", false, nil, nil, nil, nil,
+		&iscsidsc.LoginOptions{LoginFlags: 0x4}, nil, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid loginOptions argument")
+
+	_, _, err = backend.LoginIscsiTarget("iqn\x00", false, nil, nil, nil, nil, nil, nil, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid target name")
+
+	err = backend.AddIScsiSendTargetPortal(nil, nil, nil, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, "portal is required", err.Error())
+
+	_, err = backend.AddIScsiConnection(iscsidsc.SessionID{}, nil, nil, nil, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, "targetPortal is required", err.Error())
+
+	assert.Empty(t, backend.Operations())
+}
+
+func TestReadOnlyCallsArePassedThrough(t *testing.T) {
+	readOnly := &fakeReadOnlyBackend{sessions: []iscsidsc.SessionInfo{{TargetName: "iqn.2010-01.com.example:target"}}}
+	client := iscsidsc.NewClient(iscsidsc.WithBackend(NewBackend(readOnly)))
+
+	sessions, err := client.GetIScsiSessionList()
+	require.NoError(t, err)
+	assert.Equal(t, readOnly.sessions, sessions)
+	assert.Equal(t, 1, readOnly.calls)
+}
+
+func TestOperationsDontLeakCredentials(t *testing.T) {
+	backend := NewBackend(&fakeReadOnlyBackend{})
+
+	_, err := backend.AddIScsiConnection(iscsidsc.SessionID{}, nil, &iscsidsc.Portal{Address: "10.0.0.5"}, nil,
+		&iscsidsc.LoginOptions{Password: iscsidsc.NewSecretFromString("hunter2hunter2")}, iscsidsc.NewSecretFromString("s3cr3t-key"))
+	require.NoError(t, err)
+
+	operation := backend.Operations()[0]
+	marshalled, err := json.Marshal(operation)
+	require.NoError(t, err)
+	for _, rendered := range []string{string(marshalled), operation.String()} {
+		assert.NotContains(t, rendered, "hunter2")
+		assert.NotContains(t, rendered, "s3cr3t")
+	}
+	assert.Contains(t, string(marshalled), `"PasswordLength":14`)
+	assert.Contains(t, string(marshalled), `"KeySize":10`)
+}
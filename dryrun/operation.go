@@ -0,0 +1,155 @@
+package dryrun
+
+import (
+	"fmt"
+	"strings"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/internal"
+)
+
+// AllInitiatorPorts is the initiator port number passed to Windows' API when none is given,
+// meaning that Windows can use any of the initiator's ports.
+const AllInitiatorPorts = internal.AllInititatorPorts
+
+// Operation is a mutating call that a dry-run `Backend` recorded instead of performing it.
+// Its arguments are the ones that would have been passed to Windows' API, i.e. after the same
+// conversions as the default backend makes; the fields that don't apply to the call are left empty.
+type Operation struct {
+	// Name is the name of the call, e.g. "LoginIscsiTarget", as passed to `iscsidsc.Hooks`.
+	Name string
+
+	TargetName             string              `json:",omitempty"`
+	IsInformationalSession bool                `json:",omitempty"`
+	SessionID              *iscsidsc.SessionID `json:",omitempty"`
+	// InitiatorInstance is nil when letting Windows pick the initiator HBA.
+	InitiatorInstance *string `json:",omitempty"`
+	// InitiatorPortNumber is `AllInitiatorPorts` when none was given.
+	InitiatorPortNumber *uint32                 `json:",omitempty"`
+	Portal              *Portal                 `json:",omitempty"`
+	SecurityFlags       *iscsidsc.SecurityFlags `json:",omitempty"`
+	LoginOptions        *LoginOptions           `json:",omitempty"`
+	// KeySize is the size of the IPsec pre-shared key, once encoded; the key itself isn't recorded.
+	KeySize      uint32 `json:",omitempty"`
+	IsPersistent bool   `json:",omitempty"`
+}
+
+// Portal is a portal as passed to Windows' API, i.e. as given but without brackets around IPv6
+// addresses, and with its socket always set.
+type Portal struct {
+	SymbolicName string `json:",omitempty"`
+	Address      string
+	Socket       uint16
+}
+
+// LoginOptions are login options as passed to Windows' API; only the lengths of the credentials,
+// once encoded, are recorded.
+// see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/ns-iscsidsc-iscsi_login_options
+type LoginOptions struct {
+	Version uint32
+	// InformationSpecified is the bitmask of the fields that were set, as Windows expects it, e.g.
+	// 0x80 for AuthType.
+	InformationSpecified uint32
+	LoginFlags           iscsidsc.LoginFlags
+	AuthType             iscsidsc.AuthType
+	HeaderDigest         iscsidsc.DigestType
+	DataDigest           iscsidsc.DigestType
+	MaximumConnections   uint32
+	DefaultTime2Wait     uint32
+	DefaultTime2Retain   uint32
+	UsernameLength       uint32
+	PasswordLength       uint32
+}
+
+func (p *Portal) String() string {
+	portal := &iscsidsc.Portal{Address: p.Address, Socket: &p.Socket}
+	if p.SymbolicName == "" {
+		return portal.String()
+	}
+	return fmt.Sprintf("%s (%s)", portal, p.SymbolicName)
+}
+
+// String renders the operation as a call, with the arguments that apply to it, e.g.
+// `LoginIscsiTarget(targetName="iqn.2010-01.com.example:target", portal=10.0.0.5:3260, ...)`.
+func (op *Operation) String() string {
+	var args []string
+	addArg := func(name string, format string, value interface{}) {
+		args = append(args, fmt.Sprintf("%s="+format, name, value))
+	}
+
+	if op.TargetName != "" {
+		addArg("targetName", "%q", op.TargetName)
+	}
+	if op.IsInformationalSession {
+		addArg("isInformationalSession", "%v", true)
+	}
+	if op.SessionID != nil {
+		addArg("sessionID", "%v", op.SessionID)
+	}
+	if op.InitiatorInstance != nil {
+		addArg("initiatorInstance", "%q", *op.InitiatorInstance)
+	}
+	if op.InitiatorPortNumber != nil {
+		if *op.InitiatorPortNumber == AllInitiatorPorts {
+			addArg("initiatorPortNumber", "%s", "all")
+		} else {
+			addArg("initiatorPortNumber", "%d", *op.InitiatorPortNumber)
+		}
+	}
+	if op.Portal != nil {
+		addArg("portal", "%v", op.Portal)
+	}
+	if op.SecurityFlags != nil {
+		addArg("securityFlags", "%v", *op.SecurityFlags)
+	}
+	if op.LoginOptions != nil {
+		addArg("loginOptions", "%+v", *op.LoginOptions)
+	}
+	if op.KeySize != 0 {
+		addArg("keySize", "%d", op.KeySize)
+	}
+	if op.IsPersistent {
+		addArg("isPersistent", "%v", true)
+	}
+
+	return fmt.Sprintf("%s(%s)", op.Name, strings.Join(args, ", "))
+}
+
+// convertPortal converts a portal as passed to Windows' API, see `internal.CheckAndConvertPortal`.
+func convertPortal(portal *internal.Portal) *Portal {
+	if portal == nil {
+		return nil
+	}
+
+	return &Portal{
+		SymbolicName: internal.UTF16ToString(portal.SymbolicName[:]),
+		Address:      internal.UTF16ToString(portal.Address[:]),
+		Socket:       portal.Socket,
+	}
+}
+
+// convertLoginOptions converts login options as passed to Windows' API, see
+// `internal.CheckAndConvertLoginOptions`.
+func convertLoginOptions(opts *internal.LoginOptions) *LoginOptions {
+	return &LoginOptions{
+		Version:              opts.Version,
+		InformationSpecified: uint32(opts.InformationSpecified),
+		LoginFlags:           opts.LoginFlags,
+		AuthType:             opts.AuthType,
+		HeaderDigest:         opts.HeaderDigest,
+		DataDigest:           opts.DataDigest,
+		MaximumConnections:   opts.MaximumConnections,
+		DefaultTime2Wait:     opts.DefaultTime2Wait,
+		DefaultTime2Retain:   opts.DefaultTime2Retain,
+		UsernameLength:       opts.UsernameLength,
+		PasswordLength:       opts.PasswordLength,
+	}
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	sCopy := *s
+	return &sCopy
+}
@@ -0,0 +1,338 @@
+package iscsidsc
+
+// This file contains a catalog of the exit codes Windows' iSCSI discovery API can return,
+// along with their symbolic names and descriptions.
+// see https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers
+// and https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-erref/18d8fbe8-a967-4f1c-ae50-99ca8e491d2d
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorCode is an exit code returned by Windows' API.
+// All the `ErrorCode`s below can be used as sentinel errors, e.g.
+// `errors.Is(err, iscsidsc.ErrTargetNotFound)` is true if err is a `*WinAPICallError`
+// with that exit code.
+type ErrorCode uintptr
+
+// ErrorCodeOf returns the exit code of err if its cause (see `errors.Cause`) is a `*WinAPICallError`,
+// so that errors wrapped with `errors.Wrap` are accounted for.
+func ErrorCodeOf(err error) (ErrorCode, bool) {
+	winAPIErr, ok := errors.Cause(err).(*WinAPICallError)
+	if !ok {
+		return 0, false
+	}
+	return winAPIErr.Code(), true
+}
+
+// IsAlreadyLoggedIn returns true iff err says that a login failed because there already is a session
+// to the target; Windows reports that with either `ErrTargetAlreadyLoggedIn` or `ErrSessionAlreadyExists`.
+func IsAlreadyLoggedIn(err error) bool {
+	return HasErrorCode(err, ErrTargetAlreadyLoggedIn, ErrSessionAlreadyExists)
+}
+
+// HasErrorCode returns true iff err's cause is a `*WinAPICallError` with one of the given exit codes,
+// see `ErrorCodeOf`.
+func HasErrorCode(err error, codes ...ErrorCode) bool {
+	errCode, ok := ErrorCodeOf(err)
+	if !ok {
+		return false
+	}
+	for _, code := range codes {
+		if errCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// The iSCSI-specific exit codes, as defined in `iscsierr.h`.
+// Codes starting with 0xAFFF are warnings rather than errors.
+const (
+	ErrNonSpecific                       ErrorCode = 0xEFFF0001
+	ErrLoginFailed                       ErrorCode = 0xEFFF0002
+	ErrConnectionFailed                  ErrorCode = 0xEFFF0003
+	ErrInitiatorNodeAlreadyExists        ErrorCode = 0xEFFF0004
+	ErrInitiatorNodeNotFound             ErrorCode = 0xEFFF0005
+	ErrTargetMovedTemporarily            ErrorCode = 0xEFFF0006
+	ErrTargetMovedPermanently            ErrorCode = 0xEFFF0007
+	ErrInitiatorError                    ErrorCode = 0xEFFF0008
+	ErrLoginAuthFailed                   ErrorCode = 0xEFFF0009
+	ErrLoginAuthorizationFailed          ErrorCode = 0xEFFF000A
+	ErrNotFound                          ErrorCode = 0xEFFF000B
+	ErrTargetRemoved                     ErrorCode = 0xEFFF000C
+	ErrUnsupportedVersion                ErrorCode = 0xEFFF000D
+	ErrTooManyConnections                ErrorCode = 0xEFFF000E
+	ErrMissingParameter                  ErrorCode = 0xEFFF000F
+	ErrCantIncludeInSession              ErrorCode = 0xEFFF0010
+	ErrSessionTypeNotSupported           ErrorCode = 0xEFFF0011
+	ErrTargetError                       ErrorCode = 0xEFFF0012
+	ErrServiceUnavailable                ErrorCode = 0xEFFF0013
+	ErrOutOfResources                    ErrorCode = 0xEFFF0014
+	ErrConnectionAlreadyExists           ErrorCode = 0xEFFF0015
+	ErrSessionAlreadyExists              ErrorCode = 0xEFFF0016
+	ErrInitiatorInstanceNotFound         ErrorCode = 0xEFFF0017
+	ErrTargetAlreadyExists               ErrorCode = 0xEFFF0018
+	ErrDriverBug                         ErrorCode = 0xEFFF0019
+	ErrInvalidTextKey                    ErrorCode = 0xEFFF001A
+	ErrInvalidSendTargetsText            ErrorCode = 0xEFFF001B
+	ErrSessionNotFound                   ErrorCode = 0xEFFF001C
+	ErrSCSIRequestFailed                 ErrorCode = 0xEFFF001D
+	ErrTooManySessions                   ErrorCode = 0xEFFF001E
+	ErrSessionBusy                       ErrorCode = 0xEFFF001F
+	ErrTargetMappingUnavailable          ErrorCode = 0xEFFF0020
+	ErrAddressTypeNotSupported           ErrorCode = 0xEFFF0021
+	ErrLogonFailed                       ErrorCode = 0xEFFF0022
+	ErrSendFailed                        ErrorCode = 0xEFFF0023
+	ErrTransportError                    ErrorCode = 0xEFFF0024
+	ErrVersionMismatch                   ErrorCode = 0xEFFF0025
+	ErrTargetMappingOutOfRange           ErrorCode = 0xEFFF0026
+	ErrTargetPresharedKeyUnavailable     ErrorCode = 0xEFFF0027
+	ErrTargetAuthInfoUnavailable         ErrorCode = 0xEFFF0028
+	ErrTargetNotFound                    ErrorCode = 0xEFFF0029
+	ErrLoginUserInfoBad                  ErrorCode = 0xEFFF002A
+	ErrTargetMappingExists               ErrorCode = 0xEFFF002B
+	ErrHBASecurityCacheFull              ErrorCode = 0xEFFF002C
+	ErrInvalidPortNumber                 ErrorCode = 0xEFFF002D
+	ErrOperationNotAllSuccess            ErrorCode = 0xAFFF002E
+	ErrHBASecurityCacheNotSupported      ErrorCode = 0xEFFF002F
+	ErrIKEIDPayloadTypeNotSupported      ErrorCode = 0xEFFF0030
+	ErrIKEIDPayloadIncorrectSize         ErrorCode = 0xEFFF0031
+	ErrTargetPortalAlreadyExists         ErrorCode = 0xEFFF0032
+	ErrTargetAddressAlreadyExists        ErrorCode = 0xEFFF0033
+	ErrNoAuthInfoAvailable               ErrorCode = 0xEFFF0034
+	ErrNoTunnelOuterModeAddress          ErrorCode = 0xEFFF0035
+	ErrCacheCorrupted                    ErrorCode = 0xEFFF0036
+	ErrRequestNotSupported               ErrorCode = 0xEFFF0037
+	ErrTargetOutOfResources              ErrorCode = 0xEFFF0038
+	ErrServiceDidNotRespond              ErrorCode = 0xEFFF0039
+	ErrISNSServerNotFound                ErrorCode = 0xEFFF003A
+	ErrOperationRequiresReboot           ErrorCode = 0xAFFF003B
+	ErrNoPortalSpecified                 ErrorCode = 0xEFFF003C
+	ErrCantRemoveLastConnection          ErrorCode = 0xEFFF003D
+	ErrServiceNotRunning                 ErrorCode = 0xEFFF003E
+	ErrTargetAlreadyLoggedIn             ErrorCode = 0xEFFF003F
+	ErrDeviceBusyOnSession               ErrorCode = 0xEFFF0040
+	ErrCouldNotSavePersistentLoginData   ErrorCode = 0xEFFF0041
+	ErrCouldNotRemovePersistentLoginData ErrorCode = 0xEFFF0042
+	ErrPortalNotFound                    ErrorCode = 0xEFFF0043
+	ErrInitiatorNotFound                 ErrorCode = 0xEFFF0044
+	ErrDiscoveryMechanismNotFound        ErrorCode = 0xEFFF0045
+	ErrIPSecNotSupportedOnOS             ErrorCode = 0xEFFF0046
+	ErrPersistentLoginTimeout            ErrorCode = 0xEFFF0047
+	ErrShortCHAPSecret                   ErrorCode = 0xAFFF0048
+	ErrEvaluationPeriodExpired           ErrorCode = 0xEFFF0049
+	ErrInvalidCHAPSecret                 ErrorCode = 0xEFFF004A
+	ErrInvalidTargetCHAPSecret           ErrorCode = 0xEFFF004B
+	ErrInvalidInitiatorCHAPSecret        ErrorCode = 0xEFFF004C
+	ErrInvalidCHAPUserName               ErrorCode = 0xEFFF004D
+	ErrInvalidLogonAuthType              ErrorCode = 0xEFFF004E
+	ErrInvalidTargetMapping              ErrorCode = 0xEFFF004F
+	ErrInvalidTargetID                   ErrorCode = 0xEFFF0050
+	ErrInvalidISCSIName                  ErrorCode = 0xEFFF0051
+	ErrIncompatibleISNSVersion           ErrorCode = 0xEFFF0052
+	ErrFailedToConfigureIPSec            ErrorCode = 0xEFFF0053
+	ErrBufferTooSmall                    ErrorCode = 0xEFFF0054
+	ErrInvalidLoadBalancePolicy          ErrorCode = 0xEFFF0055
+	ErrInvalidParameter                  ErrorCode = 0xEFFF0056
+	ErrDuplicatePathSpecified            ErrorCode = 0xEFFF0057
+	ErrPathCountMismatch                 ErrorCode = 0xEFFF0058
+	ErrInvalidPathID                     ErrorCode = 0xEFFF0059
+	ErrMultiplePrimaryPathsSpecified     ErrorCode = 0xEFFF005A
+	ErrNoPrimaryPathSpecified            ErrorCode = 0xEFFF005B
+	ErrDeviceAlreadyPersistentlyBound    ErrorCode = 0xEFFF005C
+	ErrDeviceNotFound                    ErrorCode = 0xEFFF005D
+	ErrDeviceNotISCSIOrPersistent        ErrorCode = 0xEFFF005E
+	ErrDNSNameUnresolved                 ErrorCode = 0xEFFF005F
+	ErrNoConnectionAvailable             ErrorCode = 0xEFFF0060
+	ErrLoadBalancePolicyNotSupported     ErrorCode = 0xEFFF0061
+	ErrRemoveConnectionInProgress        ErrorCode = 0xEFFF0062
+	ErrConnectionNotFound                ErrorCode = 0xEFFF0063
+	ErrCannotRemoveLeadingConnection     ErrorCode = 0xEFFF0064
+	ErrRestrictedByGroupPolicy           ErrorCode = 0xEFFF0065
+	ErrISNSFirewallBlocked               ErrorCode = 0xEFFF0066
+	ErrFailureToPersistLoadBalancePolicy ErrorCode = 0xEFFF0067
+	ErrInvalidHost                       ErrorCode = 0xEFFF0068
+)
+
+// The generic Win32 exit codes most relevant to this package.
+const (
+	ErrInvalidFunction       ErrorCode = 0x00000001
+	ErrFileNotFound          ErrorCode = 0x00000002
+	ErrAccessDenied          ErrorCode = 0x00000005
+	ErrNotEnoughMemory       ErrorCode = 0x00000008
+	ErrOutOfMemory           ErrorCode = 0x0000000E
+	ErrWin32NotSupported     ErrorCode = 0x00000032
+	ErrWin32InvalidParameter ErrorCode = 0x00000057
+	ErrSemTimeout            ErrorCode = 0x00000079
+	ErrInsufficientBuffer    ErrorCode = 0x0000007A
+	ErrProcNotFound          ErrorCode = 0x0000007F
+	ErrNoAccess              ErrorCode = 0x000003E6
+	ErrTimeout               ErrorCode = 0x000005B4
+	ErrRPCServerUnavailable  ErrorCode = 0x000006BA
+)
+
+type errorCodeInfo struct {
+	name        string
+	description string
+}
+
+var errorCodes = map[ErrorCode]errorCodeInfo{
+	ErrNonSpecific:                       {"ISDSC_NON_SPECIFIC_ERROR", "A non-specific error occurred."},
+	ErrLoginFailed:                       {"ISDSC_LOGIN_FAILED", "Login failed."},
+	ErrConnectionFailed:                  {"ISDSC_CONNECTION_FAILED", "Connection failed."},
+	ErrInitiatorNodeAlreadyExists:        {"ISDSC_INITIATOR_NODE_ALREADY_EXISTS", "Initiator node already exists."},
+	ErrInitiatorNodeNotFound:             {"ISDSC_INITIATOR_NODE_NOT_FOUND", "Initiator node does not exist."},
+	ErrTargetMovedTemporarily:            {"ISDSC_TARGET_MOVED_TEMPORARILY", "Target has moved temporarily."},
+	ErrTargetMovedPermanently:            {"ISDSC_TARGET_MOVED_PERMANENTLY", "Target has moved permanently."},
+	ErrInitiatorError:                    {"ISDSC_INITIATOR_ERROR", "The initiator had an error."},
+	ErrLoginAuthFailed:                   {"ISDSC_AUTHENTICATION_FAILURE", "Authentication failure."},
+	ErrLoginAuthorizationFailed:          {"ISDSC_AUTHORIZATION_FAILED", "Authorization failure."},
+	ErrNotFound:                          {"ISDSC_NOT_FOUND", "Not found."},
+	ErrTargetRemoved:                     {"ISDSC_TARGET_REMOVED", "Target has been removed."},
+	ErrUnsupportedVersion:                {"ISDSC_UNSUPPORTED_VERSION", "Unsupported iSCSI version."},
+	ErrTooManyConnections:                {"ISDSC_TOO_MANY_CONNECTIONS", "Too many connections."},
+	ErrMissingParameter:                  {"ISDSC_MISSING_PARAMETER", "Missing parameter."},
+	ErrCantIncludeInSession:              {"ISDSC_CANT_INCLUDE_IN_SESSION", "Cannot include in session."},
+	ErrSessionTypeNotSupported:           {"ISDSC_SESSION_TYPE_NOT_SUPPORTED", "Session type not supported."},
+	ErrTargetError:                       {"ISDSC_TARGET_ERROR", "Target error."},
+	ErrServiceUnavailable:                {"ISDSC_SERVICE_UNAVAILABLE", "Service unavailable."},
+	ErrOutOfResources:                    {"ISDSC_OUT_OF_RESOURCES", "Out of resources."},
+	ErrConnectionAlreadyExists:           {"ISDSC_CONNECTION_ALREADY_EXISTS", "Connections already exist on initiator node."},
+	ErrSessionAlreadyExists:              {"ISDSC_SESSION_ALREADY_EXISTS", "Session already exists."},
+	ErrInitiatorInstanceNotFound:         {"ISDSC_INITIATOR_INSTANCE_NOT_FOUND", "Initiator instance does not exist."},
+	ErrTargetAlreadyExists:               {"ISDSC_TARGET_ALREADY_EXISTS", "Target already exists."},
+	ErrDriverBug:                         {"ISDSC_DRIVER_BUG", "The iSCSI driver implementation did not complete an operation correctly."},
+	ErrInvalidTextKey:                    {"ISDSC_INVALID_TEXT_KEY", "An invalid key text was encountered."},
+	ErrInvalidSendTargetsText:            {"ISDSC_INVALID_SENDTARGETS_TEXT", "Invalid SendTargets response text was encountered."},
+	ErrSessionNotFound:                   {"ISDSC_INVALID_SESSION_ID", "Invalid session ID."},
+	ErrSCSIRequestFailed:                 {"ISDSC_SCSI_REQUEST_FAILED", "The SCSI request failed."},
+	ErrTooManySessions:                   {"ISDSC_TOO_MANY_SESSIONS", "Exceeded max sessions for this initiator."},
+	ErrSessionBusy:                       {"ISDSC_SESSION_BUSY", "Session is busy since a request is already in progress."},
+	ErrTargetMappingUnavailable:          {"ISDSC_TARGET_MAPPING_UNAVAILABLE", "The target mapping is unavailable."},
+	ErrAddressTypeNotSupported:           {"ISDSC_ADDRESS_TYPE_NOT_SUPPORTED", "The Target Address type given is not supported."},
+	ErrLogonFailed:                       {"ISDSC_LOGON_FAILED", "Logon failed."},
+	ErrSendFailed:                        {"ISDSC_SEND_FAILED", "TCP Send failed."},
+	ErrTransportError:                    {"ISDSC_TRANSPORT_ERROR", "TDI error."},
+	ErrVersionMismatch:                   {"ISDSC_VERSION_MISMATCH", "The iSCSI version supported by the target does not match the initiator's."},
+	ErrTargetMappingOutOfRange:           {"ISDSC_TARGET_MAPPING_OUT_OF_RANGE", "The target mapping address passed is out of range for the adapter configuration."},
+	ErrTargetPresharedKeyUnavailable:     {"ISDSC_TARGET_PRESHAREDKEY_UNAVAILABLE", "The preshared key for the target or IKE identification payload is not available."},
+	ErrTargetAuthInfoUnavailable:         {"ISDSC_TARGET_AUTHINFO_UNAVAILABLE", "The authentication information for the target is not available."},
+	ErrTargetNotFound:                    {"ISDSC_TARGET_NOT_FOUND", "The target name is not found or is marked as hidden from login."},
+	ErrLoginUserInfoBad:                  {"ISDSC_LOGIN_USER_INFO_BAD", "One or more parameters specified in LoginTargetIN structure is invalid."},
+	ErrTargetMappingExists:               {"ISDSC_TARGET_MAPPING_EXISTS", "The given target mapping already exists."},
+	ErrHBASecurityCacheFull:              {"ISDSC_HBA_SECURITY_CACHE_FULL", "The HBA security information cache is full."},
+	ErrInvalidPortNumber:                 {"ISDSC_INVALID_PORT_NUMBER", "The port number passed is not valid for the initiator."},
+	ErrOperationNotAllSuccess:            {"ISDSC_OPERATION_NOT_ALL_SUCCESS", "The operation was not successful for all initiators or discovery methods."},
+	ErrHBASecurityCacheNotSupported:      {"ISDSC_HBA_SECURITY_CACHE_NOT_SUPPORTED", "The HBA security information cache is not supported by this adapter."},
+	ErrIKEIDPayloadTypeNotSupported:      {"ISDSC_IKE_ID_PAYLOAD_TYPE_NOT_SUPPORTED", "The IKE id payload type specified is not supported."},
+	ErrIKEIDPayloadIncorrectSize:         {"ISDSC_IKE_ID_PAYLOAD_INCORRECT_SIZE", "The IKE id payload size specified is not correct."},
+	ErrTargetPortalAlreadyExists:         {"ISDSC_TARGET_PORTAL_ALREADY_EXISTS", "Target portal structure specified already exists."},
+	ErrTargetAddressAlreadyExists:        {"ISDSC_TARGET_ADDRESS_ALREADY_EXISTS", "Target address structure specified already exists."},
+	ErrNoAuthInfoAvailable:               {"ISDSC_NO_AUTH_INFO_AVAILABLE", "There is no IKE authentication information available."},
+	ErrNoTunnelOuterModeAddress:          {"ISDSC_NO_TUNNEL_OUTER_MODE_ADDRESS", "There is no tunnel mode outer address specified."},
+	ErrCacheCorrupted:                    {"ISDSC_CACHE_CORRUPTED", "Authentication or tunnel address cache is corrupted."},
+	ErrRequestNotSupported:               {"ISDSC_REQUEST_NOT_SUPPORTED", "The request or operation is not supported."},
+	ErrTargetOutOfResources:              {"ISDSC_TARGET_OUT_OF_RESORCES", "The target does not have enough resources to process the given request."},
+	ErrServiceDidNotRespond:              {"ISDSC_SERVICE_DID_NOT_RESPOND", "The initiator service did not respond to the request sent by the driver."},
+	ErrISNSServerNotFound:                {"ISDSC_ISNS_SERVER_NOT_FOUND", "The Internet Storage Name Server (iSNS) server was not found or is unavailable."},
+	ErrOperationRequiresReboot:           {"ISDSC_OPERATION_REQUIRES_REBOOT", "The operation was successful but requires a driver reload or reboot to become effective."},
+	ErrNoPortalSpecified:                 {"ISDSC_NO_PORTAL_SPECIFIED", "There is no target portal available to complete the login."},
+	ErrCantRemoveLastConnection:          {"ISDSC_CANT_REMOVE_LAST_CONNECTION", "Cannot remove the last connection for a session."},
+	ErrServiceNotRunning:                 {"ISDSC_SERVICE_NOT_RUNNING", "The Microsoft iSCSI initiator service has not been started."},
+	ErrTargetAlreadyLoggedIn:             {"ISDSC_TARGET_ALREADY_LOGGED_IN", "The target has already been logged in via an iSCSI session."},
+	ErrDeviceBusyOnSession:               {"ISDSC_DEVICE_BUSY_ON_SESSION", "The session cannot be logged out since a device on that session is currently being used."},
+	ErrCouldNotSavePersistentLoginData:   {"ISDSC_COULD_NOT_SAVE_PERSISTENT_LOGIN_DATA", "Failed to save persistent login information."},
+	ErrCouldNotRemovePersistentLoginData: {"ISDSC_COULD_NOT_REMOVE_PERSISTENT_LOGIN_DATA", "Failed to remove persistent login information."},
+	ErrPortalNotFound:                    {"ISDSC_PORTAL_NOT_FOUND", "The specified portal was not found."},
+	ErrInitiatorNotFound:                 {"ISDSC_INITIATOR_NOT_FOUND", "The specified initiator name was not found."},
+	ErrDiscoveryMechanismNotFound:        {"ISDSC_DISCOVERY_MECHANISM_NOT_FOUND", "The specified discovery mechanism was not found."},
+	ErrIPSecNotSupportedOnOS:             {"ISDSC_IPSEC_NOT_SUPPORTED_ON_OS", "iSCSI does not support IPSEC for this version of the OS."},
+	ErrPersistentLoginTimeout:            {"ISDSC_PERSISTENT_LOGIN_TIMEOUT", "The iSCSI service timed out waiting for all persistent logins to complete."},
+	ErrShortCHAPSecret:                   {"ISDSC_SHORT_CHAP_SECRET", "The specified CHAP secret is less than 96 bits and will not be usable for authenticating over non ipsec connections."},
+	ErrEvaluationPeriodExpired:           {"ISDSC_EVALUATION_PEROID_EXPIRED", "The evaluation period for the iSCSI initiator service has expired."},
+	ErrInvalidCHAPSecret:                 {"ISDSC_INVALID_CHAP_SECRET", "CHAP secret given does not conform to the standard."},
+	ErrInvalidTargetCHAPSecret:           {"ISDSC_INVALID_TARGET_CHAP_SECRET", "Target CHAP secret given is invalid."},
+	ErrInvalidInitiatorCHAPSecret:        {"ISDSC_INVALID_INITIATOR_CHAP_SECRET", "Initiator CHAP secret given is invalid."},
+	ErrInvalidCHAPUserName:               {"ISDSC_INVALID_CHAP_USER_NAME", "CHAP Username given is invalid."},
+	ErrInvalidLogonAuthType:              {"ISDSC_INVALID_LOGON_AUTH_TYPE", "Logon Authentication type given is invalid."},
+	ErrInvalidTargetMapping:              {"ISDSC_INVALID_TARGET_MAPPING", "Target Mapping information given is invalid."},
+	ErrInvalidTargetID:                   {"ISDSC_INVALID_TARGET_ID", "Target Id given in Target Mapping is invalid."},
+	ErrInvalidISCSIName:                  {"ISDSC_INVALID_ISCSI_NAME", "The iSCSI name specified contains invalid characters or is too long."},
+	ErrIncompatibleISNSVersion:           {"ISDSC_INCOMPATIBLE_ISNS_VERSION", "The version number returned from the iSNS server is not compatible with this version of the iSNS client."},
+	ErrFailedToConfigureIPSec:            {"ISDSC_FAILED_TO_CONFIGURE_IPSEC", "Initiator failed to configure IPSec for the given connection."},
+	ErrBufferTooSmall:                    {"ISDSC_BUFFER_TOO_SMALL", "The buffer given for processing the request is too small."},
+	ErrInvalidLoadBalancePolicy:          {"ISDSC_INVALID_LOAD_BALANCE_POLICY", "The given Load Balance policy is not recognized by iScsi initiator."},
+	ErrInvalidParameter:                  {"ISDSC_INVALID_PARAMETER", "One or more paramaters specified is not valid."},
+	ErrDuplicatePathSpecified:            {"ISDSC_DUPLICATE_PATH_SPECIFIED", "Duplicate PathIds were specified in the call to set Load Balance Policy."},
+	ErrPathCountMismatch:                 {"ISDSC_PATH_COUNT_MISMATCH", "Number of paths specified in Set Load Balance Policy does not match the number of paths to the target."},
+	ErrInvalidPathID:                     {"ISDSC_INVALID_PATH_ID", "Path Id specified in the call to set Load Balance Policy is not valid."},
+	ErrMultiplePrimaryPathsSpecified:     {"ISDSC_MULTIPLE_PRIMARY_PATHS_SPECIFIED", "Multiple primary paths specified when only one primary path is expected."},
+	ErrNoPrimaryPathSpecified:            {"ISDSC_NO_PRIMARY_PATH_SPECIFIED", "No primary path specified when at least one is expected."},
+	ErrDeviceAlreadyPersistentlyBound:    {"ISDSC_DEVICE_ALREADY_PERSISTENTLY_BOUND", "Device is already a persistently bound device."},
+	ErrDeviceNotFound:                    {"ISDSC_DEVICE_NOT_FOUND", "Device was not found."},
+	ErrDeviceNotISCSIOrPersistent:        {"ISDSC_DEVICE_NOT_ISCSI_OR_PERSISTENT", "The device specified does not originate from an iSCSI disk or a persistent iSCSI login."},
+	ErrDNSNameUnresolved:                 {"ISDSC_DNS_NAME_UNRESOLVED", "The DNS name specified was not resolved."},
+	ErrNoConnectionAvailable:             {"ISDSC_NO_CONNECTION_AVAILABLE", "There is no connection available in the iSCSI session to process a request."},
+	ErrLoadBalancePolicyNotSupported:     {"ISDSC_LB_POLICY_NOT_SUPPORTED", "The given Load Balance policy is not supported."},
+	ErrRemoveConnectionInProgress:        {"ISDSC_REMOVE_CONNECTION_IN_PROGRESS", "A remove connection request is already in progress for this session."},
+	ErrConnectionNotFound:                {"ISDSC_INVALID_CONNECTION_ID", "Given connection was not found in the session."},
+	ErrCannotRemoveLeadingConnection:     {"ISDSC_CANNOT_REMOVE_LEADING_CONNECTION", "The leading connection in the session cannot be removed."},
+	ErrRestrictedByGroupPolicy:           {"ISDSC_RESTRICTED_BY_GROUP_POLICY", "The operation cannot be performed since it does not conform with the group policy assigned to this computer."},
+	ErrISNSFirewallBlocked:               {"ISDSC_ISNS_FIREWALL_BLOCKED", "The operation cannot be performed since the Internet Storage Name Server (iSNS) firewall exception has not been enabled."},
+	ErrFailureToPersistLoadBalancePolicy: {"ISDSC_FAILURE_TO_PERSIST_LB_POLICY", "Failed to persist load balancing policy parameters."},
+	ErrInvalidHost:                       {"ISDSC_INVALID_HOST", "The name could not be resolved to an IP Address."},
+	ErrInvalidFunction:                   {"ERROR_INVALID_FUNCTION", "Incorrect function."},
+	ErrFileNotFound:                      {"ERROR_FILE_NOT_FOUND", "The system cannot find the file specified."},
+	ErrAccessDenied:                      {"ERROR_ACCESS_DENIED", "Access is denied."},
+	ErrNotEnoughMemory:                   {"ERROR_NOT_ENOUGH_MEMORY", "Not enough memory resources are available to process this command."},
+	ErrOutOfMemory:                       {"ERROR_OUTOFMEMORY", "Not enough memory resources are available to complete this operation."},
+	ErrWin32NotSupported:                 {"ERROR_NOT_SUPPORTED", "The request is not supported."},
+	ErrWin32InvalidParameter:             {"ERROR_INVALID_PARAMETER", "The parameter is incorrect."},
+	ErrSemTimeout:                        {"ERROR_SEM_TIMEOUT", "The semaphore timeout period has expired."},
+	ErrInsufficientBuffer:                {"ERROR_INSUFFICIENT_BUFFER", "The data area passed to a system call is too small."},
+	ErrProcNotFound:                      {"ERROR_PROC_NOT_FOUND", "The specified procedure could not be found."},
+	ErrNoAccess:                          {"ERROR_NOACCESS", "Invalid access to memory location."},
+	ErrTimeout:                           {"ERROR_TIMEOUT", "This operation returned because the timeout period expired."},
+	ErrRPCServerUnavailable:              {"RPC_S_SERVER_UNAVAILABLE", "The RPC server is unavailable."},
+}
+
+// Name returns the symbolic name of the code, as found in Windows' headers, e.g.
+// "ISDSC_TARGET_NOT_FOUND"; or an empty string if the code is unknown.
+func (code ErrorCode) Name() string {
+	return errorCodes[code].name
+}
+
+// Description returns a human-readable description of the code, or an empty
+// string if the code is unknown.
+func (code ErrorCode) Description() string {
+	return errorCodes[code].description
+}
+
+// Known returns true iff the code is part of this package's catalog.
+func (code ErrorCode) Known() bool {
+	_, present := errorCodes[code]
+	return present
+}
+
+// HexCode formats the code into the hexadecimal representation one
+// can find in Windows' documentation.
+func (code ErrorCode) HexCode() string {
+	return fmt.Sprintf("0x%08X", uintptr(code))
+}
+
+func (code ErrorCode) String() string {
+	if info, present := errorCodes[code]; present {
+		return fmt.Sprintf("%s (%s)", code.HexCode(), info.name)
+	}
+	return code.HexCode()
+}
+
+func (code ErrorCode) Error() string {
+	if info, present := errorCodes[code]; present {
+		return fmt.Sprintf("%s (%s): %s", code.HexCode(), info.name, info.description)
+	}
+	return code.HexCode()
+}
@@ -0,0 +1,9 @@
+package iscsidsc
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned by all the functions that make calls to Windows' API
+// when running on any other platform.
+var ErrNotSupported = errors.New("Windows' iSCSI discovery API is not supported on this platform")
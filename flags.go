@@ -0,0 +1,308 @@
+package iscsidsc
+
+// This file contains the text representations of the public enum and flag types, e.g. to use them in
+// logs or config files. Flag sets are rendered as their flags' names separated by pipes, e.g.
+// "ipsec|multipath"; enums as their value's name, e.g. "mutual-chap".
+// All types marshal to JSON as strings, and can be unmarshalled from either strings or numbers, for
+// backward compatibility with configs using numeric values.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// noFlags is how empty flag sets are rendered.
+const noFlags = "none"
+
+type flagName struct {
+	value uint64
+	name  string
+}
+
+var loginFlagNames = []flagName{
+	{uint64(LoginFlagRequireIPSec), "ipsec"},
+	{uint64(LoginFlagMultipathEnabled), "multipath"},
+	{uint64(loginFlagReserved1), "reserved1"},
+	{uint64(LoginFlagAllowPortalHopping), "portal-hopping"},
+	{uint64(LoginFlagUseRadiusResponse), "radius-response"},
+	{uint64(LoginFlagUseRadiusVerification), "radius-verification"},
+}
+
+var securityFlagNames = []flagName{
+	{uint64(SecurityFlagIkeIpsecEnabled), "ike-ipsec"},
+	{uint64(SecurityFlagMainModeEnabled), "main-mode"},
+	{uint64(SecurityFlagAggressiveModeEnabled), "aggressive-mode"},
+	{uint64(SecurityFlagPfsEnabled), "pfs"},
+	{uint64(SecurityFlagTransportModePreferred), "transport-mode"},
+	{uint64(SecurityFlagTunnelModePreferred), "tunnel-mode"},
+}
+
+var authTypeNames = []flagName{
+	{uint64(NoAuthAuthType), "none"},
+	{uint64(CHAPAuthType), "chap"},
+	{uint64(MutualCHAPAuthType), "mutual-chap"},
+}
+
+var digestTypeNames = []flagName{
+	{uint64(DigestTypeNone), "none"},
+	{uint64(DigestTypeCRC32C), "crc32c"},
+}
+
+// loginFlagReserved1 maps to `ISCSI_LOGIN_FLAG_RESERVED1`, reserved for Windows' API internal usage.
+const loginFlagReserved1 LoginFlags = 0x00000004
+
+// formatFlags renders the flags set in value; unknown bits are rendered in hex.
+func formatFlags(value uint64, names []flagName) string {
+	if value == 0 {
+		return noFlags
+	}
+
+	var parts []string
+	for _, flag := range names {
+		if value&flag.value != 0 {
+			parts = append(parts, flag.name)
+			value &^= flag.value
+		}
+	}
+	if value != 0 {
+		parts = append(parts, fmt.Sprintf("%#x", value))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// parseFlags parses the output of `formatFlags`; names are case-insensitive, and blanks are ignored.
+func parseFlags(s string, names []flagName, typeName string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, noFlags) {
+		return 0, nil
+	}
+
+	var value uint64
+	for _, part := range strings.Split(s, "|") {
+		flag, err := parseName(part, names, typeName)
+		if err != nil {
+			return 0, err
+		}
+		value |= flag
+	}
+	return value, nil
+}
+
+// formatEnum renders value's name, or its numeric value if unknown.
+func formatEnum(value uint64, names []flagName, typeName string) string {
+	for _, name := range names {
+		if name.value == value {
+			return name.name
+		}
+	}
+	return fmt.Sprintf("%s(%d)", typeName, value)
+}
+
+// parseName parses a single name from names, or a numeric value.
+func parseName(s string, names []flagName, typeName string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	for _, name := range names {
+		if strings.EqualFold(s, name.name) {
+			return name.value, nil
+		}
+	}
+	if value, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return value, nil
+	}
+	return 0, errors.Errorf("unknown %s: %q", typeName, s)
+}
+
+// jsonToText returns the contents of data if it's a JSON string, or the number itself if it's a JSON number;
+// the result can then be passed to `UnmarshalText` methods.
+func jsonToText(data []byte, typeName string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", typeName)
+	}
+	switch typedValue := value.(type) {
+	case string:
+		return []byte(typedValue), nil
+	case json.Number:
+		return []byte(typedValue.String()), nil
+	default:
+		return nil, errors.Errorf("%s must be either a string or a number, got %s", typeName, string(data))
+	}
+}
+
+func (flags LoginFlags) String() string {
+	return formatFlags(uint64(flags), loginFlagNames)
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (flags LoginFlags) MarshalText() ([]byte, error) {
+	return []byte(flags.String()), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (flags *LoginFlags) UnmarshalText(text []byte) error {
+	value, err := parseFlags(string(text), loginFlagNames, "login flag")
+	if err != nil {
+		return err
+	}
+	if value > uint64(^LoginFlags(0)) {
+		return errors.Errorf("login flags out of range: %#x", value)
+	}
+	*flags = LoginFlags(value)
+	return nil
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`.
+func (flags *LoginFlags) UnmarshalJSON(data []byte) error {
+	text, err := jsonToText(data, "login flags")
+	if err != nil {
+		return err
+	}
+	return flags.UnmarshalText(text)
+}
+
+// Validate checks that the reserved flag isn't set.
+func (flags LoginFlags) Validate() error {
+	if flags&loginFlagReserved1 != 0 {
+		return errors.Errorf("login flag %#x is reserved for Windows' API internal usage", uint32(loginFlagReserved1))
+	}
+	return nil
+}
+
+func (flags SecurityFlags) String() string {
+	return formatFlags(uint64(flags), securityFlagNames)
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (flags SecurityFlags) MarshalText() ([]byte, error) {
+	return []byte(flags.String()), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (flags *SecurityFlags) UnmarshalText(text []byte) error {
+	value, err := parseFlags(string(text), securityFlagNames, "security flag")
+	if err != nil {
+		return err
+	}
+	*flags = SecurityFlags(value)
+	return nil
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`.
+func (flags *SecurityFlags) UnmarshalJSON(data []byte) error {
+	text, err := jsonToText(data, "security flags")
+	if err != nil {
+		return err
+	}
+	return flags.UnmarshalText(text)
+}
+
+// mutuallyExclusiveSecurityFlags lists the pairs of security flags that can't be set together.
+var mutuallyExclusiveSecurityFlags = [][2]SecurityFlags{
+	{SecurityFlagMainModeEnabled, SecurityFlagAggressiveModeEnabled},
+	{SecurityFlagTransportModePreferred, SecurityFlagTunnelModePreferred},
+}
+
+// Validate checks that no mutually exclusive flags are set together, e.g. transport and tunnel modes.
+func (flags SecurityFlags) Validate() error {
+	for _, pair := range mutuallyExclusiveSecurityFlags {
+		if flags&pair[0] != 0 && flags&pair[1] != 0 {
+			return errors.Errorf("security flags %v and %v are mutually exclusive", pair[0], pair[1])
+		}
+	}
+	return nil
+}
+
+func (authType AuthType) String() string {
+	return formatEnum(uint64(authType), authTypeNames, "AuthType")
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+// Unknown values are rendered as numbers, so that they can be unmarshalled back.
+func (authType AuthType) MarshalText() ([]byte, error) {
+	if authType.Validate() != nil {
+		return []byte(strconv.FormatUint(uint64(authType), 10)), nil
+	}
+	return []byte(authType.String()), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (authType *AuthType) UnmarshalText(text []byte) error {
+	value, err := parseName(string(text), authTypeNames, "auth type")
+	if err != nil {
+		return err
+	}
+	if value > uint64(^AuthType(0)) {
+		return errors.Errorf("auth type out of range: %d", value)
+	}
+	*authType = AuthType(value)
+	return nil
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`.
+func (authType *AuthType) UnmarshalJSON(data []byte) error {
+	text, err := jsonToText(data, "auth type")
+	if err != nil {
+		return err
+	}
+	return authType.UnmarshalText(text)
+}
+
+// Validate checks that the auth type is a known one.
+func (authType AuthType) Validate() error {
+	if authType > MutualCHAPAuthType {
+		return errors.Errorf("unknown auth type %d", uint32(authType))
+	}
+	return nil
+}
+
+func (digestType DigestType) String() string {
+	return formatEnum(uint64(digestType), digestTypeNames, "DigestType")
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+// Unknown values are rendered as numbers, so that they can be unmarshalled back.
+func (digestType DigestType) MarshalText() ([]byte, error) {
+	if digestType.Validate() != nil {
+		return []byte(strconv.FormatUint(uint64(digestType), 10)), nil
+	}
+	return []byte(digestType.String()), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (digestType *DigestType) UnmarshalText(text []byte) error {
+	value, err := parseName(string(text), digestTypeNames, "digest type")
+	if err != nil {
+		return err
+	}
+	if value > uint64(^DigestType(0)) {
+		return errors.Errorf("digest type out of range: %d", value)
+	}
+	*digestType = DigestType(value)
+	return nil
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`.
+func (digestType *DigestType) UnmarshalJSON(data []byte) error {
+	text, err := jsonToText(data, "digest type")
+	if err != nil {
+		return err
+	}
+	return digestType.UnmarshalText(text)
+}
+
+// Validate checks that the digest type is a known one.
+func (digestType DigestType) Validate() error {
+	if digestType > DigestTypeCRC32C {
+		return errors.Errorf("unknown digest type %d", uint32(digestType))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package iscsidsc
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textMarshaler is implemented by pointers to all the types tested below.
+type textMarshaler interface {
+	fmt.Stringer
+	MarshalText() ([]byte, error)
+	UnmarshalText(text []byte) error
+}
+
+func TestFlagsAndEnumsText(t *testing.T) {
+	loginFlags := func(flags LoginFlags) *LoginFlags { return &flags }
+	securityFlags := func(flags SecurityFlags) *SecurityFlags { return &flags }
+	authType := func(a AuthType) *AuthType { return &a }
+	digestType := func(d DigestType) *DigestType { return &d }
+
+	for _, testCase := range []struct {
+		value        textMarshaler
+		expectedText string
+		// expectedString defaults to expectedText
+		expectedString string
+		// newValue returns a zero value of the same type
+		newValue func() textMarshaler
+	}{
+		{loginFlags(0), "none", "", func() textMarshaler { return loginFlags(0) }},
+		{loginFlags(LoginFlagRequireIPSec | LoginFlagMultipathEnabled), "ipsec|multipath", "", func() textMarshaler { return loginFlags(0) }},
+		{loginFlags(LoginFlagAllowPortalHopping | LoginFlagUseRadiusResponse | LoginFlagUseRadiusVerification),
+			"portal-hopping|radius-response|radius-verification", "", func() textMarshaler { return loginFlags(0) }},
+		{loginFlags(LoginFlagMultipathEnabled | 0x100), "multipath|0x100", "", func() textMarshaler { return loginFlags(0) }},
+		{securityFlags(0), "none", "", func() textMarshaler { return securityFlags(0) }},
+		{securityFlags(SecurityFlagIkeIpsecEnabled | SecurityFlagMainModeEnabled | SecurityFlagPfsEnabled | SecurityFlagTunnelModePreferred),
+			"ike-ipsec|main-mode|pfs|tunnel-mode", "", func() textMarshaler { return securityFlags(0) }},
+		{securityFlags(SecurityFlagAggressiveModeEnabled | SecurityFlagTransportModePreferred),
+			"aggressive-mode|transport-mode", "", func() textMarshaler { return securityFlags(0) }},
+		{authType(NoAuthAuthType), "none", "", func() textMarshaler { return authType(0) }},
+		{authType(CHAPAuthType), "chap", "", func() textMarshaler { return authType(0) }},
+		{authType(MutualCHAPAuthType), "mutual-chap", "", func() textMarshaler { return authType(0) }},
+		{authType(12), "12", "AuthType(12)", func() textMarshaler { return authType(0) }},
+		{digestType(DigestTypeNone), "none", "", func() textMarshaler { return digestType(0) }},
+		{digestType(DigestTypeCRC32C), "crc32c", "", func() textMarshaler { return digestType(0) }},
+		{digestType(5), "5", "DigestType(5)", func() textMarshaler { return digestType(0) }},
+	} {
+		t.Run(fmt.Sprintf("%T %s", testCase.value, testCase.expectedText), func(t *testing.T) {
+			expectedString := testCase.expectedString
+			if expectedString == "" {
+				expectedString = testCase.expectedText
+			}
+			assert.Equal(t, expectedString, testCase.value.String())
+
+			text, err := testCase.value.MarshalText()
+			require.Nil(t, err)
+			assert.Equal(t, testCase.expectedText, string(text))
+
+			unmarshalled := testCase.newValue()
+			require.Nil(t, unmarshalled.UnmarshalText(text))
+			assert.Equal(t, testCase.value, unmarshalled)
+
+			jsonBytes, err := json.Marshal(testCase.value)
+			require.Nil(t, err)
+			assert.Equal(t, `"`+testCase.expectedText+`"`, string(jsonBytes))
+
+			unmarshalled = testCase.newValue()
+			require.Nil(t, json.Unmarshal(jsonBytes, unmarshalled))
+			assert.Equal(t, testCase.value, unmarshalled)
+		})
+	}
+}
+
+func TestFlagsAndEnumsParsing(t *testing.T) {
+	t.Run("it's lenient with cases and blanks", func(t *testing.T) {
+		var flags SecurityFlags
+		require.Nil(t, flags.UnmarshalText([]byte(" IKE-IPsec | Transport-Mode ")))
+		assert.Equal(t, SecurityFlagIkeIpsecEnabled|SecurityFlagTransportModePreferred, flags)
+
+		var authType AuthType
+		require.Nil(t, authType.UnmarshalText([]byte("CHAP")))
+		assert.Equal(t, CHAPAuthType, authType)
+	})
+
+	t.Run("empty flag sets", func(t *testing.T) {
+		flags := LoginFlagMultipathEnabled
+		require.Nil(t, flags.UnmarshalText([]byte("")))
+		assert.Equal(t, LoginFlags(0), flags)
+	})
+
+	t.Run("it accepts numbers, including in JSON", func(t *testing.T) {
+		var opts struct {
+			LoginFlags    LoginFlags
+			SecurityFlags SecurityFlags
+			AuthType      AuthType
+			DigestType    DigestType
+		}
+		require.Nil(t, json.Unmarshal([]byte(`{"LoginFlags": 3, "SecurityFlags": "0x22", "AuthType": 2, "DigestType": "1"}`), &opts))
+
+		assert.Equal(t, LoginFlagRequireIPSec|LoginFlagMultipathEnabled, opts.LoginFlags)
+		assert.Equal(t, SecurityFlagIkeIpsecEnabled|SecurityFlagTransportModePreferred, opts.SecurityFlags)
+		assert.Equal(t, MutualCHAPAuthType, opts.AuthType)
+		assert.Equal(t, DigestTypeCRC32C, opts.DigestType)
+	})
+
+	t.Run("with unknown names", func(t *testing.T) {
+		var flags LoginFlags
+		err := flags.UnmarshalText([]byte("ipsec|teleport"))
+		if assert.NotNil(t, err) {
+			assert.Equal(t, `unknown login flag: "teleport"`, err.Error())
+		}
+
+		var digestType DigestType
+		err = json.Unmarshal([]byte(`"md5"`), &digestType)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, `unknown digest type: "md5"`, err.Error())
+		}
+	})
+
+	t.Run("with out of range values", func(t *testing.T) {
+		var flags LoginFlags
+		err := flags.UnmarshalText([]byte("0x100000000"))
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "login flags out of range: 0x100000000", err.Error())
+		}
+	})
+
+	t.Run("with invalid JSON types", func(t *testing.T) {
+		var authType AuthType
+		err := json.Unmarshal([]byte(`["chap"]`), &authType)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, `auth type must be either a string or a number, got ["chap"]`, err.Error())
+		}
+	})
+}
+
+func TestFlagsValidate(t *testing.T) {
+	assert.Nil(t, (SecurityFlagIkeIpsecEnabled | SecurityFlagMainModeEnabled | SecurityFlagTunnelModePreferred).Validate())
+
+	err := (SecurityFlagIkeIpsecEnabled | SecurityFlagTransportModePreferred | SecurityFlagTunnelModePreferred).Validate()
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "security flags transport-mode and tunnel-mode are mutually exclusive", err.Error())
+	}
+	err = (SecurityFlagMainModeEnabled | SecurityFlagAggressiveModeEnabled).Validate()
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "security flags main-mode and aggressive-mode are mutually exclusive", err.Error())
+	}
+
+	assert.Nil(t, (LoginFlagRequireIPSec | LoginFlagMultipathEnabled).Validate())
+	err = (LoginFlagMultipathEnabled | loginFlagReserved1).Validate()
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "login flag 0x4 is reserved for Windows' API internal usage", err.Error())
+	}
+
+	assert.Nil(t, MutualCHAPAuthType.Validate())
+	assert.NotNil(t, AuthType(3).Validate())
+	assert.Nil(t, DigestTypeCRC32C.Validate())
+	assert.NotNil(t, DigestType(2).Validate())
+}
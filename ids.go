@@ -0,0 +1,90 @@
+package iscsidsc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file contains the canonical string form of session and connection IDs, the same as Windows tools
+// such as PowerShell's `Get-IscsiSession` or `iscsicli` use, e.g. "ffffe0008ff4b010-4000013700000002":
+// the adapter-unique and adapter-specific parts, in hex, separated by a dash.
+// IDs also marshal to text and JSON using that form.
+
+func formatID(adapterUnique, adapterSpecific uint64) string {
+	return fmt.Sprintf("%016x-%016x", adapterUnique, adapterSpecific)
+}
+
+// parseID parses the output of `formatID`, case-insensitively; leading zeroes can be omitted.
+func parseID(s, idType string) (adapterUnique, adapterSpecific uint64, err error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 2 {
+		return 0, 0, errors.Errorf("invalid %s %q: expected two hex numbers separated by a dash", idType, s)
+	}
+
+	for i, target := range []*uint64{&adapterUnique, &adapterSpecific} {
+		part := parts[i]
+		if part == "" || len(part) > 16 || strings.HasPrefix(part, "+") {
+			return 0, 0, errors.Errorf("invalid %s %q: %q is not a 64-bit hex number", idType, s, part)
+		}
+		if *target, err = strconv.ParseUint(part, 16, 64); err != nil {
+			return 0, 0, errors.Errorf("invalid %s %q: %q is not a 64-bit hex number", idType, s, part)
+		}
+	}
+
+	return
+}
+
+func (id SessionID) String() string {
+	return formatID(id.AdapterUnique, id.AdapterSpecific)
+}
+
+// ParseSessionID parses a session ID in its canonical form, e.g. "ffffe0008ff4b010-4000013700000002".
+func ParseSessionID(s string) (SessionID, error) {
+	adapterUnique, adapterSpecific, err := parseID(s, "session ID")
+	return SessionID{AdapterUnique: adapterUnique, AdapterSpecific: adapterSpecific}, err
+}
+
+// IsZero returns true iff this is the zero session ID, i.e. it doesn't identify any session.
+func (id SessionID) IsZero() bool {
+	return id == SessionID{}
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (id SessionID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (id *SessionID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseSessionID(string(text))
+	return
+}
+
+func (id ConnectionID) String() string {
+	return formatID(id.AdapterUnique, id.AdapterSpecific)
+}
+
+// ParseConnectionID parses a connection ID in its canonical form, e.g. "ffffe0008ff4b010-2000000000000003".
+func ParseConnectionID(s string) (ConnectionID, error) {
+	adapterUnique, adapterSpecific, err := parseID(s, "connection ID")
+	return ConnectionID{AdapterUnique: adapterUnique, AdapterSpecific: adapterSpecific}, err
+}
+
+// IsZero returns true iff this is the zero connection ID, i.e. it doesn't identify any connection.
+func (id ConnectionID) IsZero() bool {
+	return id == ConnectionID{}
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (id ConnectionID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (id *ConnectionID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseConnectionID(string(text))
+	return
+}
@@ -0,0 +1,113 @@
+package iscsidsc
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionIDString(t *testing.T) {
+	for _, testCase := range []struct {
+		id             SessionID
+		expectedString string
+	}{
+		// as printed by `Get-IscsiSession`
+		{SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002}, "ffffe0008ff4b010-4000013700000002"},
+		{SessionID{AdapterUnique: 0xffffa50c1e5e3010, AdapterSpecific: 0x400001370000000b}, "ffffa50c1e5e3010-400001370000000b"},
+		{SessionID{AdapterUnique: 1, AdapterSpecific: 2}, "0000000000000001-0000000000000002"},
+		{SessionID{}, "0000000000000000-0000000000000000"},
+	} {
+		t.Run(testCase.expectedString, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedString, testCase.id.String())
+			assert.Equal(t, testCase.expectedString, fmt.Sprintf("%v", &testCase.id))
+
+			parsed, err := ParseSessionID(testCase.expectedString)
+			require.Nil(t, err)
+			assert.Equal(t, testCase.id, parsed)
+
+			jsonBytes, err := json.Marshal(testCase.id)
+			require.Nil(t, err)
+			assert.Equal(t, `"`+testCase.expectedString+`"`, string(jsonBytes))
+
+			var unmarshalled SessionID
+			require.Nil(t, json.Unmarshal(jsonBytes, &unmarshalled))
+			assert.Equal(t, testCase.id, unmarshalled)
+		})
+	}
+}
+
+func TestConnectionIDString(t *testing.T) {
+	id := ConnectionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x2000000000000003}
+	const expected = "ffffe0008ff4b010-2000000000000003"
+
+	assert.Equal(t, expected, id.String())
+
+	parsed, err := ParseConnectionID(expected)
+	require.Nil(t, err)
+	assert.Equal(t, id, parsed)
+
+	text, err := id.MarshalText()
+	require.Nil(t, err)
+	var unmarshalled ConnectionID
+	require.Nil(t, unmarshalled.UnmarshalText(text))
+	assert.Equal(t, id, unmarshalled)
+
+	t.Run("in a session info", func(t *testing.T) {
+		info := SessionInfo{
+			SessionID:   SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002},
+			Connections: []ConnectionInfo{{ConnectionID: id}},
+		}
+
+		jsonBytes, err := json.Marshal(info)
+		require.Nil(t, err)
+		assert.Contains(t, string(jsonBytes), `"SessionID":"ffffe0008ff4b010-4000013700000002"`)
+		assert.Contains(t, string(jsonBytes), `"ConnectionID":"ffffe0008ff4b010-2000000000000003"`)
+
+		var unmarshalled SessionInfo
+		require.Nil(t, json.Unmarshal(jsonBytes, &unmarshalled))
+		assert.Equal(t, info, unmarshalled)
+	})
+}
+
+func TestParseID(t *testing.T) {
+	t.Run("it's lenient with cases, blanks and leading zeroes", func(t *testing.T) {
+		id, err := ParseSessionID(" FFFFE0008FF4B010-1 ")
+		require.Nil(t, err)
+		assert.Equal(t, SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 1}, id)
+	})
+
+	for _, input := range []string{
+		"",
+		"ffffe0008ff4b010",
+		"ffffe0008ff4b010-",
+		"-4000013700000002",
+		"ffffe0008ff4b010-4000013700000002-1",
+		"ffffe0008ff4b010-40000137000000021",
+		"ffffe0008ff4b010-400001370000000g",
+		"0xffffe0008ff4b0-4000013700000002",
+		"ffffe0008ff4b010-+400001370000002",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseSessionID(input)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), fmt.Sprintf("invalid session ID %q", input))
+			}
+
+			var id ConnectionID
+			err = json.Unmarshal([]byte(fmt.Sprintf("%q", input)), &id)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), fmt.Sprintf("invalid connection ID %q", input))
+			}
+		})
+	}
+}
+
+func TestIDsIsZero(t *testing.T) {
+	assert.True(t, SessionID{}.IsZero())
+	assert.False(t, SessionID{AdapterSpecific: 1}.IsZero())
+	assert.True(t, ConnectionID{}.IsZero())
+	assert.False(t, ConnectionID{AdapterUnique: 1}.IsZero())
+}
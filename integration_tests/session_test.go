@@ -1,15 +1,19 @@
+//go:build windows
+// +build windows
+
 package integrationtests
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	iscsidsc "github.com/wk8/go-win-iscsidsc"
 	"github.com/wk8/go-win-iscsidsc/session"
-	"gotest.tools/poll"
 )
 
 func TestGetIScsiSessionList(t *testing.T) {
@@ -94,7 +98,7 @@ func TestAddConnection(t *testing.T) {
 			assertSessionConnectionsEqual(t, s, portal.Address, connectionID, newConnectionID)
 		} else {
 			require.Nil(t, newConnectionID)
-			assertWinAPIErrorCode(t, err, "0xEFFF000E")
+			assertWinAPIErrorCode(t, err, iscsidsc.ErrTooManyConnections)
 		}
 	})
 
@@ -117,7 +121,7 @@ func TestAddConnection(t *testing.T) {
 		// https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers
 		newConnectionID, err := session.AddIScsiConnection(*sessionID, nil, portal, nil, nil, nil)
 		assert.Nil(t, newConnectionID)
-		assertWinAPIErrorCode(t, err, "0xEFFF001C")
+		assertWinAPIErrorCode(t, err, iscsidsc.ErrSessionNotFound)
 	})
 
 	t.Run("it errors out if passed a nil portal", func(t *testing.T) {
@@ -174,18 +178,9 @@ func TestGetDevices(t *testing.T) {
 
 				// now let's get the devices for our session; sadly, the Windows API can sometimes
 				// take a little longer to actually start reporting the devices...
-				var devices []iscsidsc.Device
-				poll.WaitOn(t, func(t poll.LogT) poll.Result {
-					devices, err = session.GetDevicesForIScsiSession(*sessionID)
-
-					if err != nil {
-						return poll.Error(err)
-					}
-					if len(devices) == diskCount {
-						return poll.Success()
-					}
-					return poll.Continue("waiting to have %d devices, currently %d", diskCount, len(devices))
-				})
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				devices, err := session.WaitForDevices(ctx, *sessionID, session.LUNCount(diskCount))
 				require.Nil(t, err)
 				require.Equal(t, diskCount, len(devices))
 
@@ -224,7 +219,7 @@ func TestGetDevices(t *testing.T) {
 		// https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers
 		devices, err := session.GetDevicesForIScsiSession(*sessionID)
 		assert.Nil(t, devices)
-		assertWinAPIErrorCode(t, err, "0xEFFF001C")
+		assertWinAPIErrorCode(t, err, iscsidsc.ErrSessionNotFound)
 	})
 }
 
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package integrationtests
 
 import (
@@ -5,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
 	"github.com/wk8/go-win-iscsidsc/target"
 )
 
@@ -78,7 +82,7 @@ func TestLoginLogout(t *testing.T) {
 	// trying to log in a second time should yield a ISDSC_TARGET_ALREADY_LOGGED_IN (0xEFFF003F) error
 	// see https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers
 	sessionID2, connectionID2, err := logIntoTargetWithDefaultArgs(targetIqn)
-	assertWinAPIErrorCode(t, err, "0xEFFF003F")
+	assertWinAPIErrorCode(t, err, iscsidsc.ErrTargetAlreadyLoggedIn)
 	assert.Nil(t, sessionID2)
 	assert.Nil(t, connectionID2)
 
@@ -88,7 +92,7 @@ func TestLoginLogout(t *testing.T) {
 
 	// trying to log out a second time should yield a ISDSC_INVALID_SESSION_ID (0xEFFF001C) error
 	err = target.LogoutIScsiTarget(*sessionID1)
-	assertWinAPIErrorCode(t, err, "0xEFFF001C")
+	assertWinAPIErrorCode(t, err, iscsidsc.ErrSessionNotFound)
 
 	// now we should be able to log in again
 	sessionID3, connectionID3, err := logIntoTargetWithDefaultArgs(targetIqn)
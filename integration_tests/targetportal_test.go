@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package integrationtests
 
 import (
@@ -100,7 +103,7 @@ func TestAddUnresponsiveTargetPortal(t *testing.T) {
 	err := targetportal.AddIScsiSendTargetPortal(nil, nil, nil, nil, portal)
 	// see https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers
 	// 0xEFFF0003 is a connection failure
-	assertWinAPIErrorCode(t, err, "0xEFFF0003")
+	assertWinAPIErrorCode(t, err, iscsidsc.ErrConnectionFailed)
 
 	// cleanup
 	portalCleaner.assertCleanupSuccessful(t)
@@ -112,12 +115,10 @@ func TestAddTargetPortalWithDiscoveryCHAPAuthentication(t *testing.T) {
 	portal.SymbolicName = "test-portal-with-chap-authentication"
 
 	authType := iscsidsc.CHAPAuthType
-	chapUser := "username"
-	chapPassword := "passwordpassword"
 	loginOptions := &iscsidsc.LoginOptions{
 		AuthType: &authType,
-		Username: &chapUser,
-		Password: &chapPassword,
+		Username: iscsidsc.NewSecretFromString("username"),
+		Password: iscsidsc.NewSecretFromString("passwordpassword"),
 	}
 
 	portalCleaner := newTargetPortalCleaner(portal)
@@ -127,7 +128,7 @@ func TestAddTargetPortalWithDiscoveryCHAPAuthentication(t *testing.T) {
 	err := targetportal.AddIScsiSendTargetPortal(nil, nil, loginOptions, nil, portal)
 	// see https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers
 	// 0xEFFF0009 is an authentication failure
-	assertWinAPIErrorCode(t, err, "0xEFFF0009")
+	assertWinAPIErrorCode(t, err, iscsidsc.ErrLoginAuthFailed)
 
 	// it should show up anyway when listing all target portals
 	portalInfo := findPortal(t, portal, len(existingTargets)+1)
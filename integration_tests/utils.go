@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package integrationtests
 
 import (
@@ -17,7 +20,6 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	iscsidsc "github.com/wk8/go-win-iscsidsc"
-	"github.com/wk8/go-win-iscsidsc/internal"
 	"github.com/wk8/go-win-iscsidsc/target"
 	"github.com/wk8/go-win-iscsidsc/targetportal"
 )
@@ -219,13 +221,12 @@ func assertStringInSlice(t *testing.T, needle string, slice []string) {
 	require.Fail(t, "assertStringInSlice failed", "%q not found in %v", needle, slice)
 }
 
-// setSmallInitialAPIBufferSize changes the value of internal.InitialAPIBufferSize to 1,
-// and returns a func to revert that change when done with testing.
+// setSmallInitialAPIBufferSize replaces the default client with one using an initial API buffer
+// size of 1, and returns a func to revert that change when done with testing.
 func setSmallInitialAPIBufferSize() func() {
-	previousBufferSize := internal.InitialAPIBufferSize
-	internal.InitialAPIBufferSize = 1
+	previousClient := iscsidsc.SetDefaultClient(iscsidsc.NewClient(iscsidsc.WithInitialBufferSize(1)))
 	return func() {
-		internal.InitialAPIBufferSize = previousBufferSize
+		iscsidsc.SetDefaultClient(previousClient)
 	}
 }
 
@@ -259,12 +260,12 @@ func readFile(t *testing.T, path string) string {
 	return string(contents)
 }
 
-func assertWinAPIErrorCode(t *testing.T, err error, expectedErrorCode string) bool {
+func assertWinAPIErrorCode(t *testing.T, err error, expectedErrorCode iscsidsc.ErrorCode) bool {
 	if !assert.NotNil(t, err) {
 		return false
 	}
 	if winAPIErr, ok := err.(*iscsidsc.WinAPICallError); assert.True(t, ok) {
-		return assert.Equal(t, expectedErrorCode, winAPIErr.HexCode())
+		return assert.True(t, winAPIErr.Is(expectedErrorCode), "expected error code %v, got %v", expectedErrorCode, winAPIErr.Code())
 	}
 	return false
 }
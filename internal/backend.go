@@ -0,0 +1,9 @@
+package internal
+
+import (
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func init() {
+	iscsidsc.RegisterDefaultBackendFactory(newBackend)
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package internal
+
+import (
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// unsupportedBackend is the default backend on platforms other than Windows: all of its
+// operations return `iscsidsc.ErrNotSupported`.
+type unsupportedBackend struct{}
+
+func newBackend(config iscsidsc.BackendConfig) iscsidsc.Backend {
+	return unsupportedBackend{}
+}
+
+func (unsupportedBackend) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	return nil, iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	return nil, nil, iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) LogoutIScsiTarget(sessionID iscsidsc.SessionID) error {
+	return iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions, securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) error {
+	return iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	return nil, iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) RemoveIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, portal *iscsidsc.Portal) error {
+	return iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) AddIScsiConnection(id iscsidsc.SessionID, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret) (*iscsidsc.ConnectionID, error) {
+	return nil, iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	return nil, iscsidsc.ErrNotSupported
+}
+
+func (unsupportedBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return nil, iscsidsc.ErrNotSupported
+}
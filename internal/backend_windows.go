@@ -0,0 +1,308 @@
+package internal
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// windowsBackend is the default backend on Windows, that makes calls to Windows' API.
+type windowsBackend struct {
+	api *winAPI
+
+	procReportIScsiTargetsW             *windows.LazyProc
+	procLoginIScsiTargetW               *windows.LazyProc
+	procLogoutIScsiTarget               *windows.LazyProc
+	procAddIScsiSendTargetPortalW       *windows.LazyProc
+	procReportIScsiSendTargetPortalsExW *windows.LazyProc
+	procRemoveIScsiSendTargetPortalW    *windows.LazyProc
+	procAddIScsiConnectionW             *windows.LazyProc
+	procGetDevicesForIScsiSessionW      *windows.LazyProc
+	procGetIScsiSessionListW            *windows.LazyProc
+}
+
+func newBackend(config iscsidsc.BackendConfig) iscsidsc.Backend {
+	api := newWinAPI(config)
+
+	return &windowsBackend{
+		api: api,
+
+		procReportIScsiTargetsW:             api.proc(reportIScsiTargetsProcName),
+		procLoginIScsiTargetW:               api.proc("LoginIScsiTargetW"),
+		procLogoutIScsiTarget:               api.proc("LogoutIScsiTarget"),
+		procAddIScsiSendTargetPortalW:       api.proc("AddIScsiSendTargetPortalW"),
+		procReportIScsiSendTargetPortalsExW: api.proc(reportIScsiSendTargetPortalsExProcName),
+		procRemoveIScsiSendTargetPortalW:    api.proc("RemoveIScsiSendTargetPortalW"),
+		procAddIScsiConnectionW:             api.proc("AddIScsiConnectionW"),
+		procGetDevicesForIScsiSessionW:      api.proc(getDevicesForIScsiSessionProcName),
+		procGetIScsiSessionListW:            api.proc(getIScsiSessionListProcName),
+	}
+}
+
+// ReportIScsiTargets implements `iscsidsc.Backend`.
+func (w *windowsBackend) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	buffer, err := w.retrieveIscsiTargets(forceUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseIscsiTargets(buffer)
+}
+
+// retrieveIscsiTargets gets the raw target list from the Windows API.
+func (w *windowsBackend) retrieveIscsiTargets(forceUpdate bool) (buffer []byte, err error) {
+	buffer, _, _, err = w.api.handleBufferedCall(
+		func(s, _, b uintptr) (uintptr, error) {
+			return w.api.call(w.procReportIScsiTargetsW,
+				uintptr(BoolToByte(forceUpdate)),
+				s,
+				b)
+		},
+		w.procReportIScsiTargetsW.Name,
+		2,
+	)
+	return
+}
+
+// LoginIscsiTarget implements `iscsidsc.Backend`.
+func (w *windowsBackend) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	args, wipe, err := ConvertLoginIscsiTargetArgs(targetName, isInformationalSession, initiatorInstance, initiatorPortNumber, targetPortal,
+		securityFlags, loginOptions, key, isPersistent)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer wipe()
+
+	return w.callProcLoginIScsiTargetW(args.TargetName, args.IsInformationalSession, args.InitiatorInstance, args.InitiatorPortNumber,
+		args.Portal, args.SecurityFlags, args.LoginOptions, uintptr(unsafe.Pointer(args.UserName)), uintptr(unsafe.Pointer(args.Password)),
+		args.Key, args.KeySize, args.IsPersistent)
+}
+
+//go:uintptrescapes
+//go:noinline
+
+func (w *windowsBackend) callProcLoginIScsiTargetW(targetNamePtr *uint16, isInformationalSession bool, initiatorInstancePtr *uint16, initiatorPortNumberValue uint32,
+	internalPortal *Portal, securityFlagsValue iscsidsc.SecurityFlags, internalLoginOptions *LoginOptions,
+	userNameUintptr, passwordUintptr uintptr, keyPtr *byte, keySize uint32, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+
+	internalLoginOptions.Username = userNameUintptr
+	internalLoginOptions.Password = passwordUintptr
+
+	sessionID := &iscsidsc.SessionID{}
+	connectionID := &iscsidsc.ConnectionID{}
+
+	if _, err := w.api.call(w.procLoginIScsiTargetW,
+		uintptr(unsafe.Pointer(targetNamePtr)),
+		uintptr(BoolToByte(isInformationalSession)),
+		uintptr(unsafe.Pointer(initiatorInstancePtr)),
+		uintptr(initiatorPortNumberValue),
+		uintptr(unsafe.Pointer(internalPortal)),
+		uintptr(securityFlagsValue),
+		0, // we don't support mappings yet
+		uintptr(unsafe.Pointer(internalLoginOptions)),
+		uintptr(keySize),
+		uintptr(unsafe.Pointer(keyPtr)),
+		uintptr(BoolToByte(isPersistent)),
+		uintptr(unsafe.Pointer(sessionID)),
+		uintptr(unsafe.Pointer(connectionID)),
+	); err != nil {
+		return nil, nil, err
+	}
+
+	return sessionID, connectionID, nil
+}
+
+// LogoutIScsiTarget implements `iscsidsc.Backend`.
+func (w *windowsBackend) LogoutIScsiTarget(sessionID iscsidsc.SessionID) error {
+	_, err := w.api.call(w.procLogoutIScsiTarget, uintptr(unsafe.Pointer(&sessionID)))
+	return err
+}
+
+// AddIScsiSendTargetPortal implements `iscsidsc.Backend`.
+func (w *windowsBackend) AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions, securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) error {
+	args, wipe, err := ConvertAddIScsiSendTargetPortalArgs(initiatorInstance, initiatorPortNumber, loginOptions, securityFlags, portal)
+	if err != nil {
+		return err
+	}
+	defer wipe()
+
+	_, err = w.callProcAddIScsiSendTargetPortalW(
+		args.InitiatorInstance,
+		args.InitiatorPortNumber,
+		args.LoginOptions,
+		args.SecurityFlags,
+		args.Portal,
+		uintptr(unsafe.Pointer(args.UserName)),
+		uintptr(unsafe.Pointer(args.Password)),
+	)
+
+	return err
+}
+
+//go:uintptrescapes
+//go:noinline
+
+// callProcAddIScsiSendTargetPortalW is only a wrapper around `winAPI.call`.
+// Its main purpose is that the unsafe pointers to the username and password strings are
+// guaranteed to stay in the same place in memory until this function returns.
+// See `CheckAndConvertLoginOptions`'s doc comment as well as https://golang.org/pkg/unsafe/#Pointer
+// for more context.
+func (w *windowsBackend) callProcAddIScsiSendTargetPortalW(initiatorInstancePtr *uint16, initiatorPortNumberValue uint32,
+	internalLoginOptions *LoginOptions, securityFlagsValue iscsidsc.SecurityFlags, internalPortal *Portal,
+	userNameUintptr, passwordUintptr uintptr) (uintptr, error) {
+
+	internalLoginOptions.Username = userNameUintptr
+	internalLoginOptions.Password = passwordUintptr
+
+	return w.api.call(w.procAddIScsiSendTargetPortalW,
+		uintptr(unsafe.Pointer(initiatorInstancePtr)),
+		uintptr(initiatorPortNumberValue),
+		uintptr(unsafe.Pointer(internalLoginOptions)),
+		uintptr(securityFlagsValue),
+		uintptr(unsafe.Pointer(internalPortal)),
+	)
+}
+
+// ReportIScsiSendTargetPortals implements `iscsidsc.Backend`.
+func (w *windowsBackend) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	buffer, bufferPointer, count, err := w.retrievePortalInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	portalInfos, bytesRead, err := hydrateTargetPortalInfos(buffer, bufferPointer, int(count), NativeLayout)
+	if err != nil {
+		return nil, err
+	}
+	if bytesRead != uintptr(len(buffer)) {
+		return nil, hydrateTargetPortalError("reply was %d bytes long, read %d bytes", len(buffer), bytesRead)
+	}
+
+	return portalInfos, nil
+}
+
+// retrievePortalInfos gets the raw portal infos from the Windows API.
+func (w *windowsBackend) retrievePortalInfos() (buffer []byte, bufferPointer uintptr, count int32, err error) {
+	return w.api.handleBufferedCall(
+		func(s, c, b uintptr) (uintptr, error) {
+			return w.api.call(w.procReportIScsiSendTargetPortalsExW, c, s, b)
+		},
+		w.procReportIScsiSendTargetPortalsExW.Name,
+		1,
+	)
+}
+
+// RemoveIScsiSendTargetPortal implements `iscsidsc.Backend`.
+func (w *windowsBackend) RemoveIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, portal *iscsidsc.Portal) error {
+	args, err := ConvertRemoveIScsiSendTargetPortalArgs(initiatorInstance, initiatorPortNumber, portal)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.api.call(w.procRemoveIScsiSendTargetPortalW,
+		uintptr(unsafe.Pointer(args.InitiatorInstance)),
+		uintptr(args.InitiatorPortNumber),
+		uintptr(unsafe.Pointer(args.Portal)),
+	)
+
+	return err
+}
+
+// AddIScsiConnection implements `iscsidsc.Backend`.
+func (w *windowsBackend) AddIScsiConnection(id iscsidsc.SessionID, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret) (*iscsidsc.ConnectionID, error) {
+	args, wipe, err := ConvertAddIScsiConnectionArgs(id, initiatorPortNumber, targetPortal, securityFlags, loginOptions, key)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe()
+
+	return w.callProcAddIScsiConnectionW(args.SessionID, args.InitiatorPortNumber, args.Portal, args.SecurityFlags,
+		args.LoginOptions, uintptr(unsafe.Pointer(args.UserName)), uintptr(unsafe.Pointer(args.Password)),
+		args.Key, args.KeySize)
+}
+
+//go:uintptrescapes
+//go:noinline
+
+func (w *windowsBackend) callProcAddIScsiConnectionW(id iscsidsc.SessionID, initiatorPortNumberValue uint32, internalPortal *Portal,
+	securityFlagsValue iscsidsc.SecurityFlags, internalLoginOptions *LoginOptions,
+	userNameUintptr, passwordUintptr uintptr, keyPtr *byte, keySize uint32) (*iscsidsc.ConnectionID, error) {
+
+	internalLoginOptions.Username = userNameUintptr
+	internalLoginOptions.Password = passwordUintptr
+
+	connectionID := &iscsidsc.ConnectionID{}
+
+	if _, err := w.api.call(w.procAddIScsiConnectionW,
+		uintptr(unsafe.Pointer(&id)),
+		0, // reserved pointer argument, must be null on input
+		uintptr(initiatorPortNumberValue),
+		uintptr(unsafe.Pointer(internalPortal)),
+		uintptr(securityFlagsValue),
+		uintptr(unsafe.Pointer(internalLoginOptions)),
+		uintptr(keySize),
+		uintptr(unsafe.Pointer(keyPtr)),
+		uintptr(unsafe.Pointer(connectionID)),
+	); err != nil {
+		return nil, err
+	}
+
+	return connectionID, nil
+}
+
+// GetDevicesForIScsiSession implements `iscsidsc.Backend`.
+func (w *windowsBackend) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	buffer, _, _, err := w.retrieveDevices(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return hydrateDevices(buffer, NativeLayout)
+}
+
+// retrieveDevices gets the raw devices' infos from the Windows API.
+func (w *windowsBackend) retrieveDevices(id iscsidsc.SessionID) (buffer []byte, bufferPointer uintptr, count int32, err error) {
+	return w.api.handleBufferedCall(
+		func(s, _, b uintptr) (uintptr, error) {
+			return w.api.call(w.procGetDevicesForIScsiSessionW,
+				uintptr(unsafe.Pointer(&id)),
+				s,
+				b)
+		},
+		w.procGetDevicesForIScsiSessionW.Name,
+		NativeLayout.Device.Size,
+	)
+}
+
+// GetIScsiSessionList implements `iscsidsc.Backend`.
+func (w *windowsBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	buffer, bufferPointer, count, err := w.retrieveSessionInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	// this really baffles me, but it seems that on some Windows versions GetIScsiSessionListW returns
+	// a buffer size that's actually quite bigger than the space it actually uses... so here we can't check
+	// that we've used all of the declared buffer size, sadly.
+	sessionInfos, _, err := hydrateSessionInfos(buffer, bufferPointer, int(count), NativeLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionInfos, nil
+}
+
+// retrieveSessionInfos gets the raw session infos from the Windows API.
+func (w *windowsBackend) retrieveSessionInfos() (buffer []byte, bufferPointer uintptr, count int32, err error) {
+	return w.api.handleBufferedCall(
+		func(s, c, b uintptr) (uintptr, error) {
+			return w.api.call(w.procGetIScsiSessionListW, s, c, b)
+		},
+		w.procGetIScsiSessionListW.Name,
+		1,
+	)
+}
@@ -0,0 +1,181 @@
+package internal
+
+// This file contains the argument checks and conversions of the mutating calls, shared by the Windows
+// backend and the dry-run one.
+
+import (
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// LoginIscsiTargetArgs are the converted arguments of `LoginIscsiTarget`, as Windows' API expects them.
+type LoginIscsiTargetArgs struct {
+	TargetName             *uint16
+	IsInformationalSession bool
+	InitiatorInstance      *uint16
+	InitiatorPortNumber    uint32
+	// nil if no portal was given
+	Portal        *Portal
+	SecurityFlags iscsidsc.SecurityFlags
+	LoginOptions  *LoginOptions
+	UserName      *byte
+	Password      *byte
+	Key           *byte
+	KeySize       uint32
+	IsPersistent  bool
+}
+
+// ConvertLoginIscsiTargetArgs checks and converts `LoginIscsiTarget`'s arguments.
+// The returned function wipes the copies of the credentials and key, and should be called once
+// the syscall has returned; on errors, there's nothing to wipe.
+func ConvertLoginIscsiTargetArgs(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*LoginIscsiTargetArgs, func(), error) {
+	args := &LoginIscsiTargetArgs{
+		IsInformationalSession: isInformationalSession,
+		SecurityFlags:          convertSecurityFlags(securityFlags),
+		IsPersistent:           isPersistent,
+	}
+
+	var err error
+	if args.TargetName, err = UTF16PtrFromString(targetName); err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid target name: %q", targetName)
+	}
+	if args.InitiatorInstance, args.InitiatorPortNumber, err = ConvertInitiatorArgs(initiatorInstance, initiatorPortNumber); err != nil {
+		return nil, nil, err
+	}
+	if args.Portal, err = CheckAndConvertPortal(targetPortal); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid portal argument")
+	}
+	if args.LoginOptions, args.UserName, args.Password, err = CheckAndConvertLoginOptions(loginOptions); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid loginOptions argument")
+	}
+	if args.Key, args.KeySize, err = CheckAndConvertKey(key); err != nil {
+		WipeLoginOptions(args.LoginOptions, args.UserName, args.Password)
+		return nil, nil, err
+	}
+
+	return args, func() {
+		WipeLoginOptions(args.LoginOptions, args.UserName, args.Password)
+		WipeKey(args.Key, args.KeySize)
+	}, nil
+}
+
+// AddIScsiSendTargetPortalArgs are the converted arguments of `AddIScsiSendTargetPortal`, as Windows' API
+// expects them.
+type AddIScsiSendTargetPortalArgs struct {
+	InitiatorInstance   *uint16
+	InitiatorPortNumber uint32
+	LoginOptions        *LoginOptions
+	UserName            *byte
+	Password            *byte
+	SecurityFlags       iscsidsc.SecurityFlags
+	Portal              *Portal
+}
+
+// ConvertAddIScsiSendTargetPortalArgs checks and converts `AddIScsiSendTargetPortal`'s arguments.
+// The returned function wipes the copies of the credentials, and should be called once the syscall
+// has returned; on errors, there's nothing to wipe.
+func ConvertAddIScsiSendTargetPortalArgs(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions,
+	securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) (*AddIScsiSendTargetPortalArgs, func(), error) {
+	args := &AddIScsiSendTargetPortalArgs{SecurityFlags: convertSecurityFlags(securityFlags)}
+
+	var err error
+	if args.InitiatorInstance, args.InitiatorPortNumber, err = ConvertInitiatorArgs(initiatorInstance, initiatorPortNumber); err != nil {
+		return nil, nil, err
+	}
+	if args.LoginOptions, args.UserName, args.Password, err = CheckAndConvertLoginOptions(loginOptions); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid loginOptions argument")
+	}
+	wipe := func() {
+		WipeLoginOptions(args.LoginOptions, args.UserName, args.Password)
+	}
+
+	if portal == nil {
+		wipe()
+		return nil, nil, errors.Errorf("portal is required")
+	}
+	if args.Portal, err = CheckAndConvertPortal(portal); err != nil {
+		wipe()
+		return nil, nil, errors.Wrap(err, "invalid portal argument")
+	}
+
+	return args, wipe, nil
+}
+
+// RemoveIScsiSendTargetPortalArgs are the converted arguments of `RemoveIScsiSendTargetPortal`, as
+// Windows' API expects them.
+type RemoveIScsiSendTargetPortalArgs struct {
+	InitiatorInstance   *uint16
+	InitiatorPortNumber uint32
+	Portal              *Portal
+}
+
+// ConvertRemoveIScsiSendTargetPortalArgs checks and converts `RemoveIScsiSendTargetPortal`'s arguments.
+func ConvertRemoveIScsiSendTargetPortalArgs(initiatorInstance *string, initiatorPortNumber *uint32, portal *iscsidsc.Portal) (*RemoveIScsiSendTargetPortalArgs, error) {
+	args := &RemoveIScsiSendTargetPortalArgs{}
+
+	var err error
+	if args.InitiatorInstance, args.InitiatorPortNumber, err = ConvertInitiatorArgs(initiatorInstance, initiatorPortNumber); err != nil {
+		return nil, err
+	}
+	if portal == nil {
+		return nil, errors.Errorf("portal is required")
+	}
+	if args.Portal, err = CheckAndConvertPortal(portal); err != nil {
+		return nil, errors.Wrap(err, "invalid portal argument")
+	}
+
+	return args, nil
+}
+
+// AddIScsiConnectionArgs are the converted arguments of `AddIScsiConnection`, as Windows' API expects them.
+type AddIScsiConnectionArgs struct {
+	SessionID           iscsidsc.SessionID
+	InitiatorPortNumber uint32
+	Portal              *Portal
+	SecurityFlags       iscsidsc.SecurityFlags
+	LoginOptions        *LoginOptions
+	UserName            *byte
+	Password            *byte
+	Key                 *byte
+	KeySize             uint32
+}
+
+// ConvertAddIScsiConnectionArgs checks and converts `AddIScsiConnection`'s arguments.
+// The returned function wipes the copies of the credentials and key, and should be called once
+// the syscall has returned; on errors, there's nothing to wipe.
+func ConvertAddIScsiConnectionArgs(id iscsidsc.SessionID, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret) (*AddIScsiConnectionArgs, func(), error) {
+	args := &AddIScsiConnectionArgs{
+		SessionID:           id,
+		InitiatorPortNumber: ConvertInitiatorPortNumber(initiatorPortNumber),
+		SecurityFlags:       convertSecurityFlags(securityFlags),
+	}
+
+	var err error
+	if targetPortal == nil {
+		return nil, nil, errors.Errorf("targetPortal is required")
+	}
+	if args.Portal, err = CheckAndConvertPortal(targetPortal); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid targetPortal argument")
+	}
+	if args.LoginOptions, args.UserName, args.Password, err = CheckAndConvertLoginOptions(loginOptions); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid loginOptions argument")
+	}
+	if args.Key, args.KeySize, err = CheckAndConvertKey(key); err != nil {
+		WipeLoginOptions(args.LoginOptions, args.UserName, args.Password)
+		return nil, nil, err
+	}
+
+	return args, func() {
+		WipeLoginOptions(args.LoginOptions, args.UserName, args.Password)
+		WipeKey(args.Key, args.KeySize)
+	}, nil
+}
+
+func convertSecurityFlags(securityFlags *iscsidsc.SecurityFlags) iscsidsc.SecurityFlags {
+	if securityFlags == nil {
+		return 0
+	}
+	return *securityFlags
+}
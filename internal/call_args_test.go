@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestConvertLoginIscsiTargetArgs(t *testing.T) {
+	securityFlags := iscsidsc.SecurityFlagIkeIpsecEnabled
+	initiatorInstance := "ROOT\\ISCSIPRT\\0000_0"
+	key := iscsidsc.NewSecretFromString("key")
+
+	args, wipe, err := ConvertLoginIscsiTargetArgs("iqn.1991-05.com.microsoft:target", true, &initiatorInstance, nil,
+		&iscsidsc.Portal{Address: "[::1]"}, &securityFlags,
+		&iscsidsc.LoginOptions{Username: iscsidsc.NewSecretFromString("username")}, key, true)
+	require.Nil(t, err)
+
+	assert.Equal(t, "iqn.1991-05.com.microsoft:target", utf16PtrToString(args.TargetName))
+	assert.True(t, args.IsInformationalSession)
+	assert.Equal(t, initiatorInstance, utf16PtrToString(args.InitiatorInstance))
+	assert.Equal(t, AllInititatorPorts, args.InitiatorPortNumber)
+	assert.Equal(t, "::1", UTF16ToString(args.Portal.Address[:]))
+	assert.Equal(t, DefaultPortalPortNumber, args.Portal.Socket)
+	assert.Equal(t, securityFlags, args.SecurityFlags)
+	assert.Equal(t, InformationSpecifiedUsername, args.LoginOptions.InformationSpecified)
+	assertIsBytePointerFromSecret(t, args.UserName, iscsidsc.NewSecretFromString("username"))
+	assert.Nil(t, args.Password)
+	assertIsBytePointerFromSecret(t, args.Key, key)
+	assert.Equal(t, uint32(3), args.KeySize)
+	assert.True(t, args.IsPersistent)
+
+	wipe()
+
+	zeroes := func(n int) *iscsidsc.Secret {
+		secret, err := iscsidsc.NewEncodedSecret(make([]byte, n), iscsidsc.CredentialEncodingRaw)
+		require.Nil(t, err)
+		return secret
+	}
+	assertIsBytePointerFromSecret(t, args.UserName, zeroes(8))
+	assertIsBytePointerFromSecret(t, args.Key, zeroes(3))
+	assert.Equal(t, []byte("key"), key.Bytes(), "should not have wiped the caller's key")
+}
+
+func TestConvertArgsErrors(t *testing.T) {
+	portal := &iscsidsc.Portal{Address: "10.0.0.5"}
+	invalidPortal := &iscsidsc.Portal{Address: "10.0.0.5\x00"}
+	invalidLoginOptions := &iscsidsc.LoginOptions{Username: iscsidsc.NewSecretFromString("caf\xe9")}
+	invalidKey := iscsidsc.NewSecretFromString("caf\xe9")
+
+	for _, testCase := range []struct {
+		name          string
+		convert       func() error
+		expectedError string
+	}{
+		{
+			name: "login with an invalid target name",
+			convert: func() error {
+				_, _, err := ConvertLoginIscsiTargetArgs("target\x00", false, nil, nil, nil, nil, nil, nil, false)
+				return err
+			},
+			expectedError: `invalid target name: "target\x00"`,
+		},
+		{
+			name: "login with an invalid portal",
+			convert: func() error {
+				_, _, err := ConvertLoginIscsiTargetArgs("target", false, nil, nil, invalidPortal, nil, nil, nil, false)
+				return err
+			},
+			expectedError: "invalid portal argument: invalid portal address",
+		},
+		{
+			name: "login with an invalid key",
+			convert: func() error {
+				_, _, err := ConvertLoginIscsiTargetArgs("target", false, nil, nil, nil, nil, nil, invalidKey, false)
+				return err
+			},
+			expectedError: "invalid key",
+		},
+		{
+			name: "adding a portal without one",
+			convert: func() error {
+				_, _, err := ConvertAddIScsiSendTargetPortalArgs(nil, nil, nil, nil, nil)
+				return err
+			},
+			expectedError: "portal is required",
+		},
+		{
+			name: "adding a portal with invalid login options",
+			convert: func() error {
+				_, _, err := ConvertAddIScsiSendTargetPortalArgs(nil, nil, invalidLoginOptions, nil, portal)
+				return err
+			},
+			expectedError: "invalid loginOptions argument: invalid username",
+		},
+		{
+			name: "removing a portal without one",
+			convert: func() error {
+				_, err := ConvertRemoveIScsiSendTargetPortalArgs(nil, nil, nil)
+				return err
+			},
+			expectedError: "portal is required",
+		},
+		{
+			name: "adding a connection without a portal",
+			convert: func() error {
+				_, _, err := ConvertAddIScsiConnectionArgs(iscsidsc.SessionID{}, nil, nil, nil, nil, nil)
+				return err
+			},
+			expectedError: "targetPortal is required",
+		},
+		{
+			name: "adding a connection with an invalid portal",
+			convert: func() error {
+				_, _, err := ConvertAddIScsiConnectionArgs(iscsidsc.SessionID{}, nil, invalidPortal, nil, nil, nil)
+				return err
+			},
+			expectedError: "invalid targetPortal argument: invalid portal address",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.convert()
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), testCase.expectedError)
+			}
+		})
+	}
+}
+
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	var s []uint16
+	for ; *p != 0; p = (*uint16)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) + 2)) {
+		s = append(s, *p)
+	}
+	return UTF16ToString(s)
+}
@@ -3,23 +3,28 @@ package internal
 // This file contains helpers to convert from public-facing to internal structs.
 
 import (
-	"golang.org/x/sys/windows"
-
 	"github.com/pkg/errors"
 	iscsidsc "github.com/wk8/go-win-iscsidsc"
 )
 
 // CheckAndConvertLoginOptions translates the user-facing `LoginOptions` struct
-// into the internal `LoginOptions` struct that the syscalls expect.
+// into the internal `LoginOptions` struct that the syscalls expect, after validating it
+// (see `iscsidsc.LoginOptions.Validate`).
 // Note that this latter struct contains two `uintptr`s  that map to `PUCHAR`s on the
 // C++ side, which means they need to be converted to unsafe pointers in a safe way;
 // we achieve that by taking advantage of converting them to unsafe pointers as part
 // of a function call's argument lists.
 // See https://golang.org/pkg/unsafe/#Pointer (point 4) for more info.
+// The username and password are copied, and their copies should be wiped with `WipeLoginOptions`
+// once the syscall has returned.
 func CheckAndConvertLoginOptions(optsIn *iscsidsc.LoginOptions) (opts *LoginOptions, userNamePtr, passwordPtr *byte, err error) {
 	if optsIn == nil {
 		optsIn = &iscsidsc.LoginOptions{}
 	}
+	if err = optsIn.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid login options")
+		return
+	}
 
 	opts = &LoginOptions{
 		// that one must always be the same as per Windows' doc
@@ -53,23 +58,20 @@ func CheckAndConvertLoginOptions(optsIn *iscsidsc.LoginOptions) (opts *LoginOpti
 	}
 
 	if optsIn.Username != nil {
-		userNamePtr, err = windows.BytePtrFromString(*optsIn.Username)
+		// don't leak credentials in error messages
+		userNamePtr, opts.UsernameLength, err = BytePtrFromSecret(optsIn.Username)
 		if err != nil {
-			err = errors.Wrapf(err, "invalid username: %q", *optsIn.Username)
+			err = errors.Wrap(err, "invalid username")
 			return
 		}
-
-		opts.UsernameLength = uint32(len(*optsIn.Username))
 		opts.InformationSpecified |= InformationSpecifiedUsername
 	}
 	if optsIn.Password != nil {
-		passwordPtr, err = windows.BytePtrFromString(*optsIn.Password)
+		passwordPtr, opts.PasswordLength, err = BytePtrFromSecret(optsIn.Password)
 		if err != nil {
-			err = errors.Wrapf(err, "invalid password: %q", *optsIn.Username)
+			err = errors.Wrap(err, "invalid password")
 			return
 		}
-
-		opts.PasswordLength = uint32(len(*optsIn.Password))
 		opts.InformationSpecified |= InformationSpecifiedPassword
 	}
 
@@ -78,6 +80,9 @@ func CheckAndConvertLoginOptions(optsIn *iscsidsc.LoginOptions) (opts *LoginOpti
 
 // CheckAndConvertPortal translates the user-facing `Portal` struct
 // into the internal `Portal` struct that the syscalls expect.
+// It's passed as per `iscsidsc.Portal.Raw`, i.e. with the default socket and without brackets
+// around IPv6 addresses, but otherwise as given: normalizing its address (see `iscsidsc.Portal.Normalize`)
+// could change what Windows connects to, e.g. "::ffff:10.0.0.5" would become "10.0.0.5".
 func CheckAndConvertPortal(ptlIn *iscsidsc.Portal) (*Portal, error) {
 	if ptlIn == nil {
 		return nil, nil
@@ -85,7 +90,7 @@ func CheckAndConvertPortal(ptlIn *iscsidsc.Portal) (*Portal, error) {
 
 	ptl := &Portal{}
 
-	symbolicNameRunes, err := windows.UTF16FromString(ptlIn.SymbolicName)
+	symbolicNameRunes, err := UTF16FromString(ptlIn.SymbolicName)
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid portal name: %q", ptlIn.SymbolicName)
 	}
@@ -95,7 +100,9 @@ func CheckAndConvertPortal(ptlIn *iscsidsc.Portal) (*Portal, error) {
 	}
 	ptl.SymbolicName = symbolicName
 
-	addressRunes, err := windows.UTF16FromString(ptlIn.Address)
+	raw := ptlIn.Raw()
+
+	addressRunes, err := UTF16FromString(raw.Address)
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid portal address: %q", ptlIn.Address)
 	}
@@ -105,11 +112,7 @@ func CheckAndConvertPortal(ptlIn *iscsidsc.Portal) (*Portal, error) {
 	}
 	ptl.Address = address
 
-	if ptlIn.Socket == nil {
-		ptl.Socket = DefaultPortalPortNumber
-	} else {
-		ptl.Socket = *ptlIn.Socket
-	}
+	ptl.Socket = *raw.Socket
 
 	return ptl, nil
 }
@@ -122,7 +125,7 @@ func ConvertInitiatorArgs(initiatorInstance *string, initiatorPortNumber *uint32
 		err                  error
 	)
 	if initiatorInstance != nil {
-		initiatorInstancePtr, err = windows.UTF16PtrFromString(*initiatorInstance)
+		initiatorInstancePtr, err = UTF16PtrFromString(*initiatorInstance)
 		if err != nil {
 			return nil, 0, errors.Wrapf(err, "invalid initiatorInstance argument: %q", *initiatorInstance)
 		}
@@ -141,16 +144,51 @@ func ConvertInitiatorPortNumber(initiatorPortNumber *uint32) uint32 {
 	return initiatorPortNumberValue
 }
 
+// BytePtrFromSecret encodes secret according to its encoding (see `iscsidsc.Secret.Encode`), and returns
+// a pointer to a null-terminated copy of the result, as well as its length (not counting the null character).
+// Depending on the encoding, the result may contain null characters; Windows' API takes credentials'
+// lengths explicitly, so that's fine.
+func BytePtrFromSecret(secret *iscsidsc.Secret) (*byte, uint32, error) {
+	encoded, err := secret.Encode()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	a := make([]byte, len(encoded)+1)
+	copy(a, encoded)
+	if len(encoded) != 0 {
+		WipeBytePtr(&encoded[0], len(encoded))
+	}
+
+	return &a[0], uint32(len(encoded)), nil
+}
+
+// WipeLoginOptions zeroes the copies of the username and password made by `CheckAndConvertLoginOptions`.
+func WipeLoginOptions(opts *LoginOptions, userNamePtr, passwordPtr *byte) {
+	if opts == nil {
+		return
+	}
+	// +1 for the null characters
+	WipeBytePtr(userNamePtr, int(opts.UsernameLength)+1)
+	WipeBytePtr(passwordPtr, int(opts.PasswordLength)+1)
+}
+
 // CheckAndConvertKey converts the user-facing key argument, common to several
 // procs, into internal types compatible with Windows' API.
-func CheckAndConvertKey(key *string) (keyPtr *byte, keySize uint32, err error) {
+// The key is copied, and its copy should be wiped with `WipeKey` once the syscall has returned.
+func CheckAndConvertKey(key *iscsidsc.Secret) (keyPtr *byte, keySize uint32, err error) {
 	if key != nil {
-		if keyPtr, err = windows.BytePtrFromString(*key); err != nil {
-			err = errors.Wrapf(err, "invalid key: %q", *key)
-			return
+		if keyPtr, keySize, err = BytePtrFromSecret(key); err != nil {
+			// don't leak the key in the error message
+			err = errors.Wrap(err, "invalid key")
 		}
-		keySize = uint32(len(*key))
 	}
 
 	return
 }
+
+// WipeKey zeroes the copy of the key made by `CheckAndConvertKey`.
+func WipeKey(keyPtr *byte, keySize uint32) {
+	// +1 for the null character
+	WipeBytePtr(keyPtr, int(keySize)+1)
+}
@@ -5,8 +5,7 @@ import (
 	"testing"
 	"unsafe"
 
-	"golang.org/x/sys/windows"
-
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	iscsidsc "github.com/wk8/go-win-iscsidsc"
@@ -22,8 +21,8 @@ func TestCheckAndConvertLoginOptions(t *testing.T) {
 
 			assert.Nil(t, err)
 			assert.Equal(t, expectedOutput, output)
-			assertIsBytePointerFromString(t, userNamePtr, input.Username)
-			assertIsBytePointerFromString(t, passwordPtr, input.Password)
+			assertIsBytePointerFromSecret(t, userNamePtr, input.Username)
+			assertIsBytePointerFromSecret(t, passwordPtr, input.Password)
 		})
 	}
 
@@ -35,14 +34,19 @@ func TestCheckAndConvertLoginOptions(t *testing.T) {
 		expectedOutputFunc func(*LoginOptions)
 	}{
 		{
+			// CHAP requires credentials, so this sets the same ones as the username and password cases below
 			name: "auth type",
 			inputFunc: func(optsIn *iscsidsc.LoginOptions) {
 				chapAuthType := iscsidsc.CHAPAuthType
 				optsIn.AuthType = &chapAuthType
+				optsIn.Username = iscsidsc.NewSecretFromString("username")
+				optsIn.Password = iscsidsc.NewSecretFromString("super_password")
 			},
 			expectedOutputFunc: func(opts *LoginOptions) {
-				opts.InformationSpecified |= InformationSpecifiedAuthType
+				opts.InformationSpecified |= InformationSpecifiedAuthType | InformationSpecifiedUsername | InformationSpecifiedPassword
 				opts.AuthType = iscsidsc.CHAPAuthType
+				opts.UsernameLength = uint32(8)
+				opts.PasswordLength = uint32(14)
 			},
 		},
 		{
@@ -103,8 +107,7 @@ func TestCheckAndConvertLoginOptions(t *testing.T) {
 		{
 			name: "username",
 			inputFunc: func(optsIn *iscsidsc.LoginOptions) {
-				username := "username"
-				optsIn.Username = &username
+				optsIn.Username = iscsidsc.NewSecretFromString("username")
 			},
 			expectedOutputFunc: func(opts *LoginOptions) {
 				opts.InformationSpecified |= InformationSpecifiedUsername
@@ -114,8 +117,7 @@ func TestCheckAndConvertLoginOptions(t *testing.T) {
 		{
 			name: "password",
 			inputFunc: func(optsIn *iscsidsc.LoginOptions) {
-				password := "super_password"
-				optsIn.Password = &password
+				optsIn.Password = iscsidsc.NewSecretFromString("super_password")
 			},
 			expectedOutputFunc: func(opts *LoginOptions) {
 				opts.InformationSpecified |= InformationSpecifiedPassword
@@ -142,13 +144,99 @@ func TestCheckAndConvertLoginOptions(t *testing.T) {
 	})
 }
 
+func TestCheckAndConvertLoginOptionsErrors(t *testing.T) {
+	t.Run("it validates the options", func(t *testing.T) {
+		maximumConnections := uint32(0)
+
+		_, _, _, err := CheckAndConvertLoginOptions(&iscsidsc.LoginOptions{MaximumConnections: &maximumConnections})
+
+		require.NotNil(t, err)
+		assert.Equal(t, "invalid login options: MaximumConnections is 0, must be between 1 and 65535", err.Error())
+		assert.IsType(t, &iscsidsc.LoginOptionsRangeError{}, errors.Cause(err))
+	})
+
+	t.Run("it doesn't leak invalid passwords, nor usernames, in errors", func(t *testing.T) {
+		username := iscsidsc.NewSecretFromString("username")
+		password := iscsidsc.NewSecretFromString("hunter\x002")
+
+		_, _, _, err := CheckAndConvertLoginOptions(&iscsidsc.LoginOptions{Username: username, Password: password})
+
+		require.NotNil(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "invalid password: "))
+		assert.NotContains(t, err.Error(), "username")
+		assert.NotContains(t, err.Error(), "hunter")
+	})
+}
+
+func TestCheckAndConvertLoginOptionsCredentialEncodings(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		value           string
+		encoding        iscsidsc.CredentialEncoding
+		expectedEncoded string
+	}{
+		{"UTF-8", "café", iscsidsc.CredentialEncodingUTF8, "caf\xc3\xa9"},
+		{"Latin-1", "café", iscsidsc.CredentialEncodingLatin1, "caf\xe9"},
+		{"raw bytes with null characters", "\x00\xffcaf\xe9", iscsidsc.CredentialEncodingRaw, "\x00\xffcaf\xe9"},
+		{"hex", "0x00ff636166e9", iscsidsc.CredentialEncodingHex, "\x00\xffcaf\xe9"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			secret, err := iscsidsc.NewEncodedSecret([]byte(testCase.value), testCase.encoding)
+			require.Nil(t, err)
+
+			opts, _, passwordPtr, err := CheckAndConvertLoginOptions(&iscsidsc.LoginOptions{Password: secret})
+			require.Nil(t, err)
+
+			assert.Equal(t, uint32(len(testCase.expectedEncoded)), opts.PasswordLength)
+			assertIsBytePointerFromSecret(t, passwordPtr, secret)
+			assert.Equal(t, testCase.expectedEncoded, string((*[64]byte)(unsafe.Pointer(passwordPtr))[:opts.PasswordLength]))
+
+			keyPtr, keySize, err := CheckAndConvertKey(secret)
+			require.Nil(t, err)
+			assert.Equal(t, opts.PasswordLength, keySize)
+			assertIsBytePointerFromSecret(t, keyPtr, secret)
+		})
+	}
+
+	t.Run("with an invalid secret", func(t *testing.T) {
+		_, _, _, err := CheckAndConvertLoginOptions(&iscsidsc.LoginOptions{Username: iscsidsc.NewSecretFromString("caf\xe9")})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "invalid username: UTF-8 secret is not valid UTF-8", err.Error())
+		}
+	})
+}
+
+func TestWipeLoginOptionsAndKey(t *testing.T) {
+	opts, userNamePtr, passwordPtr, err := CheckAndConvertLoginOptions(&iscsidsc.LoginOptions{
+		Username: iscsidsc.NewSecretFromString("username"),
+		Password: iscsidsc.NewSecretFromString("super_password"),
+	})
+	require.Nil(t, err)
+	key := iscsidsc.NewSecretFromString("key")
+	keyPtr, keySize, err := CheckAndConvertKey(key)
+	require.Nil(t, err)
+
+	WipeLoginOptions(opts, userNamePtr, passwordPtr)
+	WipeKey(keyPtr, keySize)
+
+	zeroes := func(n int) *iscsidsc.Secret {
+		secret, err := iscsidsc.NewEncodedSecret(make([]byte, n), iscsidsc.CredentialEncodingRaw)
+		require.Nil(t, err)
+		return secret
+	}
+	assertIsBytePointerFromSecret(t, userNamePtr, zeroes(8))
+	assertIsBytePointerFromSecret(t, passwordPtr, zeroes(14))
+	assertIsBytePointerFromSecret(t, keyPtr, zeroes(3))
+	assert.Equal(t, []byte("key"), key.Bytes(), "should not have wiped the caller's secret")
+}
+
 func TestCheckAndConvertPortal(t *testing.T) {
 	symbolicName := "symbolic_name"
 	address := "1.1.1.1"
 	socket := uint16(2828)
 
 	toUTF16 := func(s string) [256]uint16 {
-		utf16, err := windows.UTF16FromString(s)
+		utf16, err := UTF16FromString(s)
 		require.Nil(t, err)
 		var result [MaxIscsiPortalNameLen]uint16
 		copy(result[:], utf16)
@@ -199,6 +287,16 @@ func TestCheckAndConvertPortal(t *testing.T) {
 			input:          &iscsidsc.Portal{},
 			expectedOutput: &Portal{Socket: DefaultPortalPortNumber},
 		},
+		{
+			name:           "it removes brackets around IPv6 addresses",
+			input:          &iscsidsc.Portal{Address: "[FE80:0::1%3]"},
+			expectedOutput: &Portal{Address: toUTF16("FE80:0::1%3"), Socket: DefaultPortalPortNumber},
+		},
+		{
+			name:           "it doesn't otherwise normalize addresses",
+			input:          &iscsidsc.Portal{Address: "::ffff:10.0.0.5"},
+			expectedOutput: &Portal{Address: toUTF16("::ffff:10.0.0.5"), Socket: DefaultPortalPortNumber},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -218,23 +316,25 @@ func TestCheckAndConvertPortal(t *testing.T) {
 	}
 }
 
-// assertIsBytePointerFromString asserts that ptr was obtained by calling windows.BytePtrFromString(*str).
+// assertIsBytePointerFromSecret asserts that ptr was obtained by calling BytePtrFromSecret(secret).
 // also checks that either both pointers are nil, or both are not-nil.
-func assertIsBytePointerFromString(t *testing.T, ptr *byte, str *string) {
+func assertIsBytePointerFromSecret(t *testing.T, ptr *byte, secret *iscsidsc.Secret) {
 	if ptr == nil {
-		assert.Nil(t, str)
+		assert.Nil(t, secret)
 		return
 	}
-	if !assert.NotNil(t, str) {
+	if !assert.NotNil(t, secret) {
 		return
 	}
 
-	byteSlice := make([]byte, len(*str)+1)
+	encoded, err := secret.Encode()
+	require.Nil(t, err)
+	byteSlice := make([]byte, len(encoded)+1)
 
 	for i := 0; i < len(byteSlice); i++ {
 		byteSlice[i] = *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + uintptr(i)))
 	}
 
-	assert.Equal(t, *str, string(byteSlice[:len(byteSlice)-1]))
+	assert.Equal(t, encoded, byteSlice[:len(byteSlice)-1])
 	assert.Equal(t, byte(0), byteSlice[len(byteSlice)-1])
 }
@@ -0,0 +1,147 @@
+package internal
+
+// This file contains helpers to decode the structs contained in the buffers returned by Windows' API,
+// according to a `Layout`.
+// All the decoding functions below expect to be given a buffer starting at the beginning of the struct
+// to decode, and at least as long as the struct's size for that layout; it's up to the caller to check that.
+
+import (
+	"encoding/binary"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// DecodeLoginOptions decodes a `ISCSI_LOGIN_OPTIONS` C++ struct.
+func (l *Layout) DecodeLoginOptions(b []byte) *LoginOptions {
+	lo := &l.LoginOptions
+	return &LoginOptions{
+		Version:              readUint32(b, lo.Version),
+		InformationSpecified: InformationSpecified(readUint32(b, lo.InformationSpecified)),
+		LoginFlags:           iscsidsc.LoginFlags(readUint32(b, lo.LoginFlags)),
+		AuthType:             iscsidsc.AuthType(readUint32(b, lo.AuthType)),
+		HeaderDigest:         iscsidsc.DigestType(readUint32(b, lo.HeaderDigest)),
+		DataDigest:           iscsidsc.DigestType(readUint32(b, lo.DataDigest)),
+		MaximumConnections:   readUint32(b, lo.MaximumConnections),
+		DefaultTime2Wait:     readUint32(b, lo.DefaultTime2Wait),
+		DefaultTime2Retain:   readUint32(b, lo.DefaultTime2Retain),
+		UsernameLength:       readUint32(b, lo.UsernameLength),
+		PasswordLength:       readUint32(b, lo.PasswordLength),
+		Username:             l.readPointer(b, lo.Username),
+		Password:             l.readPointer(b, lo.Password),
+	}
+}
+
+// DecodePortalInfo decodes a `ISCSI_TARGET_PORTAL_INFO_EXW` C++ struct.
+func (l *Layout) DecodePortalInfo(b []byte) *PortalInfo {
+	pi := &l.PortalInfo
+	info := &PortalInfo{
+		InitiatorPortNumber: readUint32(b, pi.InitiatorPortNumber),
+		Socket:              readUint16(b, pi.Socket),
+		SecurityFlags:       iscsidsc.SecurityFlags(readUint64(b, pi.SecurityFlags)),
+		LoginOptions:        *l.DecodeLoginOptions(b[pi.LoginOptions:]),
+	}
+	readWideChars(b, pi.InitiatorName, info.InitiatorName[:])
+	readWideChars(b, pi.SymbolicName, info.SymbolicName[:])
+	readWideChars(b, pi.Address, info.Address[:])
+	return info
+}
+
+// DecodeSessionInfo decodes a `ISCSI_SESSION_INFOW` C++ struct.
+func (l *Layout) DecodeSessionInfo(b []byte) *SessionInfo {
+	si := &l.SessionInfo
+	info := &SessionInfo{
+		SessionID:       iscsidsc.SessionID(readUniqueID(b, si.SessionID)),
+		InitiatorName:   l.readPointer(b, si.InitiatorName),
+		TargetNodeName:  l.readPointer(b, si.TargetNodeName),
+		TargetName:      l.readPointer(b, si.TargetName),
+		ConnectionCount: readUint32(b, si.ConnectionCount),
+		Connections:     l.readPointer(b, si.Connections),
+	}
+	copy(info.ISID[:], b[si.ISID:])
+	copy(info.TSID[:], b[si.TSID:])
+	return info
+}
+
+// DecodeConnectionInfo decodes a `ISCSI_CONNECTION_INFOW` C++ struct.
+func (l *Layout) DecodeConnectionInfo(b []byte) *ConnectionInfo {
+	ci := &l.ConnectionInfo
+	info := &ConnectionInfo{
+		ConnectionID:     iscsidsc.ConnectionID(readUniqueID(b, ci.ConnectionID)),
+		InitiatorAddress: l.readPointer(b, ci.InitiatorAddress),
+		TargetAddress:    l.readPointer(b, ci.TargetAddress),
+		InitiatorSocket:  readUint16(b, ci.InitiatorSocket),
+		TargetSocket:     readUint16(b, ci.TargetSocket),
+	}
+	copy(info.CID[:], b[ci.CID:])
+	return info
+}
+
+// DecodeDevice decodes a `ISCSI_DEVICE_ON_SESSIONW` C++ struct.
+func (l *Layout) DecodeDevice(b []byte) *Device {
+	d := &l.Device
+	device := &Device{
+		ScsiAddress: ScsiAddress{
+			Length:     readUint32(b, d.ScsiAddress),
+			PortNumber: b[d.ScsiAddress+4],
+			PathID:     b[d.ScsiAddress+5],
+			TargetID:   b[d.ScsiAddress+6],
+			Lun:        b[d.ScsiAddress+7],
+		},
+		DeviceInterfaceType: GUID{
+			Data1: readUint32(b, d.DeviceInterfaceType),
+			Data2: readUint16(b, d.DeviceInterfaceType+4),
+			Data3: readUint16(b, d.DeviceInterfaceType+6),
+		},
+		StorageDeviceNumber: iscsidsc.StorageDeviceNumber{
+			DeviceType:      iscsidsc.DeviceType(readUint32(b, d.StorageDeviceNumber)),
+			DeviceNumber:    readUint32(b, d.StorageDeviceNumber+4),
+			PartitionNumber: readUint32(b, d.StorageDeviceNumber+8),
+		},
+		DeviceInstance: readUint32(b, d.DeviceInstance),
+	}
+	copy(device.DeviceInterfaceType.Data4[:], b[d.DeviceInterfaceType+8:])
+	readWideChars(b, d.InitiatorName, device.InitiatorName[:])
+	readWideChars(b, d.TargetName, device.TargetName[:])
+	readWideChars(b, d.DeviceInterfaceName, device.DeviceInterfaceName[:])
+	readWideChars(b, d.LegacyName, device.LegacyName[:])
+	return device
+}
+
+// uniqueID has the same fields as both `ISCSI_UNIQUE_SESSION_ID` and `ISCSI_UNIQUE_CONNECTION_ID`.
+type uniqueID struct {
+	AdapterUnique   uint64
+	AdapterSpecific uint64
+}
+
+func readUniqueID(b []byte, offset uintptr) uniqueID {
+	return uniqueID{
+		AdapterUnique:   readUint64(b, offset),
+		AdapterSpecific: readUint64(b, offset+8),
+	}
+}
+
+// readPointer reads a pointer; note that it's only meaningful as an address, it can't be dereferenced.
+func (l *Layout) readPointer(b []byte, offset uintptr) uintptr {
+	if l.PointerSize == 4 {
+		return uintptr(readUint32(b, offset))
+	}
+	return uintptr(readUint64(b, offset))
+}
+
+func readUint16(b []byte, offset uintptr) uint16 {
+	return binary.LittleEndian.Uint16(b[offset:])
+}
+
+func readUint32(b []byte, offset uintptr) uint32 {
+	return binary.LittleEndian.Uint32(b[offset:])
+}
+
+func readUint64(b []byte, offset uintptr) uint64 {
+	return binary.LittleEndian.Uint64(b[offset:])
+}
+
+func readWideChars(b []byte, offset uintptr, dst []uint16) {
+	for i := range dst {
+		dst[i] = readUint16(b, offset+2*uintptr(i))
+	}
+}
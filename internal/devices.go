@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+const getDevicesForIScsiSessionProcName = "GetDevicesForIScsiSessionW"
+
+// hydrateDevices takes the raw bytes returned by the `GetDevicesForIScsiSessionW` C++ proc,
+// and decodes the raw data into Go structs, according to the given layout.
+func hydrateDevices(buffer []byte, layout *Layout) ([]iscsidsc.Device, error) {
+	if len(buffer)%int(layout.Device.Size) != 0 {
+		return nil, hydrateDevicesError("expected reply size to be a multiple of %d, actual size %d",
+			layout.Device.Size, len(buffer))
+	}
+	count := len(buffer) / int(layout.Device.Size)
+
+	devices := make([]iscsidsc.Device, count)
+	for i := 0; i < count; i++ {
+		if err := hydrateDevice(buffer, i, layout, &devices[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return devices, nil
+}
+
+// hydrateDevice hydrates a single `Device` struct.
+func hydrateDevice(buffer []byte, i int, layout *Layout, device *iscsidsc.Device) error {
+	// we already know that we're still in the buffer here - we check that at the very start of `hydrateDevices`
+	deviceIn := layout.DecodeDevice(buffer[uintptr(i)*layout.Device.Size:])
+
+	device.InitiatorName = UTF16ToString(deviceIn.InitiatorName[:])
+	device.TargetName = UTF16ToString(deviceIn.TargetName[:])
+
+	scsiAddress, err := hydrateScsiAddress(deviceIn.ScsiAddress)
+	if err != nil {
+		return err
+	}
+	device.ScsiAddress = *scsiAddress
+
+	guid, err := hydrateGUID(deviceIn.DeviceInterfaceType)
+	if err != nil {
+		return err
+	}
+	device.DeviceInterfaceType = guid
+
+	device.DeviceInterfaceName = UTF16ToString(deviceIn.DeviceInterfaceName[:])
+	device.LegacyName = UTF16ToString(deviceIn.LegacyName[:])
+	device.StorageDeviceNumber = deviceIn.StorageDeviceNumber
+	device.DeviceInstance = deviceIn.DeviceInstance
+
+	return nil
+}
+
+func hydrateScsiAddress(addressIn ScsiAddress) (*iscsidsc.ScsiAddress, error) {
+	if addressIn.Length != 8 {
+		return nil, hydrateDevicesError("Unexpected SCSI address length: %d", addressIn.Length)
+	}
+
+	return &iscsidsc.ScsiAddress{
+		PortNumber: addressIn.PortNumber,
+		PathID:     addressIn.PathID,
+		TargetID:   addressIn.TargetID,
+		Lun:        addressIn.Lun,
+	}, nil
+}
+
+// hydrateGUID converts a GUID as stored internally by Windows' API into
+// a more easily usable struct.
+// Note that a UUID's bytes are in the same order as a GUID's canonical string form,
+// i.e. its first 3 fields are big-endian.
+func hydrateGUID(guidIn GUID) (uuid.UUID, error) {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint32(b, guidIn.Data1)
+	binary.BigEndian.PutUint16(b[4:], guidIn.Data2)
+	binary.BigEndian.PutUint16(b[6:], guidIn.Data3)
+	copy(b[8:], guidIn.Data4[:])
+
+	guid, err := uuid.FromBytes(b)
+
+	if err != nil {
+		return guid, hydrateDevicesError("error when parsing GUID: %v", err)
+	}
+	return guid, nil
+}
+
+func hydrateDevicesError(format string, args ...interface{}) error {
+	msg := fmt.Sprintf("Error when hydrating the response from %s - it might be that your Windows version is not supported: ", getDevicesForIScsiSessionProcName)
+	return errors.Errorf(msg+format, args...)
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestHydrateDevices(t *testing.T) {
+	devices := []iscsidsc.Device{
+		{
+			InitiatorName:       "ROOT\\ISCSIPRT\\0000_0",
+			TargetName:          "iqn.1991-05.com.microsoft:target-1",
+			ScsiAddress:         iscsidsc.ScsiAddress{PortNumber: 2, PathID: 0, TargetID: 1, Lun: 0},
+			DeviceInterfaceType: uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b"),
+			DeviceInterfaceName: "\\\\?\\scsi#disk&ven_msft&prod_virtual_hd#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}",
+			LegacyName:          "\\\\.\\PhysicalDrive1",
+			StorageDeviceNumber: iscsidsc.StorageDeviceNumber{DeviceType: 7, DeviceNumber: 1, PartitionNumber: 0},
+			DeviceInstance:      12,
+		},
+		{
+			InitiatorName:       "ROOT\\ISCSIPRT\\0000_0",
+			TargetName:          "iqn.1991-05.com.microsoft:target-1",
+			ScsiAddress:         iscsidsc.ScsiAddress{PortNumber: 2, PathID: 0, TargetID: 1, Lun: 1},
+			DeviceInterfaceType: uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b"),
+			DeviceInterfaceName: "\\\\?\\scsi#disk&ven_msft&prod_virtual_hd#1&1c121344&0&000001#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}",
+			LegacyName:          "\\\\.\\PhysicalDrive2",
+			StorageDeviceNumber: iscsidsc.StorageDeviceNumber{DeviceType: 7, DeviceNumber: 2, PartitionNumber: 0},
+			DeviceInstance:      13,
+		},
+	}
+
+	for arch, layout := range Layouts {
+		t.Run(arch, func(t *testing.T) {
+			buffer := make([]byte, 0)
+			for _, device := range devices {
+				buffer = append(buffer, layout.EncodeDevice(toInternalDevice(device))...)
+			}
+
+			hydrated, err := hydrateDevices(buffer, layout)
+
+			require.Nil(t, err)
+			assert.Equal(t, devices, hydrated)
+		})
+	}
+
+	t.Run("with a buffer that's not a multiple of the device size", func(t *testing.T) {
+		layout := Layouts["amd64"]
+		buffer := layout.EncodeDevice(toInternalDevice(devices[0]))
+
+		_, err := hydrateDevices(buffer[:len(buffer)-1], layout)
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "expected reply size to be a multiple of 2040, actual size 2039")
+		}
+	})
+
+	t.Run("with an unexpected SCSI address length", func(t *testing.T) {
+		layout := Layouts["amd64"]
+		deviceIn := toInternalDevice(devices[0])
+		deviceIn.ScsiAddress.Length = 12
+
+		_, err := hydrateDevices(layout.EncodeDevice(deviceIn), layout)
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Unexpected SCSI address length: 12")
+		}
+	})
+}
+
+func TestHydrateGUID(t *testing.T) {
+	// that's GUID_DEVINTERFACE_DISK
+	guid, err := hydrateGUID(GUID{
+		Data1: 0x53f56307,
+		Data2: 0xb6bf,
+		Data3: 0x11d0,
+		Data4: [8]byte{0x94, 0xf2, 0x00, 0xa0, 0xc9, 0x1e, 0xfb, 0x8b},
+	})
+
+	require.Nil(t, err)
+	assert.Equal(t, "53f56307-b6bf-11d0-94f2-00a0c91efb8b", guid.String())
+}
+
+// toInternalDevice is the reverse of hydrateDevice.
+func toInternalDevice(device iscsidsc.Device) *Device {
+	deviceIn := &Device{
+		ScsiAddress: ScsiAddress{
+			Length:     8,
+			PortNumber: device.ScsiAddress.PortNumber,
+			PathID:     device.ScsiAddress.PathID,
+			TargetID:   device.ScsiAddress.TargetID,
+			Lun:        device.ScsiAddress.Lun,
+		},
+		StorageDeviceNumber: device.StorageDeviceNumber,
+		DeviceInstance:      device.DeviceInstance,
+	}
+
+	b := device.DeviceInterfaceType
+	deviceIn.DeviceInterfaceType = GUID{
+		Data1: uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]),
+		Data2: uint16(b[4])<<8 | uint16(b[5]),
+		Data3: uint16(b[6])<<8 | uint16(b[7]),
+	}
+	copy(deviceIn.DeviceInterfaceType.Data4[:], b[8:])
+
+	StringToWideChars(device.InitiatorName, deviceIn.InitiatorName[:])
+	StringToWideChars(device.TargetName, deviceIn.TargetName[:])
+	StringToWideChars(device.DeviceInterfaceName, deviceIn.DeviceInterfaceName[:])
+	StringToWideChars(device.LegacyName, deviceIn.LegacyName[:])
+
+	return deviceIn
+}
@@ -3,8 +3,8 @@ package internal
 // This file contains helpers to hydrate objects from the buffers returned by Windows' API procs.
 
 import (
+	"encoding/binary"
 	"unicode/utf16"
-	"unsafe"
 
 	"github.com/pkg/errors"
 )
@@ -30,9 +30,7 @@ func ExtractWideStringFromBuffer(buffer []byte, bufferPointer, stringPointer uin
 	// starting the wide chars buffer with a reasonable capacity allows avoiding too many resizes when adding to it
 	wideChars := make([]uint16, 0, 50)
 	for bufferOffset+1 < uintptr(len(buffer)) {
-		// this is safe as per rule (1) of https://golang.org/pkg/unsafe/#Pointer:
-		// any 2 bytes can be read as a uint16
-		char := *(*uint16)(unsafe.Pointer(&buffer[bufferOffset]))
+		char := binary.LittleEndian.Uint16(buffer[bufferOffset:])
 		if char == 0 {
 			break
 		}
@@ -50,17 +48,23 @@ func ExtractWideStringFromBuffer(buffer []byte, bufferPointer, stringPointer uin
 
 // ExtractStringFromBuffer extracts a regular string (PCHAR) with known length from a buffer returned by the Windows API.
 func ExtractStringFromBuffer(buffer []byte, bufferPointer, stringPointer, stringSize uintptr) (string, error) {
-	// first let's compute the offset at which we should find the string in the buffer:
+	strBytes, err := ExtractBytesFromBuffer(buffer, bufferPointer, stringPointer, stringSize)
+	return string(strBytes), err
+}
+
+// ExtractBytesFromBuffer is the same as `ExtractStringFromBuffer`, but returns a copy of the raw bytes.
+func ExtractBytesFromBuffer(buffer []byte, bufferPointer, bytesPointer, size uintptr) ([]byte, error) {
+	// first let's compute the offset at which we should find the bytes in the buffer:
 	// the pointer address we have might not be valid any more, since the GC might have moved the
 	// buffer internally; that's why we use the address of the start of the buffer as it was when we made
 	// the syscall
-	bufferOffset := stringPointer - bufferPointer
+	bufferOffset := bytesPointer - bufferPointer
 	// sanity check: this should still be inside the buffer
-	if stringPointer < bufferPointer || bufferOffset+stringSize > uintptr(len(buffer)) {
-		return "", errors.New("string pointer pointing out of the buffer")
+	if bytesPointer < bufferPointer || bufferOffset+size > uintptr(len(buffer)) {
+		return nil, errors.New("string pointer pointing out of the buffer")
 	}
 
-	strBytes := make([]byte, stringSize)
-	copy(strBytes, buffer[bufferOffset:bufferOffset+stringSize])
-	return string(strBytes), nil
+	bytes := make([]byte, size)
+	copy(bytes, buffer[bufferOffset:bufferOffset+size])
+	return bytes, nil
 }
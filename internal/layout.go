@@ -0,0 +1,236 @@
+package internal
+
+// This file contains descriptors of how the MSVC compiler lays out in memory the C++ structs
+// returned by Windows' API, for each architecture Windows runs on.
+// We need those because Go's own struct layout does not always match MSVC's: for example
+// on 386, Go aligns 8-byte integers on 4 bytes, whereas MSVC aligns them on 8 bytes; so
+// casting a buffer returned by Windows' API to a Go struct is only correct on some architectures.
+// Instead, we decode buffers field by field, using the offsets described here.
+
+import (
+	"runtime"
+)
+
+// Layout describes the sizes and alignments that determine how MSVC lays out structs on
+// a given architecture, as well as the resulting offsets for each of the structs we decode.
+type Layout struct {
+	// Arch is the name of the architecture, as reported by `runtime.GOARCH`.
+	Arch string
+	// PointerSize is the size, in bytes, of a pointer; pointers are always aligned on their size.
+	PointerSize uintptr
+	// Uint64Align is the alignment, in bytes, of 8-byte integers.
+	Uint64Align uintptr
+
+	LoginOptions   LoginOptionsLayout
+	PortalInfo     PortalInfoLayout
+	SessionInfo    SessionInfoLayout
+	ConnectionInfo ConnectionInfoLayout
+	Device         DeviceLayout
+}
+
+// LoginOptionsLayout contains the offsets of the `ISCSI_LOGIN_OPTIONS` C++ struct's fields.
+type LoginOptionsLayout struct {
+	Size                 uintptr
+	Align                uintptr
+	Version              uintptr
+	InformationSpecified uintptr
+	LoginFlags           uintptr
+	AuthType             uintptr
+	HeaderDigest         uintptr
+	DataDigest           uintptr
+	MaximumConnections   uintptr
+	DefaultTime2Wait     uintptr
+	DefaultTime2Retain   uintptr
+	UsernameLength       uintptr
+	PasswordLength       uintptr
+	Username             uintptr
+	Password             uintptr
+}
+
+// PortalInfoLayout contains the offsets of the `ISCSI_TARGET_PORTAL_INFO_EXW` C++ struct's fields.
+type PortalInfoLayout struct {
+	Size                uintptr
+	Align               uintptr
+	InitiatorName       uintptr
+	InitiatorPortNumber uintptr
+	SymbolicName        uintptr
+	Address             uintptr
+	Socket              uintptr
+	SecurityFlags       uintptr
+	LoginOptions        uintptr
+}
+
+// SessionInfoLayout contains the offsets of the `ISCSI_SESSION_INFOW` C++ struct's fields.
+type SessionInfoLayout struct {
+	Size            uintptr
+	Align           uintptr
+	SessionID       uintptr
+	InitiatorName   uintptr
+	TargetNodeName  uintptr
+	TargetName      uintptr
+	ISID            uintptr
+	TSID            uintptr
+	ConnectionCount uintptr
+	Connections     uintptr
+}
+
+// ConnectionInfoLayout contains the offsets of the `ISCSI_CONNECTION_INFOW` C++ struct's fields.
+type ConnectionInfoLayout struct {
+	Size             uintptr
+	Align            uintptr
+	ConnectionID     uintptr
+	InitiatorAddress uintptr
+	TargetAddress    uintptr
+	InitiatorSocket  uintptr
+	TargetSocket     uintptr
+	CID              uintptr
+}
+
+// DeviceLayout contains the offsets of the `ISCSI_DEVICE_ON_SESSIONW` C++ struct's fields.
+type DeviceLayout struct {
+	Size                uintptr
+	Align               uintptr
+	InitiatorName       uintptr
+	TargetName          uintptr
+	ScsiAddress         uintptr
+	DeviceInterfaceType uintptr
+	DeviceInterfaceName uintptr
+	LegacyName          uintptr
+	StorageDeviceNumber uintptr
+	DeviceInstance      uintptr
+}
+
+var (
+	// Layouts contains the layouts for all the architectures Windows runs on, indexed by
+	// their `runtime.GOARCH` name.
+	Layouts = map[string]*Layout{
+		"386":   NewLayout("386", 4, 8),
+		"amd64": NewLayout("amd64", 8, 8),
+		"arm":   NewLayout("arm", 4, 8),
+		"arm64": NewLayout("arm64", 8, 8),
+	}
+
+	// NativeLayout is the layout for the architecture we're running on.
+	// It is nil if Windows does not run on this architecture.
+	NativeLayout = Layouts[runtime.GOARCH]
+)
+
+// NewLayout computes the offsets of all the structs we decode, given an architecture's
+// pointer size and 8-byte integers alignment.
+func NewLayout(arch string, pointerSize, uint64Align uintptr) *Layout {
+	l := &Layout{
+		Arch:        arch,
+		PointerSize: pointerSize,
+		Uint64Align: uint64Align,
+	}
+
+	lo := &l.LoginOptions
+	s := &structLayout{}
+	lo.Version = s.uint32()
+	lo.InformationSpecified = s.uint32()
+	lo.LoginFlags = s.uint32()
+	lo.AuthType = s.uint32()
+	lo.HeaderDigest = s.uint32()
+	lo.DataDigest = s.uint32()
+	lo.MaximumConnections = s.uint32()
+	lo.DefaultTime2Wait = s.uint32()
+	lo.DefaultTime2Retain = s.uint32()
+	lo.UsernameLength = s.uint32()
+	lo.PasswordLength = s.uint32()
+	lo.Username = s.field(pointerSize, pointerSize)
+	lo.Password = s.field(pointerSize, pointerSize)
+	lo.Size, lo.Align = s.done()
+
+	pi := &l.PortalInfo
+	s = &structLayout{}
+	pi.InitiatorName = s.wideChars(MaxHbaNameLen)
+	pi.InitiatorPortNumber = s.uint32()
+	pi.SymbolicName = s.wideChars(MaxIscsiPortalNameLen)
+	pi.Address = s.wideChars(MaxIscsiPortalAddressLen)
+	pi.Socket = s.uint16()
+	pi.SecurityFlags = s.field(8, uint64Align)
+	pi.LoginOptions = s.field(lo.Size, lo.Align)
+	pi.Size, pi.Align = s.done()
+
+	// both `ISCSI_UNIQUE_SESSION_ID` and `ISCSI_UNIQUE_CONNECTION_ID` are made of 2 8-byte integers
+	uniqueIDSize, uniqueIDAlign := uintptr(16), uint64Align
+
+	si := &l.SessionInfo
+	s = &structLayout{}
+	si.SessionID = s.field(uniqueIDSize, uniqueIDAlign)
+	si.InitiatorName = s.field(pointerSize, pointerSize)
+	si.TargetNodeName = s.field(pointerSize, pointerSize)
+	si.TargetName = s.field(pointerSize, pointerSize)
+	si.ISID = s.field(6, 1)
+	si.TSID = s.field(2, 1)
+	si.ConnectionCount = s.uint32()
+	si.Connections = s.field(pointerSize, pointerSize)
+	si.Size, si.Align = s.done()
+
+	ci := &l.ConnectionInfo
+	s = &structLayout{}
+	ci.ConnectionID = s.field(uniqueIDSize, uniqueIDAlign)
+	ci.InitiatorAddress = s.field(pointerSize, pointerSize)
+	ci.TargetAddress = s.field(pointerSize, pointerSize)
+	ci.InitiatorSocket = s.uint16()
+	ci.TargetSocket = s.uint16()
+	ci.CID = s.field(2, 1)
+	ci.Size, ci.Align = s.done()
+
+	d := &l.Device
+	s = &structLayout{}
+	d.InitiatorName = s.wideChars(MaxHbaNameLen)
+	d.TargetName = s.wideChars(MaxIscsiNameLen + 1)
+	// `SCSI_ADDRESS` is a 4-byte integer followed by 4 bytes
+	d.ScsiAddress = s.field(8, 4)
+	// `GUID` is a 4-byte integer, 2 2-byte integers and 8 bytes
+	d.DeviceInterfaceType = s.field(16, 4)
+	d.DeviceInterfaceName = s.wideChars(MaxPath)
+	d.LegacyName = s.wideChars(MaxPath)
+	// `STORAGE_DEVICE_NUMBER` is 3 4-byte integers
+	d.StorageDeviceNumber = s.field(12, 4)
+	d.DeviceInstance = s.uint32()
+	d.Size, d.Align = s.done()
+
+	return l
+}
+
+// structLayout incrementally computes the offsets of a struct's fields, following MSVC's
+// default packing rules: each field is aligned on its own alignment, and the struct's total
+// size is padded to a multiple of its largest field's alignment.
+type structLayout struct {
+	size  uintptr
+	align uintptr
+}
+
+// field adds a field to the struct, and returns its offset.
+func (s *structLayout) field(size, align uintptr) uintptr {
+	s.size = alignUp(s.size, align)
+	offset := s.size
+	s.size += size
+	if align > s.align {
+		s.align = align
+	}
+	return offset
+}
+
+func (s *structLayout) uint16() uintptr {
+	return s.field(2, 2)
+}
+
+func (s *structLayout) uint32() uintptr {
+	return s.field(4, 4)
+}
+
+func (s *structLayout) wideChars(count uintptr) uintptr {
+	return s.field(2*count, 2)
+}
+
+// done returns the struct's total size and alignment.
+func (s *structLayout) done() (size, align uintptr) {
+	return alignUp(s.size, s.align), s.align
+}
+
+func alignUp(offset, align uintptr) uintptr {
+	return (offset + align - 1) / align * align
+}
@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// these are the layouts produced by MSVC, as reported by `offsetof` and `sizeof`
+var (
+	expectedLoginOptionsLayout64 = LoginOptionsLayout{
+		Size: 64, Align: 8,
+		Version: 0, InformationSpecified: 4, LoginFlags: 8, AuthType: 12, HeaderDigest: 16, DataDigest: 20,
+		MaximumConnections: 24, DefaultTime2Wait: 28, DefaultTime2Retain: 32, UsernameLength: 36, PasswordLength: 40,
+		Username: 48, Password: 56,
+	}
+	expectedLoginOptionsLayout32 = LoginOptionsLayout{
+		Size: 52, Align: 4,
+		Version: 0, InformationSpecified: 4, LoginFlags: 8, AuthType: 12, HeaderDigest: 16, DataDigest: 20,
+		MaximumConnections: 24, DefaultTime2Wait: 28, DefaultTime2Retain: 32, UsernameLength: 36, PasswordLength: 40,
+		Username: 44, Password: 48,
+	}
+
+	expectedDeviceLayout = DeviceLayout{
+		Size: 2040, Align: 4,
+		InitiatorName: 0, TargetName: 512, ScsiAddress: 960, DeviceInterfaceType: 968, DeviceInterfaceName: 984,
+		LegacyName: 1504, StorageDeviceNumber: 2024, DeviceInstance: 2036,
+	}
+
+	expectedLayouts = map[string]*Layout{
+		"amd64": {
+			Arch:         "amd64",
+			PointerSize:  8,
+			Uint64Align:  8,
+			LoginOptions: expectedLoginOptionsLayout64,
+			PortalInfo: PortalInfoLayout{
+				Size: 1616, Align: 8,
+				InitiatorName: 0, InitiatorPortNumber: 512, SymbolicName: 516, Address: 1028, Socket: 1540,
+				SecurityFlags: 1544, LoginOptions: 1552,
+			},
+			SessionInfo: SessionInfoLayout{
+				Size: 64, Align: 8,
+				SessionID: 0, InitiatorName: 16, TargetNodeName: 24, TargetName: 32, ISID: 40, TSID: 46,
+				ConnectionCount: 48, Connections: 56,
+			},
+			ConnectionInfo: ConnectionInfoLayout{
+				Size: 40, Align: 8,
+				ConnectionID: 0, InitiatorAddress: 16, TargetAddress: 24, InitiatorSocket: 32, TargetSocket: 34, CID: 36,
+			},
+			Device: expectedDeviceLayout,
+		},
+		"386": {
+			Arch:         "386",
+			PointerSize:  4,
+			Uint64Align:  8,
+			LoginOptions: expectedLoginOptionsLayout32,
+			PortalInfo: PortalInfoLayout{
+				// note that the struct's size is padded to a multiple of 8 because of
+				// the SecurityFlags field, even though pointers are only 4 bytes long
+				Size: 1608, Align: 8,
+				InitiatorName: 0, InitiatorPortNumber: 512, SymbolicName: 516, Address: 1028, Socket: 1540,
+				SecurityFlags: 1544, LoginOptions: 1552,
+			},
+			SessionInfo: SessionInfoLayout{
+				Size: 48, Align: 8,
+				SessionID: 0, InitiatorName: 16, TargetNodeName: 20, TargetName: 24, ISID: 28, TSID: 34,
+				ConnectionCount: 36, Connections: 40,
+			},
+			ConnectionInfo: ConnectionInfoLayout{
+				Size: 32, Align: 8,
+				ConnectionID: 0, InitiatorAddress: 16, TargetAddress: 20, InitiatorSocket: 24, TargetSocket: 26, CID: 28,
+			},
+			Device: expectedDeviceLayout,
+		},
+	}
+)
+
+func init() {
+	// arm64 is identical to amd64, and arm to 386
+	arm64 := *expectedLayouts["amd64"]
+	arm64.Arch = "arm64"
+	expectedLayouts["arm64"] = &arm64
+
+	arm := *expectedLayouts["386"]
+	arm.Arch = "arm"
+	expectedLayouts["arm"] = &arm
+}
+
+func TestLayouts(t *testing.T) {
+	require.Equal(t, len(expectedLayouts), len(Layouts))
+
+	for arch, expected := range expectedLayouts {
+		t.Run(arch, func(t *testing.T) {
+			assert.Equal(t, expected, Layouts[arch])
+		})
+	}
+
+	t.Run("Go's own layout matches the native layout for structs passed to Windows' API", func(t *testing.T) {
+		if NativeLayout == nil {
+			t.Skip("Windows doesn't run on this architecture")
+		}
+
+		opts := LoginOptions{}
+		assert.Equal(t, NativeLayout.LoginOptions.Size, unsafe.Sizeof(opts))
+		assert.Equal(t, NativeLayout.LoginOptions.Username, unsafe.Offsetof(opts.Username))
+		assert.Equal(t, NativeLayout.LoginOptions.Password, unsafe.Offsetof(opts.Password))
+	})
+}
+
+func TestDecoding(t *testing.T) {
+	loginOptions := LoginOptions{
+		Version:              LoginOptionsVersion,
+		InformationSpecified: InformationSpecifiedAuthType | InformationSpecifiedUsername | InformationSpecifiedPassword,
+		LoginFlags:           iscsidsc.LoginFlagMultipathEnabled,
+		AuthType:             iscsidsc.MutualCHAPAuthType,
+		HeaderDigest:         iscsidsc.DigestTypeCRC32C,
+		DataDigest:           iscsidsc.DigestTypeNone,
+		MaximumConnections:   12,
+		DefaultTime2Wait:     28,
+		DefaultTime2Retain:   31,
+		UsernameLength:       8,
+		PasswordLength:       14,
+		Username:             0x12345678,
+		Password:             0x9abcdef0,
+	}
+
+	portalInfo := PortalInfo{
+		InitiatorPortNumber: AllInititatorPorts,
+		Socket:              3260,
+		SecurityFlags:       iscsidsc.SecurityFlagIkeIpsecEnabled | iscsidsc.SecurityFlagTunnelModePreferred,
+		LoginOptions:        loginOptions,
+	}
+	StringToWideChars("ROOT\\ISCSIPRT\\0000_0", portalInfo.InitiatorName[:])
+	StringToWideChars("my-portal", portalInfo.SymbolicName[:])
+	StringToWideChars("10.0.0.5", portalInfo.Address[:])
+
+	sessionInfo := SessionInfo{
+		SessionID:       iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002},
+		InitiatorName:   0x1000,
+		TargetNodeName:  0x2000,
+		TargetName:      0x3000,
+		ISID:            [6]byte{0x40, 0x00, 0x01, 0x37, 0x00, 0x00},
+		TSID:            [2]byte{0x01, 0x02},
+		ConnectionCount: 2,
+		Connections:     0x4000,
+	}
+
+	connectionInfo := ConnectionInfo{
+		ConnectionID:     iscsidsc.ConnectionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000003},
+		InitiatorAddress: 0x5000,
+		TargetAddress:    0x6000,
+		InitiatorSocket:  49152,
+		TargetSocket:     3260,
+		CID:              [2]byte{0x00, 0x01},
+	}
+
+	device := Device{
+		ScsiAddress:         ScsiAddress{Length: 8, PortNumber: 1, PathID: 2, TargetID: 3, Lun: 4},
+		DeviceInterfaceType: GUID{Data1: 0x53f56307, Data2: 0xb6bf, Data3: 0x11d0, Data4: [8]byte{0x94, 0xf2, 0x00, 0xa0, 0xc9, 0x1e, 0xfb, 0x8b}},
+		StorageDeviceNumber: iscsidsc.StorageDeviceNumber{DeviceType: 7, DeviceNumber: 2, PartitionNumber: 0},
+		DeviceInstance:      42,
+	}
+	StringToWideChars("iqn.1991-05.com.microsoft:host", device.InitiatorName[:])
+	StringToWideChars("iqn.1991-05.com.microsoft:target", device.TargetName[:])
+	StringToWideChars("\\\\?\\scsi#disk&ven_msft&prod_virtual_hd#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}", device.DeviceInterfaceName[:])
+	StringToWideChars("\\\\.\\PhysicalDrive2", device.LegacyName[:])
+
+	for arch, layout := range Layouts {
+		t.Run(arch, func(t *testing.T) {
+			t.Run("login options", func(t *testing.T) {
+				buffer := layout.EncodeLoginOptions(&loginOptions)
+				require.Equal(t, int(layout.LoginOptions.Size), len(buffer))
+				assert.Equal(t, &loginOptions, layout.DecodeLoginOptions(buffer))
+			})
+
+			t.Run("portal info", func(t *testing.T) {
+				buffer := layout.EncodePortalInfo(&portalInfo)
+				require.Equal(t, int(layout.PortalInfo.Size), len(buffer))
+				assert.Equal(t, &portalInfo, layout.DecodePortalInfo(buffer))
+			})
+
+			t.Run("session info", func(t *testing.T) {
+				buffer := layout.EncodeSessionInfo(&sessionInfo)
+				require.Equal(t, int(layout.SessionInfo.Size), len(buffer))
+				assert.Equal(t, &sessionInfo, layout.DecodeSessionInfo(buffer))
+			})
+
+			t.Run("connection info", func(t *testing.T) {
+				buffer := layout.EncodeConnectionInfo(&connectionInfo)
+				require.Equal(t, int(layout.ConnectionInfo.Size), len(buffer))
+				assert.Equal(t, &connectionInfo, layout.DecodeConnectionInfo(buffer))
+			})
+
+			t.Run("device", func(t *testing.T) {
+				buffer := layout.EncodeDevice(&device)
+				require.Equal(t, int(layout.Device.Size), len(buffer))
+				assert.Equal(t, &device, layout.DecodeDevice(buffer))
+			})
+		})
+	}
+
+	t.Run("it decodes fields as little-endian, regardless of the host", func(t *testing.T) {
+		layout := Layouts["386"]
+		buffer := make([]byte, layout.SessionInfo.Size)
+		copy(buffer[layout.SessionInfo.SessionID:], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+		copy(buffer[layout.SessionInfo.TargetName:], []byte{0x10, 0x20, 0x30, 0x40})
+		copy(buffer[layout.SessionInfo.ConnectionCount:], []byte{3, 0, 0, 0})
+
+		decoded := layout.DecodeSessionInfo(buffer)
+
+		assert.Equal(t, uint64(0x0807060504030201), decoded.SessionID.AdapterUnique)
+		assert.Equal(t, uintptr(0x40302010), decoded.TargetName)
+		assert.Equal(t, uint32(3), decoded.ConnectionCount)
+	})
+}
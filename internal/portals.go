@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+const reportIScsiSendTargetPortalsExProcName = "ReportIScsiSendTargetPortalsExW"
+
+// hydrateTargetPortalInfos takes the raw bytes returned by the `ReportIscsiSendTargetPortalsEx` C++ proc,
+// and decodes the raw data into Go structs, according to the given layout.
+// Also returns the total number of bytes it's read from the buffer.
+func hydrateTargetPortalInfos(buffer []byte, bufferPointer uintptr, count int, layout *Layout) ([]iscsidsc.PortalInfo, uintptr, error) {
+	// sanity check: the total size should be at least enough to contain the portal infos
+	minimumExpectedSize := count * int(layout.PortalInfo.Size)
+	if len(buffer) < minimumExpectedSize {
+		return nil, 0, hydrateTargetPortalError("expected the reply to be at least %d bytes, only got %d bytes", minimumExpectedSize, len(buffer))
+	}
+
+	portalInfos := make([]iscsidsc.PortalInfo, count)
+	var bytesRead uintptr
+	for i := 0; i < count; i++ {
+		read, err := hydrateTargetPortalInfo(buffer, bufferPointer, i, layout, &portalInfos[i])
+		bytesRead += read
+		if err != nil {
+			return nil, bytesRead, err
+		}
+	}
+
+	return portalInfos, bytesRead, nil
+}
+
+// hydrateTargetPortalInfo hydrates a single `PortalInfo` struct.
+// It returns the number of bytes it's read from the buffer.
+func hydrateTargetPortalInfo(buffer []byte, bufferPointer uintptr, i int, layout *Layout, info *iscsidsc.PortalInfo) (uintptr, error) {
+	// we already know that we're still in the buffer here - we check that at the very start of `hydrateTargetPortalInfos`
+	infoIn := layout.DecodePortalInfo(buffer[uintptr(i)*layout.PortalInfo.Size:])
+	bytesRead := layout.PortalInfo.Size
+
+	info.Portal = *hydratePortal(infoIn)
+	info.InitiatorName = UTF16ToString(infoIn.InitiatorName[:])
+	info.InitiatorPortNumber = infoIn.InitiatorPortNumber
+	info.SecurityFlags = infoIn.SecurityFlags
+
+	loginOptions, read, err := hydrateLoginOptions(&infoIn.LoginOptions, buffer, bufferPointer)
+	info.LoginOptions = *loginOptions
+	bytesRead += read
+	return bytesRead, err
+}
+
+func hydratePortal(infoIn *PortalInfo) *iscsidsc.Portal {
+	socket := infoIn.Socket
+	return &iscsidsc.Portal{
+		SymbolicName: UTF16ToString(infoIn.SymbolicName[:]),
+		Address:      UTF16ToString(infoIn.Address[:]),
+		Socket:       &socket,
+	}
+}
+
+func hydrateLoginOptions(optsIn *LoginOptions, buffer []byte, bufferPointer uintptr) (*iscsidsc.LoginOptions, uintptr, error) {
+	opts := &iscsidsc.LoginOptions{LoginFlags: optsIn.LoginFlags}
+
+	if optsIn.InformationSpecified&InformationSpecifiedAuthType != 0 {
+		authType := optsIn.AuthType
+		opts.AuthType = &authType
+	}
+	if optsIn.InformationSpecified&InformationSpecifiedHeaderDigest != 0 {
+		headerDigest := optsIn.HeaderDigest
+		opts.HeaderDigest = &headerDigest
+	}
+	if optsIn.InformationSpecified&InformationSpecifiedDataDigest != 0 {
+		dataDigest := optsIn.DataDigest
+		opts.DataDigest = &dataDigest
+	}
+	if optsIn.InformationSpecified&InformationSpecifiedMaximumConnections != 0 {
+		maximumConnections := optsIn.MaximumConnections
+		opts.MaximumConnections = &maximumConnections
+	}
+	if optsIn.InformationSpecified&InformationSpecifiedDefaultTime2Wait != 0 {
+		defaultTime2Wait := optsIn.DefaultTime2Wait
+		opts.DefaultTime2Wait = &defaultTime2Wait
+	}
+	if optsIn.InformationSpecified&InformationSpecifiedDefaultTime2Retain != 0 {
+		defaultTime2Retain := optsIn.DefaultTime2Retain
+		opts.DefaultTime2Retain = &defaultTime2Retain
+	}
+
+	var bytesRead uintptr
+	if optsIn.InformationSpecified&InformationSpecifiedUsername != 0 && optsIn.UsernameLength != 0 && optsIn.Username != 0 {
+		username, err := ExtractBytesFromBuffer(buffer, bufferPointer, optsIn.Username, uintptr(optsIn.UsernameLength))
+		if err != nil {
+			return nil, bytesRead, hydrateTargetPortalError(" could not read login username: %v", err)
+		}
+		bytesRead += uintptr(optsIn.UsernameLength)
+		opts.Username = hydrateCredential(username)
+	}
+	if optsIn.InformationSpecified&InformationSpecifiedPassword != 0 && optsIn.PasswordLength != 0 && optsIn.Password != 0 {
+		password, err := ExtractBytesFromBuffer(buffer, bufferPointer, optsIn.Password, uintptr(optsIn.PasswordLength))
+		if err != nil {
+			return nil, bytesRead, hydrateTargetPortalError(" could not read login password: %v", err)
+		}
+		bytesRead += uintptr(optsIn.PasswordLength)
+		opts.Password = hydrateCredential(password)
+	}
+
+	return opts, bytesRead, nil
+}
+
+// hydrateCredential builds a secret from credential bytes returned by Windows' API.
+// Windows doesn't keep track of how credentials were encoded, so this assumes UTF-8 when possible,
+// and falls back to raw bytes otherwise; either way, encoding the result yields the same bytes.
+// Callers knowing that a credential uses another encoding can decode it again with `iscsidsc.DecodeSecret`.
+func hydrateCredential(encoded []byte) *iscsidsc.Secret {
+	if secret, err := iscsidsc.DecodeSecret(encoded, iscsidsc.CredentialEncodingUTF8); err == nil {
+		return secret
+	}
+	secret, _ := iscsidsc.DecodeSecret(encoded, iscsidsc.CredentialEncodingRaw)
+	return secret
+}
+
+func hydrateTargetPortalError(format string, args ...interface{}) error {
+	msg := fmt.Sprintf("Error when hydrating the response from %s - it might be that your Windows version is not supported: ", reportIScsiSendTargetPortalsExProcName)
+	return errors.Errorf(msg+format, args...)
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestHydrateTargetPortalInfos(t *testing.T) {
+	socket := uint16(3260)
+	otherSocket := uint16(3261)
+	authType := iscsidsc.CHAPAuthType
+	dataDigest := iscsidsc.DigestTypeCRC32C
+	defaultTime2Wait := uint32(28)
+	username := iscsidsc.NewSecretFromString("username")
+	password := iscsidsc.NewSecretFromString("passwordpassword")
+
+	portalInfos := []iscsidsc.PortalInfo{
+		{
+			Portal: iscsidsc.Portal{
+				SymbolicName: "portal-1",
+				Address:      "10.0.0.5",
+				Socket:       &socket,
+			},
+			InitiatorName:       "ROOT\\ISCSIPRT\\0000_0",
+			InitiatorPortNumber: AllInititatorPorts,
+			SecurityFlags:       iscsidsc.SecurityFlagIkeIpsecEnabled | iscsidsc.SecurityFlagTransportModePreferred,
+			LoginOptions: iscsidsc.LoginOptions{
+				LoginFlags:       iscsidsc.LoginFlagMultipathEnabled,
+				AuthType:         &authType,
+				DataDigest:       &dataDigest,
+				DefaultTime2Wait: &defaultTime2Wait,
+				Username:         username,
+				Password:         password,
+			},
+		},
+		{
+			Portal: iscsidsc.Portal{
+				Address: "array.example.com",
+				Socket:  &otherSocket,
+			},
+			InitiatorPortNumber: 1,
+		},
+	}
+
+	for arch, layout := range Layouts {
+		t.Run(arch, func(t *testing.T) {
+			bufferPointer := uintptr(0x10000)
+			buffer := buildPortalInfosBuffer(layout, bufferPointer, portalInfos)
+
+			hydrated, bytesRead, err := hydrateTargetPortalInfos(buffer, bufferPointer, len(portalInfos), layout)
+
+			require.Nil(t, err)
+			assert.Equal(t, portalInfos, hydrated)
+			assert.Equal(t, uintptr(len(buffer)), bytesRead)
+		})
+	}
+
+	t.Run("with credentials that aren't UTF-8", func(t *testing.T) {
+		latin1Username, err := iscsidsc.NewEncodedSecret([]byte("café"), iscsidsc.CredentialEncodingLatin1)
+		require.Nil(t, err)
+		hexPassword, err := iscsidsc.NewEncodedSecret([]byte("0x00ff00ff00ff00ff00ff00ff"), iscsidsc.CredentialEncodingHex)
+		require.Nil(t, err)
+
+		portalInfo := portalInfos[0]
+		portalInfo.LoginOptions.Username = latin1Username
+		portalInfo.LoginOptions.Password = hexPassword
+
+		for arch, layout := range Layouts {
+			t.Run(arch, func(t *testing.T) {
+				bufferPointer := uintptr(0x10000)
+				buffer := buildPortalInfosBuffer(layout, bufferPointer, []iscsidsc.PortalInfo{portalInfo})
+
+				hydrated, _, err := hydrateTargetPortalInfos(buffer, bufferPointer, 1, layout)
+				require.Nil(t, err)
+				require.Equal(t, 1, len(hydrated))
+
+				// Windows doesn't know about encodings, so these come back as raw bytes...
+				hydratedOpts := hydrated[0].LoginOptions
+				assert.Equal(t, iscsidsc.CredentialEncodingRaw, hydratedOpts.Username.Encoding())
+				assert.Equal(t, iscsidsc.CredentialEncodingRaw, hydratedOpts.Password.Encoding())
+
+				// ... that can be decoded back to the original values
+				for _, pair := range []struct{ original, hydrated *iscsidsc.Secret }{
+					{latin1Username, hydratedOpts.Username},
+					{hexPassword, hydratedOpts.Password},
+				} {
+					encoded, err := pair.hydrated.Encode()
+					require.Nil(t, err)
+					decoded, err := iscsidsc.DecodeSecret(encoded, pair.original.Encoding())
+					require.Nil(t, err)
+					assert.Equal(t, pair.original, decoded)
+				}
+			})
+		}
+	})
+
+	t.Run("with too short a buffer", func(t *testing.T) {
+		layout := Layouts["386"]
+		buffer := buildPortalInfosBuffer(layout, 0, portalInfos)
+
+		_, _, err := hydrateTargetPortalInfos(buffer[:layout.PortalInfo.Size], 0, len(portalInfos), layout)
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "expected the reply to be at least 3216 bytes, only got 1608 bytes")
+		}
+	})
+}
+
+// buildPortalInfosBuffer builds a buffer similar to what `ReportIScsiSendTargetPortalsExW` would return for
+// the given portals on the given architecture: all portal infos first, then the login options' usernames
+// and passwords.
+func buildPortalInfosBuffer(layout *Layout, bufferPointer uintptr, portalInfos []iscsidsc.PortalInfo) []byte {
+	buffer := make([]byte, uintptr(len(portalInfos))*layout.PortalInfo.Size)
+
+	addSecret := func(s *iscsidsc.Secret) (uintptr, uint32) {
+		if s == nil {
+			return 0, 0
+		}
+		encoded, err := s.Encode()
+		if err != nil {
+			panic(err)
+		}
+		pointer := bufferPointer + uintptr(len(buffer))
+		buffer = append(buffer, encoded...)
+		return pointer, uint32(len(encoded))
+	}
+
+	for i, portalInfo := range portalInfos {
+		loginOptions, _, _, err := CheckAndConvertLoginOptions(&portalInfo.LoginOptions)
+		if err != nil {
+			panic(err)
+		}
+		loginOptions.Username, _ = addSecret(portalInfo.LoginOptions.Username)
+		loginOptions.Password, _ = addSecret(portalInfo.LoginOptions.Password)
+
+		portal, err := CheckAndConvertPortal(&portalInfo.Portal)
+		if err != nil {
+			panic(err)
+		}
+
+		infoIn := &PortalInfo{
+			InitiatorPortNumber: portalInfo.InitiatorPortNumber,
+			SymbolicName:        portal.SymbolicName,
+			Address:             portal.Address,
+			Socket:              portal.Socket,
+			SecurityFlags:       portalInfo.SecurityFlags,
+			LoginOptions:        *loginOptions,
+		}
+		StringToWideChars(portalInfo.InitiatorName, infoIn.InitiatorName[:])
+
+		copy(buffer[uintptr(i)*layout.PortalInfo.Size:], layout.EncodePortalInfo(infoIn))
+	}
+
+	return buffer
+}
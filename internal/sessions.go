@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+const getIScsiSessionListProcName = "GetIScsiSessionListW"
+
+// hydrateSessionInfos takes the raw bytes returned by the `GetIScsiSessionListW` C++ proc,
+// and decodes the raw data into Go structs, according to the given layout.
+// Also returns the total number of bytes it's read from the buffer.
+func hydrateSessionInfos(buffer []byte, bufferPointer uintptr, count int, layout *Layout) ([]iscsidsc.SessionInfo, uintptr, error) {
+	// sanity check: the total size should be at least enough to contain the session infos
+	minimumExpectedSize := count * int(layout.SessionInfo.Size)
+	if len(buffer) < minimumExpectedSize {
+		return nil, 0, hydrateSessionError("expected the reply to be at least %d bytes, only got %d bytes", minimumExpectedSize, len(buffer))
+	}
+
+	sessions := make([]iscsidsc.SessionInfo, count)
+	var bytesRead uintptr
+	for i := 0; i < count; i++ {
+		read, err := hydrateSessionInfo(buffer, bufferPointer, i, layout, &sessions[i])
+		bytesRead += read
+		if err != nil {
+			return nil, bytesRead, err
+		}
+	}
+
+	return sessions, bytesRead, nil
+}
+
+// hydrateSessionInfo hydrates a single `SessionInfo` struct.
+// It returns the number of bytes it's read from the buffer.
+func hydrateSessionInfo(buffer []byte, bufferPointer uintptr, i int, layout *Layout, info *iscsidsc.SessionInfo) (uintptr, error) {
+	// we already know that we're still in the buffer here - we check that at the very start of `hydrateSessionInfos`
+	infoIn := layout.DecodeSessionInfo(buffer[uintptr(i)*layout.SessionInfo.Size:])
+	bytesRead := layout.SessionInfo.Size
+
+	info.SessionID = infoIn.SessionID
+
+	initiatorName, read, err := ExtractWideStringFromBuffer(buffer, bufferPointer, infoIn.InitiatorName)
+	bytesRead += read
+	if err != nil {
+		return bytesRead, hydrateSessionError(" could not read session initiator name: %v", err)
+	}
+	info.InitiatorName = initiatorName
+
+	targetNodeName, read, err := ExtractWideStringFromBuffer(buffer, bufferPointer, infoIn.TargetNodeName)
+	bytesRead += read
+	if err != nil {
+		return bytesRead, hydrateSessionError(" could not read session target node name: %v", err)
+	}
+	info.TargetNodeName = targetNodeName
+
+	targetName, read, err := ExtractWideStringFromBuffer(buffer, bufferPointer, infoIn.TargetName)
+	bytesRead += read
+	if err != nil {
+		return bytesRead, hydrateSessionError(" could not read session target name: %v", err)
+	}
+	info.TargetName = targetName
+
+	info.ISID = infoIn.ISID
+	info.TSID = infoIn.TSID
+
+	if infoIn.ConnectionCount > 0 && infoIn.Connections != 0 {
+		connectionsOffset := infoIn.Connections - bufferPointer
+
+		// sanity check: this should still be inside the buffer
+		if connectionsOffset < 0 || connectionsOffset >= uintptr(len(buffer)) {
+			return bytesRead, hydrateSessionError("connections pointer pointing out of the buffer")
+		}
+
+		connections, read, err := hydrateConnectionInfos(
+			buffer,
+			bufferPointer,
+			connectionsOffset,
+			int(infoIn.ConnectionCount),
+			layout,
+		)
+		bytesRead += read
+		if err != nil {
+			return bytesRead, err
+		}
+		info.Connections = connections
+	}
+
+	return bytesRead, nil
+}
+
+func hydrateConnectionInfos(buffer []byte, bufferPointer, connectionsOffset uintptr, connectionCount int, layout *Layout) ([]iscsidsc.ConnectionInfo, uintptr, error) {
+	// sanity check: the total size should be at least enough to contain the connection infos
+	minimumExpectedSize := connectionCount * int(layout.ConnectionInfo.Size)
+	if len(buffer)-int(connectionsOffset) < minimumExpectedSize {
+		return nil, 0, hydrateSessionError("expected the buffer for connections to be at least %d bytes, only got %d bytes", minimumExpectedSize, len(buffer)-int(connectionsOffset))
+	}
+
+	connections := make([]iscsidsc.ConnectionInfo, connectionCount)
+	var bytesRead uintptr
+	for i := 0; i < connectionCount; i++ {
+		read, err := hydrateConnectionInfo(buffer, bufferPointer, connectionsOffset, i, layout, &connections[i])
+		bytesRead += read
+		if err != nil {
+			return nil, bytesRead, err
+		}
+	}
+
+	return connections, bytesRead, nil
+}
+
+func hydrateConnectionInfo(buffer []byte, bufferPointer, connectionsOffset uintptr, i int, layout *Layout, info *iscsidsc.ConnectionInfo) (uintptr, error) {
+	infoIn := layout.DecodeConnectionInfo(buffer[connectionsOffset+uintptr(i)*layout.ConnectionInfo.Size:])
+	bytesRead := layout.ConnectionInfo.Size
+
+	info.ConnectionID = infoIn.ConnectionID
+
+	initiatorAddress, read, err := ExtractWideStringFromBuffer(buffer, bufferPointer, infoIn.InitiatorAddress)
+	bytesRead += read
+	if err != nil {
+		return bytesRead, hydrateSessionError(" could not read connection initiator address: %v", err)
+	}
+	info.InitiatorAddress = initiatorAddress
+
+	targetAddress, read, err := ExtractWideStringFromBuffer(buffer, bufferPointer, infoIn.TargetAddress)
+	bytesRead += read
+	if err != nil {
+		return bytesRead, hydrateSessionError(" could not read connection target address: %v", err)
+	}
+	info.TargetAddress = targetAddress
+
+	info.InitiatorSocket = infoIn.InitiatorSocket
+	info.TargetSocket = infoIn.TargetSocket
+	info.CID = infoIn.CID
+
+	return bytesRead, nil
+}
+
+func hydrateSessionError(format string, args ...interface{}) error {
+	msg := fmt.Sprintf("Error when hydrating the response from %s - it might be that your Windows version is not supported: ", getIScsiSessionListProcName)
+	return errors.Errorf(msg+format, args...)
+}
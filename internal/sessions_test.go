@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestHydrateSessionInfos(t *testing.T) {
+	sessions := []iscsidsc.SessionInfo{
+		{
+			SessionID:      iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002},
+			InitiatorName:  "ROOT\\ISCSIPRT\\0000_0",
+			TargetNodeName: "iqn.1991-05.com.microsoft:target-1",
+			TargetName:     "iqn.1991-05.com.microsoft:target-1",
+			ISID:           [6]byte{0x40, 0x00, 0x01, 0x37, 0x00, 0x00},
+			TSID:           [2]byte{0x01, 0x00},
+			Connections: []iscsidsc.ConnectionInfo{
+				{
+					ConnectionID:     iscsidsc.ConnectionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000003},
+					InitiatorAddress: "0.0.0.0",
+					TargetAddress:    "10.0.0.5",
+					InitiatorSocket:  49152,
+					TargetSocket:     3260,
+					CID:              [2]byte{0x01, 0x00},
+				},
+				{
+					ConnectionID:     iscsidsc.ConnectionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000004},
+					InitiatorAddress: "0.0.0.0",
+					TargetAddress:    "10.0.0.6",
+					InitiatorSocket:  49153,
+					TargetSocket:     3261,
+					CID:              [2]byte{0x02, 0x00},
+				},
+			},
+		},
+		{
+			SessionID:      iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000005},
+			InitiatorName:  "ROOT\\ISCSIPRT\\0000_0",
+			TargetNodeName: "iqn.1991-05.com.microsoft:target-2",
+			TargetName:     "iqn.1991-05.com.microsoft:target-2",
+			ISID:           [6]byte{0x40, 0x00, 0x01, 0x37, 0x00, 0x01},
+			TSID:           [2]byte{0x02, 0x00},
+		},
+	}
+
+	for arch, layout := range Layouts {
+		t.Run(arch, func(t *testing.T) {
+			bufferPointer := uintptr(0x10000)
+			buffer := buildSessionListBuffer(layout, bufferPointer, sessions)
+
+			hydrated, bytesRead, err := hydrateSessionInfos(buffer, bufferPointer, len(sessions), layout)
+
+			require.Nil(t, err)
+			assert.Equal(t, sessions, hydrated)
+			assert.Equal(t, uintptr(len(buffer)), bytesRead)
+		})
+	}
+
+	t.Run("with too short a buffer", func(t *testing.T) {
+		layout := Layouts["amd64"]
+		buffer := buildSessionListBuffer(layout, 0, sessions)
+
+		_, _, err := hydrateSessionInfos(buffer[:layout.SessionInfo.Size], 0, len(sessions), layout)
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "expected the reply to be at least 128 bytes, only got 64 bytes")
+		}
+	})
+
+	t.Run("with a connections pointer pointing out of the buffer", func(t *testing.T) {
+		layout := Layouts["386"]
+		buffer := buildSessionListBuffer(layout, 0x10000, sessions[:1])
+		// make the connections pointer point after the buffer
+		copy(buffer[layout.SessionInfo.Connections:], []byte{0xff, 0xff, 0xff, 0xff})
+
+		_, _, err := hydrateSessionInfos(buffer, 0x10000, 1, layout)
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "connections pointer pointing out of the buffer")
+		}
+	})
+}
+
+// buildSessionListBuffer builds a buffer similar to what `GetIScsiSessionListW` would return for
+// the given sessions on the given architecture: all session infos first, then connection infos,
+// then all strings.
+func buildSessionListBuffer(layout *Layout, bufferPointer uintptr, sessions []iscsidsc.SessionInfo) []byte {
+	connectionCount := 0
+	for _, session := range sessions {
+		connectionCount += len(session.Connections)
+	}
+
+	sessionsSize := uintptr(len(sessions)) * layout.SessionInfo.Size
+	connectionsSize := uintptr(connectionCount) * layout.ConnectionInfo.Size
+	buffer := make([]byte, sessionsSize+connectionsSize)
+
+	addString := func(s string) uintptr {
+		pointer := bufferPointer + uintptr(len(buffer))
+		buffer = append(buffer, StringToUTF16ByteBuffer(s)...)
+		return pointer
+	}
+
+	connectionsOffset := sessionsSize
+	for i, session := range sessions {
+		sessionIn := &SessionInfo{
+			SessionID:       session.SessionID,
+			InitiatorName:   addString(session.InitiatorName),
+			TargetNodeName:  addString(session.TargetNodeName),
+			TargetName:      addString(session.TargetName),
+			ISID:            session.ISID,
+			TSID:            session.TSID,
+			ConnectionCount: uint32(len(session.Connections)),
+		}
+		if len(session.Connections) != 0 {
+			sessionIn.Connections = bufferPointer + connectionsOffset
+		}
+
+		for _, connection := range session.Connections {
+			connectionIn := &ConnectionInfo{
+				ConnectionID:     connection.ConnectionID,
+				InitiatorAddress: addString(connection.InitiatorAddress),
+				TargetAddress:    addString(connection.TargetAddress),
+				InitiatorSocket:  connection.InitiatorSocket,
+				TargetSocket:     connection.TargetSocket,
+				CID:              connection.CID,
+			}
+			copy(buffer[connectionsOffset:], layout.EncodeConnectionInfo(connectionIn))
+			connectionsOffset += layout.ConnectionInfo.Size
+		}
+
+		copy(buffer[uintptr(i)*layout.SessionInfo.Size:], layout.EncodeSessionInfo(sessionIn))
+	}
+
+	return buffer
+}
@@ -0,0 +1,66 @@
+package internal
+
+// This file contains platform-independent equivalents to the string helpers from `golang.org/x/sys/windows`,
+// so that converting to and from Windows' API types works on any platform.
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+var errNullCharacter = errors.New("string contains a null character")
+
+// UTF16FromString returns the UTF-16 encoding of s, with a terminating null character added.
+// It errors out if s contains a null character.
+func UTF16FromString(s string) ([]uint16, error) {
+	if strings.IndexByte(s, 0) != -1 {
+		return nil, errNullCharacter
+	}
+	return utf16.Encode([]rune(s + "\x00")), nil
+}
+
+// UTF16PtrFromString returns a pointer to the UTF-16 encoding of s, with a terminating null character added.
+// It errors out if s contains a null character.
+func UTF16PtrFromString(s string) (*uint16, error) {
+	a, err := UTF16FromString(s)
+	if err != nil {
+		return nil, err
+	}
+	return &a[0], nil
+}
+
+// UTF16ToString returns the string encoded in s, stopping at the first null character if any.
+func UTF16ToString(s []uint16) string {
+	for i, char := range s {
+		if char == 0 {
+			s = s[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(s))
+}
+
+// BytePtrFromString returns a pointer to a null-terminated copy of s.
+// It errors out if s contains a null character.
+func BytePtrFromString(s string) (*byte, error) {
+	if strings.IndexByte(s, 0) != -1 {
+		return nil, errNullCharacter
+	}
+	a := make([]byte, len(s)+1)
+	copy(a, s)
+	return &a[0], nil
+}
+
+// WipeBytePtr zeroes the size bytes starting at p, e.g. a copy of a secret.
+func WipeBytePtr(p *byte, size int) {
+	if p == nil || size <= 0 {
+		return
+	}
+	a := (*[1 << 30]byte)(unsafe.Pointer(p))[:size:size]
+	for i := range a {
+		a[i] = 0
+	}
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTF16Helpers(t *testing.T) {
+	for _, input := range []string{"", "foo", "&=)", "Ŋ", "fŏŎ", "𝄞"} {
+		t.Run("round trip with "+input, func(t *testing.T) {
+			encoded, err := UTF16FromString(input)
+			require.Nil(t, err)
+			assert.Equal(t, uint16(0), encoded[len(encoded)-1])
+
+			assert.Equal(t, input, UTF16ToString(encoded))
+		})
+	}
+
+	t.Run("it stops at the first null character when decoding", func(t *testing.T) {
+		assert.Equal(t, "ab", UTF16ToString([]uint16{'a', 'b', 0, 'c', 0}))
+	})
+
+	t.Run("it decodes strings without a null character", func(t *testing.T) {
+		assert.Equal(t, "abc", UTF16ToString([]uint16{'a', 'b', 'c'}))
+	})
+
+	t.Run("it refuses to encode strings containing null characters", func(t *testing.T) {
+		encoded, err := UTF16FromString("foo\x00bar")
+		assert.Nil(t, encoded)
+		assert.Equal(t, errNullCharacter, err)
+
+		ptr, err := UTF16PtrFromString("\x00")
+		assert.Nil(t, ptr)
+		assert.Equal(t, errNullCharacter, err)
+	})
+}
+
+func TestBytePtrFromString(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		input := "coucou"
+		ptr, err := BytePtrFromString(input)
+		require.Nil(t, err)
+
+		bytes := (*[7]byte)(unsafe.Pointer(ptr))
+		assert.Equal(t, input, string(bytes[:6]))
+		assert.Equal(t, byte(0), bytes[6])
+	})
+
+	t.Run("it refuses strings containing null characters", func(t *testing.T) {
+		ptr, err := BytePtrFromString("cou\x00cou")
+		assert.Nil(t, ptr)
+		assert.Equal(t, errNullCharacter, err)
+	})
+}
+
+func TestWipeBytePtr(t *testing.T) {
+	input := "coucou"
+	ptr, err := BytePtrFromString(input)
+	require.Nil(t, err)
+
+	WipeBytePtr(ptr, 7)
+
+	bytes := (*[7]byte)(unsafe.Pointer(ptr))
+	assert.Equal(t, [7]byte{}, *bytes)
+	assert.Equal(t, "coucou", input)
+
+	// should be a no-op
+	WipeBytePtr(nil, 12)
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"github.com/pkg/errors"
+)
+
+const reportIScsiTargetsProcName = "ReportIScsiTargetsW"
+
+var invalidIscsiTargetsOutput = errors.Errorf("Error when parsing the response from %q: invalid output", reportIScsiTargetsProcName)
+
+// parseIscsiTargets parses the output from retrieveIscsiTargets, which is
+// a list of UTF16-encoded, null-terminated strings; and the last string is
+// double null-terminated
+// Note that in practice there can be any amount of random bytes past the final
+// double null character.
+func parseIscsiTargets(buffer []byte) ([]string, error) {
+	// no matter what, this buffer can't be shorter than 4 bytes (2 null wide chars)
+	if len(buffer) < 4 {
+		return nil, invalidIscsiTargetsOutput
+	}
+
+	targets := make([]string, 0)
+	offset := uintptr(0)
+
+	for {
+		target, read, err := ExtractWideStringFromBuffer(buffer, 0, offset)
+		if err != nil {
+			return nil, invalidIscsiTargetsOutput
+		}
+		if target == "" {
+			if offset != 0 || (buffer[2] == 0 && buffer[3] == 0) {
+				// we've found the double null char, we're done
+				return targets, nil
+			}
+			// the buffer started with a null char, but the next char wasn't a null char
+			return nil, invalidIscsiTargetsOutput
+		}
+		targets = append(targets, target)
+		offset += read
+	}
+}
@@ -1,11 +1,10 @@
-package target
+package internal
 
 import (
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/wk8/go-win-iscsidsc/internal"
 )
 
 func TestParseIscsiTargets(t *testing.T) {
@@ -65,7 +64,7 @@ func buildIscsiTargetsOutput(targets ...string) []byte {
 	result := make([]byte, 0)
 
 	for _, target := range targets {
-		result = append(result, internal.StringToUTF16ByteBuffer(target)...)
+		result = append(result, StringToUTF16ByteBuffer(target)...)
 	}
 	// add a wide null byte
 	result = append(result, 0, 0)
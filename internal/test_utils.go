@@ -3,7 +3,6 @@ package internal
 import (
 	"encoding/binary"
 	"unicode/utf16"
-	"unsafe"
 )
 
 // IterateOverAllSubsets will call f with all the 2^n - 1 (unordered) subsets of {0,1,2,...,n}.
@@ -32,24 +31,133 @@ func StringToUTF16ByteBuffer(s string) []byte {
 	utf16bytes := utf16.Encode([]rune(s + "\x00"))
 	result := make([]byte, 2*len(utf16bytes))
 	for i, utf16byte := range utf16bytes {
-		endianness.PutUint16(result[2*i:], utf16byte)
+		binary.LittleEndian.PutUint16(result[2*i:], utf16byte)
 	}
 	return result
 }
 
-var endianness = determineEndiannness()
+// The Encode* functions below are the reverse of the Decode* functions from decoding.go;
+// they allow building buffers similar to the ones Windows' API would return on any architecture.
 
-// shamelessly stolen from https://github.com/tensorflow/tensorflow/blob/v2.0.0-beta1/tensorflow/go/tensor.go#L488-L505
-func determineEndiannness() binary.ByteOrder {
-	buf := [2]byte{}
-	*(*uint16)(unsafe.Pointer(&buf[0])) = uint16(0xABCD)
+// EncodeLoginOptions encodes a `LoginOptions` struct as a `ISCSI_LOGIN_OPTIONS` C++ struct.
+func (l *Layout) EncodeLoginOptions(opts *LoginOptions) []byte {
+	lo := &l.LoginOptions
+	b := make([]byte, lo.Size)
+	writeUint32(b, lo.Version, opts.Version)
+	writeUint32(b, lo.InformationSpecified, uint32(opts.InformationSpecified))
+	writeUint32(b, lo.LoginFlags, uint32(opts.LoginFlags))
+	writeUint32(b, lo.AuthType, uint32(opts.AuthType))
+	writeUint32(b, lo.HeaderDigest, uint32(opts.HeaderDigest))
+	writeUint32(b, lo.DataDigest, uint32(opts.DataDigest))
+	writeUint32(b, lo.MaximumConnections, opts.MaximumConnections)
+	writeUint32(b, lo.DefaultTime2Wait, opts.DefaultTime2Wait)
+	writeUint32(b, lo.DefaultTime2Retain, opts.DefaultTime2Retain)
+	writeUint32(b, lo.UsernameLength, opts.UsernameLength)
+	writeUint32(b, lo.PasswordLength, opts.PasswordLength)
+	l.writePointer(b, lo.Username, opts.Username)
+	l.writePointer(b, lo.Password, opts.Password)
+	return b
+}
+
+// EncodePortalInfo encodes a `PortalInfo` struct as a `ISCSI_TARGET_PORTAL_INFO_EXW` C++ struct.
+func (l *Layout) EncodePortalInfo(info *PortalInfo) []byte {
+	pi := &l.PortalInfo
+	b := make([]byte, pi.Size)
+	writeWideChars(b, pi.InitiatorName, info.InitiatorName[:])
+	writeUint32(b, pi.InitiatorPortNumber, info.InitiatorPortNumber)
+	writeWideChars(b, pi.SymbolicName, info.SymbolicName[:])
+	writeWideChars(b, pi.Address, info.Address[:])
+	writeUint16(b, pi.Socket, info.Socket)
+	writeUint64(b, pi.SecurityFlags, uint64(info.SecurityFlags))
+	copy(b[pi.LoginOptions:], l.EncodeLoginOptions(&info.LoginOptions))
+	return b
+}
+
+// EncodeSessionInfo encodes a `SessionInfo` struct as a `ISCSI_SESSION_INFOW` C++ struct.
+func (l *Layout) EncodeSessionInfo(info *SessionInfo) []byte {
+	si := &l.SessionInfo
+	b := make([]byte, si.Size)
+	writeUniqueID(b, si.SessionID, uniqueID(info.SessionID))
+	l.writePointer(b, si.InitiatorName, info.InitiatorName)
+	l.writePointer(b, si.TargetNodeName, info.TargetNodeName)
+	l.writePointer(b, si.TargetName, info.TargetName)
+	copy(b[si.ISID:], info.ISID[:])
+	copy(b[si.TSID:], info.TSID[:])
+	writeUint32(b, si.ConnectionCount, info.ConnectionCount)
+	l.writePointer(b, si.Connections, info.Connections)
+	return b
+}
+
+// EncodeConnectionInfo encodes a `ConnectionInfo` struct as a `ISCSI_CONNECTION_INFOW` C++ struct.
+func (l *Layout) EncodeConnectionInfo(info *ConnectionInfo) []byte {
+	ci := &l.ConnectionInfo
+	b := make([]byte, ci.Size)
+	writeUniqueID(b, ci.ConnectionID, uniqueID(info.ConnectionID))
+	l.writePointer(b, ci.InitiatorAddress, info.InitiatorAddress)
+	l.writePointer(b, ci.TargetAddress, info.TargetAddress)
+	writeUint16(b, ci.InitiatorSocket, info.InitiatorSocket)
+	writeUint16(b, ci.TargetSocket, info.TargetSocket)
+	copy(b[ci.CID:], info.CID[:])
+	return b
+}
+
+// EncodeDevice encodes a `Device` struct as a `ISCSI_DEVICE_ON_SESSIONW` C++ struct.
+func (l *Layout) EncodeDevice(device *Device) []byte {
+	d := &l.Device
+	b := make([]byte, d.Size)
+	writeWideChars(b, d.InitiatorName, device.InitiatorName[:])
+	writeWideChars(b, d.TargetName, device.TargetName[:])
+	writeUint32(b, d.ScsiAddress, device.ScsiAddress.Length)
+	b[d.ScsiAddress+4] = device.ScsiAddress.PortNumber
+	b[d.ScsiAddress+5] = device.ScsiAddress.PathID
+	b[d.ScsiAddress+6] = device.ScsiAddress.TargetID
+	b[d.ScsiAddress+7] = device.ScsiAddress.Lun
+	writeUint32(b, d.DeviceInterfaceType, device.DeviceInterfaceType.Data1)
+	writeUint16(b, d.DeviceInterfaceType+4, device.DeviceInterfaceType.Data2)
+	writeUint16(b, d.DeviceInterfaceType+6, device.DeviceInterfaceType.Data3)
+	copy(b[d.DeviceInterfaceType+8:], device.DeviceInterfaceType.Data4[:])
+	writeWideChars(b, d.DeviceInterfaceName, device.DeviceInterfaceName[:])
+	writeWideChars(b, d.LegacyName, device.LegacyName[:])
+	writeUint32(b, d.StorageDeviceNumber, uint32(device.StorageDeviceNumber.DeviceType))
+	writeUint32(b, d.StorageDeviceNumber+4, device.StorageDeviceNumber.DeviceNumber)
+	writeUint32(b, d.StorageDeviceNumber+8, device.StorageDeviceNumber.PartitionNumber)
+	writeUint32(b, d.DeviceInstance, device.DeviceInstance)
+	return b
+}
+
+// StringToWideChars converts a string to a fixed-size, null-terminated array of UTF16 characters,
+// as found in Windows' API structs.
+func StringToWideChars(s string, dst []uint16) {
+	copy(dst, utf16.Encode([]rune(s+"\x00")))
+}
+
+func writeUniqueID(b []byte, offset uintptr, id uniqueID) {
+	writeUint64(b, offset, id.AdapterUnique)
+	writeUint64(b, offset+8, id.AdapterSpecific)
+}
+
+func (l *Layout) writePointer(b []byte, offset uintptr, pointer uintptr) {
+	if l.PointerSize == 4 {
+		writeUint32(b, offset, uint32(pointer))
+	} else {
+		writeUint64(b, offset, uint64(pointer))
+	}
+}
+
+func writeUint16(b []byte, offset uintptr, value uint16) {
+	binary.LittleEndian.PutUint16(b[offset:], value)
+}
+
+func writeUint32(b []byte, offset uintptr, value uint32) {
+	binary.LittleEndian.PutUint32(b[offset:], value)
+}
+
+func writeUint64(b []byte, offset uintptr, value uint64) {
+	binary.LittleEndian.PutUint64(b[offset:], value)
+}
 
-	switch buf {
-	case [2]byte{0xCD, 0xAB}:
-		return binary.LittleEndian
-	case [2]byte{0xAB, 0xCD}:
-		return binary.BigEndian
-	default:
-		panic("Could not determine native endianness.")
+func writeWideChars(b []byte, offset uintptr, src []uint16) {
+	for i, char := range src {
+		writeUint16(b, offset+2*uintptr(i), char)
 	}
 }
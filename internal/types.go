@@ -3,11 +3,12 @@ package internal
 // This file contains the types and constants used internally throughout this repo.
 // We need separates struct from the public-facing structs to be able
 // to distinguish which fields have been set by the caller - and still have the
-// same memory layout as the C++ struct.
+// same memory layout as the C++ struct, for those structs that we pass to Windows' API.
+// The structs that we only read from Windows' API replies are decoded field by field,
+// see layout.go.
 
 import (
 	"math"
-	"unsafe"
 
 	iscsidsc "github.com/wk8/go-win-iscsidsc"
 )
@@ -72,7 +73,7 @@ const MaxIscsiPortalAddressLen = 256
 
 // DefaultPortalPortNumber is the default port on which target portals are expected
 // to be listening on if no other port is explicitly provided.
-const DefaultPortalPortNumber uint16 = 3260
+const DefaultPortalPortNumber = iscsidsc.DefaultPortalSocket
 
 // MaxHbaNameLen maps to the `MAX_ISCSI_HBANAME_LEN` C++ constant.
 const MaxHbaNameLen = 256
@@ -95,13 +96,6 @@ type PortalInfo struct {
 	LoginOptions        LoginOptions
 }
 
-var (
-	emptyPrivatePortalInfo = PortalInfo{}
-
-	// PortalInfoSize is the size, in bytes, of the internal `PortalInfo` type.
-	PortalInfoSize = unsafe.Sizeof(emptyPrivatePortalInfo)
-)
-
 // ConnectionInfo maps to the `ISCSI_CONNECTION_INFOW` C++ struct.
 // see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/ns-iscsidsc-iscsi_connection_infow
 type ConnectionInfo struct {
@@ -113,12 +107,6 @@ type ConnectionInfo struct {
 	CID              [2]byte
 }
 
-var (
-	emptyConnectionInfo = ConnectionInfo{}
-	// ConnectionInfoSize is the size, in bytes, of the internal `ConnectionInfo` type.
-	ConnectionInfoSize = unsafe.Sizeof(emptyConnectionInfo)
-)
-
 // SessionInfo maps to the `ISCSI_SESSION_INFOW` C++ struct.
 // see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/ns-iscsidsc-iscsi_session_infow
 type SessionInfo struct {
@@ -132,12 +120,6 @@ type SessionInfo struct {
 	Connections     uintptr
 }
 
-var (
-	emptySessionInfo = SessionInfo{}
-	// SessionInfoSize is the size, in bytes, of the internal `SessionInfo` type.
-	SessionInfoSize = unsafe.Sizeof(emptySessionInfo)
-)
-
 // Device maps to the `ISCSI_DEVICE_ON_SESSIONW` C++ struct.
 // see https://docs.microsoft.com/en-us/windows/win32/api/iscsidsc/ns-iscsidsc-iscsi_device_on_sessionw
 type Device struct {
@@ -151,12 +133,6 @@ type Device struct {
 	DeviceInstance      uint32
 }
 
-var (
-	emptyDevice = Device{}
-	// DeviceSize is the size, in bytes, of the internal `Device` type.
-	DeviceSize = unsafe.Sizeof(emptyDevice)
-)
-
 // GUID maps to the `GUID` C++ struct
 type GUID struct {
 	Data1 uint32
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// winAPI makes calls to the procs of an iscsidsc DLL.
+type winAPI struct {
+	// TODO: we could (should?) check the version
+	dll         *windows.LazyDLL
+	procsPrefix string
+	// initialBufferSize is the size of the buffer used for the 1st call to APIs that need one.
+	initialBufferSize uintptr
+}
+
+func newWinAPI(config iscsidsc.BackendConfig) *winAPI {
+	var dll *windows.LazyDLL
+	if filepath.Base(config.DLLPath) == config.DLLPath {
+		// only look up base names in the system directory
+		dll = windows.NewLazySystemDLL(config.DLLPath)
+	} else {
+		dll = windows.NewLazyDLL(config.DLLPath)
+	}
+
+	return &winAPI{
+		dll:               dll,
+		procsPrefix:       config.ProcsPrefix,
+		initialBufferSize: config.InitialBufferSize,
+	}
+}
+
+// proc returns a handle to a proc from the DLL.
+func (api *winAPI) proc(name string) *windows.LazyProc {
+	return api.dll.NewProc(api.procsPrefix + name)
+}
+
+//go:uintptrescapes
+//go:noinline
+
+// call makes a call to Windows' API.
+func (api *winAPI) call(proc *windows.LazyProc, args ...uintptr) (uintptr, error) {
+	if err := proc.Find(); err != nil {
+		return 0, errors.Wrapf(err, "Unable to locate %q function in DLL %q", proc.Name, api.dll.Name)
+	}
+
+	exitCode, _, _ := proc.Call(args...)
+
+	if exitCode == 0 {
+		return exitCode, nil
+	}
+	return exitCode, iscsidsc.NewWinAPICallError(proc.Name, exitCode)
+}
+
+// handleBufferedCall is a helper for Windows API calls listing objects, that always follow the same pattern:
+// the caller has to allocate a buffer, and the proc fills that buffer, returning an object count and a byte count.
+// typeSize is the size, in bytes, of the type the API calls expect the buffer to be (eg 1 for CHAR, 2 for WCHAR, etc...)
+func (api *winAPI) handleBufferedCall(f func(s, c, b uintptr) (uintptr, error), procName string, typeSize uintptr) (buffer []byte, bufferPointer uintptr, count int32, err error) {
+	bufferSize := api.initialBufferSize/typeSize + 1
+	var exitCode uintptr
+
+	for {
+		buffer = make([]byte, bufferSize*typeSize)
+
+		exitCode, bufferPointer, err = makeBufferedWinAPICall(
+			f,
+			uintptr(unsafe.Pointer(&bufferSize)),
+			uintptr(unsafe.Pointer(&count)),
+			uintptr(unsafe.Pointer(&buffer[0])),
+		)
+
+		if exitCode != uintptr(syscall.ERROR_INSUFFICIENT_BUFFER) {
+			if exitCode == 0 {
+				// sanity check: the reported size should be smaller than the expected size
+				if bufferSize*typeSize <= uintptr(len(buffer)) {
+					buffer = buffer[:bufferSize*typeSize]
+				} else {
+					err = errors.Errorf("Call to %q successful, but reported buffer size %d bigger than actual size %d", procName, bufferSize*typeSize, len(buffer))
+				}
+			}
+
+			return
+		}
+
+		// sanity check: is the new buffer size indeed bigger than the previous one?
+		if bufferSize*typeSize <= uintptr(len(buffer)) {
+			// this should never happen
+			err = errors.Errorf("Error when calling %q: buffer of size %d deemed too small but bigger than the new advised size of %d", procName, len(buffer), bufferSize*typeSize)
+			return
+		}
+		// try again with a bigger buffer
+	}
+}
+
+//go:uintptrescapes
+//go:noinline
+
+// ensures the none of the arguments will be moved by the GC before we return; in particular,
+// allows saving the position of the buffer in memory when passed to the Win API proc.
+func makeBufferedWinAPICall(f func(s, c, b uintptr) (uintptr, error), size, count, buffer uintptr) (uintptr, uintptr, error) {
+	exitCode, err := f(size, count, buffer)
+	return exitCode, buffer, err
+}
@@ -0,0 +1,119 @@
+package iscsidsc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ISIDFormat is the format of an ISID, given by its T field.
+// see https://tools.ietf.org/html/rfc3720#section-10.12.5
+type ISIDFormat uint8
+
+// The various ISID formats.
+const (
+	// ISIDFormatOUI means that the A and B fields hold an IEEE OUI, and the C and D fields a qualifier.
+	ISIDFormatOUI ISIDFormat = iota
+	// ISIDFormatEN means that the B and C fields hold an IANA enterprise number, and the D field a qualifier.
+	ISIDFormatEN
+	// ISIDFormatRandom means that the B and C fields are random, and the D field a qualifier.
+	ISIDFormatRandom
+	// ISIDFormatReserved is reserved by RFC 3720.
+	ISIDFormatReserved
+)
+
+var isidFormatNames = map[ISIDFormat]string{
+	ISIDFormatOUI:      "OUI",
+	ISIDFormatEN:       "EN",
+	ISIDFormatRandom:   "random",
+	ISIDFormatReserved: "reserved",
+}
+
+func (format ISIDFormat) String() string {
+	if name, present := isidFormatNames[format]; present {
+		return name
+	}
+	return fmt.Sprintf("ISIDFormat(%d)", format)
+}
+
+// ISID is a decoded initiator session ID, made of its T, A, B, C and D fields.
+// see https://tools.ietf.org/html/rfc3720#section-10.12.5
+type ISID struct {
+	// T is 2 bits long
+	T ISIDFormat
+	// A is 6 bits long
+	A uint8
+	B uint16
+	C uint8
+	D uint16
+}
+
+// DecodeISID decodes a raw ISID, as found in `SessionInfo`s, in network byte order.
+func DecodeISID(raw [6]byte) ISID {
+	return ISID{
+		T: ISIDFormat(raw[0] >> 6),
+		A: raw[0] & 0x3f,
+		B: binary.BigEndian.Uint16(raw[1:3]),
+		C: raw[3],
+		D: binary.BigEndian.Uint16(raw[4:6]),
+	}
+}
+
+// Bytes encodes the ISID back to its raw form.
+func (isid ISID) Bytes() (raw [6]byte) {
+	raw[0] = byte(isid.T)<<6 | isid.A&0x3f
+	binary.BigEndian.PutUint16(raw[1:3], isid.B)
+	raw[3] = isid.C
+	binary.BigEndian.PutUint16(raw[4:6], isid.D)
+	return
+}
+
+// OUI returns the 22-bit IEEE OUI held in the A and B fields, if the ISID is in the OUI format.
+func (isid ISID) OUI() (uint32, bool) {
+	if isid.T != ISIDFormatOUI {
+		return 0, false
+	}
+	return uint32(isid.A)<<16 | uint32(isid.B), true
+}
+
+// EnterpriseNumber returns the 24-bit IANA enterprise number held in the B and C fields, if the ISID
+// is in the EN format; e.g. Microsoft's is 311.
+func (isid ISID) EnterpriseNumber() (uint32, bool) {
+	if isid.T != ISIDFormatEN {
+		return 0, false
+	}
+	return uint32(isid.B)<<8 | uint32(isid.C), true
+}
+
+// Qualifier returns the qualifier part of the ISID: the 24-bit C and D fields for the OUI format,
+// the D field for other formats.
+func (isid ISID) Qualifier() uint32 {
+	if isid.T == ISIDFormatOUI {
+		return uint32(isid.C)<<16 | uint32(isid.D)
+	}
+	return uint32(isid.D)
+}
+
+// String renders the ISID the way targets usually log it, i.e. as a 48-bit hex number, e.g. "0x400001370000".
+func (isid ISID) String() string {
+	raw := isid.Bytes()
+	return fmt.Sprintf("0x%012x", raw[:])
+}
+
+// TSIH is a target session identifying handle.
+// see https://tools.ietf.org/html/rfc3720#section-10.12.6
+type TSIH uint16
+
+// String renders the TSIH the way targets usually log it, i.e. as a 16-bit hex number, e.g. "0x0002".
+func (tsih TSIH) String() string {
+	return fmt.Sprintf("0x%04x", uint16(tsih))
+}
+
+// DecodedISID returns the session's decoded ISID.
+func (info *SessionInfo) DecodedISID() ISID {
+	return DecodeISID(info.ISID)
+}
+
+// TSIH returns the session's TSIH, decoded from its TSID field, in network byte order.
+func (info *SessionInfo) TSIH() TSIH {
+	return TSIH(binary.BigEndian.Uint16(info.TSID[:]))
+}
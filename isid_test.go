@@ -0,0 +1,89 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeISID(t *testing.T) {
+	for _, testCase := range []struct {
+		name                     string
+		raw                      [6]byte
+		expected                 ISID
+		expectedString           string
+		expectedOUI              uint32
+		expectedEnterpriseNumber uint32
+		expectedQualifier        uint32
+	}{
+		{
+			// what Microsoft's initiator uses
+			name:                     "EN format",
+			raw:                      [6]byte{0x40, 0x00, 0x01, 0x37, 0x00, 0x00},
+			expected:                 ISID{T: ISIDFormatEN, B: 0x0001, C: 0x37},
+			expectedString:           "0x400001370000",
+			expectedEnterpriseNumber: 311,
+		},
+		{
+			name:                     "EN format with a qualifier",
+			raw:                      [6]byte{0x40, 0x00, 0x01, 0x37, 0x00, 0x0b},
+			expected:                 ISID{T: ISIDFormatEN, B: 0x0001, C: 0x37, D: 0x000b},
+			expectedString:           "0x40000137000b",
+			expectedEnterpriseNumber: 311,
+			expectedQualifier:        11,
+		},
+		{
+			name:              "OUI format",
+			raw:               [6]byte{0x00, 0x02, 0x3d, 0x01, 0x00, 0x02},
+			expected:          ISID{T: ISIDFormatOUI, B: 0x023d, C: 0x01, D: 0x0002},
+			expectedString:    "0x00023d010002",
+			expectedOUI:       0x00023d,
+			expectedQualifier: 0x010002,
+		},
+		{
+			name:              "random format",
+			raw:               [6]byte{0x80, 0x12, 0x34, 0x56, 0x00, 0x01},
+			expected:          ISID{T: ISIDFormatRandom, B: 0x1234, C: 0x56, D: 0x0001},
+			expectedString:    "0x801234560001",
+			expectedQualifier: 1,
+		},
+		{
+			name:              "reserved format",
+			raw:               [6]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			expected:          ISID{T: ISIDFormatReserved, A: 0x3f, B: 0xffff, C: 0xff, D: 0xffff},
+			expectedString:    "0xffffffffffff",
+			expectedQualifier: 0xffff,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			isid := DecodeISID(testCase.raw)
+
+			assert.Equal(t, testCase.expected, isid)
+			assert.Equal(t, testCase.raw, isid.Bytes())
+			assert.Equal(t, testCase.expectedString, isid.String())
+			assert.Equal(t, testCase.expectedQualifier, isid.Qualifier())
+
+			oui, isOUI := isid.OUI()
+			assert.Equal(t, testCase.expected.T == ISIDFormatOUI, isOUI)
+			assert.Equal(t, testCase.expectedOUI, oui)
+
+			enterpriseNumber, isEN := isid.EnterpriseNumber()
+			assert.Equal(t, testCase.expected.T == ISIDFormatEN, isEN)
+			assert.Equal(t, testCase.expectedEnterpriseNumber, enterpriseNumber)
+		})
+	}
+}
+
+func TestSessionInfoAccessors(t *testing.T) {
+	// matches session ID ffffe0008ff4b010-4000013700000002
+	info := &SessionInfo{
+		ISID: [6]byte{0x40, 0x00, 0x01, 0x37, 0x00, 0x00},
+		TSID: [2]byte{0x00, 0x02},
+	}
+
+	assert.Equal(t, ISIDFormatEN, info.DecodedISID().T)
+	assert.Equal(t, "EN", info.DecodedISID().T.String())
+	assert.Equal(t, TSIH(2), info.TSIH())
+	assert.Equal(t, "0x0002", info.TSIH().String())
+	assert.Equal(t, "0x0100", (&SessionInfo{TSID: [2]byte{0x01, 0x00}}).TSIH().String())
+}
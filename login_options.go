@@ -0,0 +1,111 @@
+package iscsidsc
+
+import (
+	"fmt"
+)
+
+// RFC 3720 ranges for the numeric login options.
+// see https://tools.ietf.org/html/rfc3720#section-12
+const (
+	minMaximumConnections = 1
+	maxMaximumConnections = 65535
+	maxDefaultTime2Wait   = 3600
+	maxDefaultTime2Retain = 3600
+)
+
+// LoginOptionsRangeError is returned by `LoginOptions.Validate` when a numeric field is
+// outside of the range allowed by RFC 3720.
+type LoginOptionsRangeError struct {
+	Field string
+	Value uint32
+	Min   uint32
+	Max   uint32
+}
+
+func (err *LoginOptionsRangeError) Error() string {
+	return fmt.Sprintf("%s is %d, must be between %d and %d", err.Field, err.Value, err.Min, err.Max)
+}
+
+// LoginOptionsFieldError is returned by `LoginOptions.Validate` when a field is missing, is set when
+// it shouldn't be, or has an unknown value.
+type LoginOptionsFieldError struct {
+	Field  string
+	Reason string
+}
+
+func (err *LoginOptionsFieldError) Error() string {
+	return fmt.Sprintf("%s %s", err.Field, err.Reason)
+}
+
+// Validate checks that the options are consistent, and within the ranges allowed by RFC 3720.
+// It returns either a `*LoginOptionsRangeError` or a `*LoginOptionsFieldError` for the first invalid field.
+// A nil `*LoginOptions` is valid.
+func (opts *LoginOptions) Validate() error {
+	if opts == nil {
+		return nil
+	}
+
+	if err := opts.LoginFlags.Validate(); err != nil {
+		return &LoginOptionsFieldError{Field: "LoginFlags", Reason: "are invalid: " + err.Error()}
+	}
+	if opts.AuthType != nil && opts.AuthType.Validate() != nil {
+		return &LoginOptionsFieldError{Field: "AuthType", Reason: fmt.Sprintf("has unknown value %d", *opts.AuthType)}
+	}
+	if opts.HeaderDigest != nil && opts.HeaderDigest.Validate() != nil {
+		return &LoginOptionsFieldError{Field: "HeaderDigest", Reason: fmt.Sprintf("has unknown value %d", *opts.HeaderDigest)}
+	}
+	if opts.DataDigest != nil && opts.DataDigest.Validate() != nil {
+		return &LoginOptionsFieldError{Field: "DataDigest", Reason: fmt.Sprintf("has unknown value %d", *opts.DataDigest)}
+	}
+
+	if err := checkLoginOptionRange("MaximumConnections", opts.MaximumConnections, minMaximumConnections, maxMaximumConnections); err != nil {
+		return err
+	}
+	if err := checkLoginOptionRange("DefaultTime2Wait", opts.DefaultTime2Wait, 0, maxDefaultTime2Wait); err != nil {
+		return err
+	}
+	if err := checkLoginOptionRange("DefaultTime2Retain", opts.DefaultTime2Retain, 0, maxDefaultTime2Retain); err != nil {
+		return err
+	}
+
+	return opts.validateCredentials()
+}
+
+func checkLoginOptionRange(field string, value *uint32, min, max uint32) error {
+	if value != nil && (*value < min || *value > max) {
+		return &LoginOptionsRangeError{Field: field, Value: *value, Min: min, Max: max}
+	}
+	return nil
+}
+
+// validateCredentials checks that the username and password are consistent with the auth type:
+// both are required for (mutual) CHAP, and neither makes sense without authentication.
+// When the auth type is left nil, Windows decides, and we don't check anything.
+func (opts *LoginOptions) validateCredentials() error {
+	if opts.AuthType == nil {
+		return nil
+	}
+
+	switch *opts.AuthType {
+	case NoAuthAuthType:
+		if opts.Username != nil {
+			return &LoginOptionsFieldError{Field: "Username", Reason: "cannot be set without authentication"}
+		}
+		if opts.Password != nil {
+			return &LoginOptionsFieldError{Field: "Password", Reason: "cannot be set without authentication"}
+		}
+	case CHAPAuthType, MutualCHAPAuthType:
+		authName := "CHAP"
+		if *opts.AuthType == MutualCHAPAuthType {
+			authName = "mutual CHAP"
+		}
+		if opts.Username.Len() == 0 {
+			return &LoginOptionsFieldError{Field: "Username", Reason: "is required for " + authName + " authentication"}
+		}
+		if opts.Password.Len() == 0 {
+			return &LoginOptionsFieldError{Field: "Password", Reason: "is required for " + authName + " authentication"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginOptionsValidate(t *testing.T) {
+	uint32Ptr := func(i uint32) *uint32 { return &i }
+	authTypePtr := func(a AuthType) *AuthType { return &a }
+	digestTypePtr := func(d DigestType) *DigestType { return &d }
+
+	for _, testCase := range []struct {
+		name          string
+		opts          *LoginOptions
+		expectedError error
+	}{
+		{
+			name: "nil options",
+		},
+		{
+			name: "empty options",
+			opts: &LoginOptions{},
+		},
+		{
+			name: "options at the edges of RFC 3720 ranges",
+			opts: &LoginOptions{
+				MaximumConnections: uint32Ptr(65535),
+				DefaultTime2Wait:   uint32Ptr(0),
+				DefaultTime2Retain: uint32Ptr(3600),
+			},
+		},
+		{
+			name:          "no connections",
+			opts:          &LoginOptions{MaximumConnections: uint32Ptr(0)},
+			expectedError: &LoginOptionsRangeError{Field: "MaximumConnections", Value: 0, Min: 1, Max: 65535},
+		},
+		{
+			name:          "too many connections",
+			opts:          &LoginOptions{MaximumConnections: uint32Ptr(65536)},
+			expectedError: &LoginOptionsRangeError{Field: "MaximumConnections", Value: 65536, Min: 1, Max: 65535},
+		},
+		{
+			name:          "time to wait too long",
+			opts:          &LoginOptions{DefaultTime2Wait: uint32Ptr(3601)},
+			expectedError: &LoginOptionsRangeError{Field: "DefaultTime2Wait", Value: 3601, Min: 0, Max: 3600},
+		},
+		{
+			name:          "time to retain too long",
+			opts:          &LoginOptions{DefaultTime2Retain: uint32Ptr(7200)},
+			expectedError: &LoginOptionsRangeError{Field: "DefaultTime2Retain", Value: 7200, Min: 0, Max: 3600},
+		},
+		{
+			name:          "reserved login flag",
+			opts:          &LoginOptions{LoginFlags: LoginFlagMultipathEnabled | 0x4},
+			expectedError: &LoginOptionsFieldError{Field: "LoginFlags", Reason: "are invalid: login flag 0x4 is reserved for Windows' API internal usage"},
+		},
+		{
+			name:          "unknown auth type",
+			opts:          &LoginOptions{AuthType: authTypePtr(3)},
+			expectedError: &LoginOptionsFieldError{Field: "AuthType", Reason: "has unknown value 3"},
+		},
+		{
+			name:          "unknown data digest",
+			opts:          &LoginOptions{DataDigest: digestTypePtr(2)},
+			expectedError: &LoginOptionsFieldError{Field: "DataDigest", Reason: "has unknown value 2"},
+		},
+		{
+			name: "CHAP with credentials",
+			opts: &LoginOptions{AuthType: authTypePtr(CHAPAuthType), Username: NewSecretFromString("user"), Password: NewSecretFromString("passwordpassword")},
+		},
+		{
+			name:          "CHAP without a username",
+			opts:          &LoginOptions{AuthType: authTypePtr(CHAPAuthType), Password: NewSecretFromString("passwordpassword")},
+			expectedError: &LoginOptionsFieldError{Field: "Username", Reason: "is required for CHAP authentication"},
+		},
+		{
+			name:          "mutual CHAP with an empty password",
+			opts:          &LoginOptions{AuthType: authTypePtr(MutualCHAPAuthType), Username: NewSecretFromString("user"), Password: NewSecretFromString("")},
+			expectedError: &LoginOptionsFieldError{Field: "Password", Reason: "is required for mutual CHAP authentication"},
+		},
+		{
+			name:          "credentials without authentication",
+			opts:          &LoginOptions{AuthType: authTypePtr(NoAuthAuthType), Password: NewSecretFromString("passwordpassword")},
+			expectedError: &LoginOptionsFieldError{Field: "Password", Reason: "cannot be set without authentication"},
+		},
+		{
+			name: "credentials without an auth type",
+			opts: &LoginOptions{Username: NewSecretFromString("user")},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.opts.Validate()
+
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestLoginOptionsErrors(t *testing.T) {
+	assert.Equal(t, "DefaultTime2Wait is 3601, must be between 0 and 3600",
+		(&LoginOptionsRangeError{Field: "DefaultTime2Wait", Value: 3601, Min: 0, Max: 3600}).Error())
+	assert.Equal(t, "Username is required for CHAP authentication",
+		(&LoginOptionsFieldError{Field: "Username", Reason: "is required for CHAP authentication"}).Error())
+}
@@ -0,0 +1,133 @@
+package iscsidsc
+
+import (
+	"fmt"
+)
+
+// LoginStatus is the status returned by an iSCSI target in response to a login
+// request, made of a class (the most significant byte) and a detail (the least significant byte).
+// see https://tools.ietf.org/html/rfc3720#section-10.13.5
+type LoginStatus uint16
+
+// LoginStatusClass is the class of a `LoginStatus`.
+type LoginStatusClass uint8
+
+// The various login status classes defined by RFC 3720.
+const (
+	LoginStatusClassSuccess        LoginStatusClass = 0x00
+	LoginStatusClassRedirection    LoginStatusClass = 0x01
+	LoginStatusClassInitiatorError LoginStatusClass = 0x02
+	LoginStatusClassTargetError    LoginStatusClass = 0x03
+)
+
+// The various non-success login statuses defined by RFC 3720.
+const (
+	LoginStatusTargetMovedTemporarily LoginStatus = 0x0101
+	LoginStatusTargetMovedPermanently LoginStatus = 0x0102
+
+	LoginStatusInitiatorError         LoginStatus = 0x0200
+	LoginStatusAuthenticationFailure  LoginStatus = 0x0201
+	LoginStatusAuthorizationFailure   LoginStatus = 0x0202
+	LoginStatusTargetNotFound         LoginStatus = 0x0203
+	LoginStatusTargetRemoved          LoginStatus = 0x0204
+	LoginStatusUnsupportedVersion     LoginStatus = 0x0205
+	LoginStatusTooManyConnections     LoginStatus = 0x0206
+	LoginStatusMissingParameter       LoginStatus = 0x0207
+	LoginStatusCantIncludeInSession   LoginStatus = 0x0208
+	LoginStatusSessionTypeUnsupported LoginStatus = 0x0209
+
+	LoginStatusTargetError        LoginStatus = 0x0300
+	LoginStatusServiceUnavailable LoginStatus = 0x0301
+	LoginStatusOutOfResources     LoginStatus = 0x0302
+)
+
+// loginStatuses maps the exit codes Windows' API returns when a target rejects a login
+// to the status the target replied with.
+var loginStatuses = map[ErrorCode]LoginStatus{
+	ErrTargetMovedTemporarily:   LoginStatusTargetMovedTemporarily,
+	ErrTargetMovedPermanently:   LoginStatusTargetMovedPermanently,
+	ErrInitiatorError:           LoginStatusInitiatorError,
+	ErrLoginAuthFailed:          LoginStatusAuthenticationFailure,
+	ErrLoginAuthorizationFailed: LoginStatusAuthorizationFailure,
+	ErrNotFound:                 LoginStatusTargetNotFound,
+	ErrTargetRemoved:            LoginStatusTargetRemoved,
+	ErrUnsupportedVersion:       LoginStatusUnsupportedVersion,
+	ErrTooManyConnections:       LoginStatusTooManyConnections,
+	ErrMissingParameter:         LoginStatusMissingParameter,
+	ErrCantIncludeInSession:     LoginStatusCantIncludeInSession,
+	ErrSessionTypeNotSupported:  LoginStatusSessionTypeUnsupported,
+	ErrTargetError:              LoginStatusTargetError,
+	ErrServiceUnavailable:       LoginStatusServiceUnavailable,
+	ErrOutOfResources:           LoginStatusOutOfResources,
+}
+
+var loginStatusDetails = map[LoginStatus]string{
+	LoginStatusTargetMovedTemporarily: "target moved temporarily",
+	LoginStatusTargetMovedPermanently: "target moved permanently",
+	LoginStatusInitiatorError:         "miscellaneous initiator error",
+	LoginStatusAuthenticationFailure:  "authentication failure",
+	LoginStatusAuthorizationFailure:   "authorization failure",
+	LoginStatusTargetNotFound:         "target not found",
+	LoginStatusTargetRemoved:          "target removed",
+	LoginStatusUnsupportedVersion:     "unsupported version",
+	LoginStatusTooManyConnections:     "too many connections",
+	LoginStatusMissingParameter:       "missing parameter",
+	LoginStatusCantIncludeInSession:   "can't include in session",
+	LoginStatusSessionTypeUnsupported: "session type not supported",
+	LoginStatusTargetError:            "target hardware or software error",
+	LoginStatusServiceUnavailable:     "service unavailable",
+	LoginStatusOutOfResources:         "out of resources",
+}
+
+// LoginStatus returns the login status the target replied with, if the code is one of
+// those Windows' API returns when a target rejects a login; and false otherwise.
+func (code ErrorCode) LoginStatus() (LoginStatus, bool) {
+	status, present := loginStatuses[code]
+	return status, present
+}
+
+// Class returns the status' class.
+func (status LoginStatus) Class() LoginStatusClass {
+	return LoginStatusClass(status >> 8)
+}
+
+// Detail returns the status' detail, which is only meaningful within its class.
+func (status LoginStatus) Detail() uint8 {
+	return uint8(status)
+}
+
+// Retryable returns true iff the same login request might succeed if retried later, or
+// at another address for redirections.
+// As per RFC 3720, initiator errors should not be retried as-is, whereas target errors
+// are transient.
+func (status LoginStatus) Retryable() bool {
+	switch status.Class() {
+	case LoginStatusClassRedirection, LoginStatusClassTargetError:
+		return true
+	default:
+		return false
+	}
+}
+
+func (status LoginStatus) String() string {
+	detail, present := loginStatusDetails[status]
+	if !present {
+		detail = "unknown detail"
+	}
+	return fmt.Sprintf("%s: %s (0x%04X)", status.Class(), detail, uint16(status))
+}
+
+func (class LoginStatusClass) String() string {
+	switch class {
+	case LoginStatusClassSuccess:
+		return "success"
+	case LoginStatusClassRedirection:
+		return "redirection"
+	case LoginStatusClassInitiatorError:
+		return "initiator error"
+	case LoginStatusClassTargetError:
+		return "target error"
+	default:
+		return fmt.Sprintf("unknown class 0x%02X", uint8(class))
+	}
+}
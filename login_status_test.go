@@ -0,0 +1,79 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginStatus(t *testing.T) {
+	testCases := []struct {
+		exitCode          uintptr
+		expectedStatus    LoginStatus
+		expectedClass     LoginStatusClass
+		expectedDetail    uint8
+		expectedRetryable bool
+		expectedString    string
+	}{
+		{
+			exitCode:          0xEFFF0006,
+			expectedStatus:    LoginStatusTargetMovedTemporarily,
+			expectedClass:     LoginStatusClassRedirection,
+			expectedDetail:    0x01,
+			expectedRetryable: true,
+			expectedString:    "redirection: target moved temporarily (0x0101)",
+		},
+		{
+			exitCode:          0xEFFF0009,
+			expectedStatus:    LoginStatusAuthenticationFailure,
+			expectedClass:     LoginStatusClassInitiatorError,
+			expectedDetail:    0x01,
+			expectedRetryable: false,
+			expectedString:    "initiator error: authentication failure (0x0201)",
+		},
+		{
+			exitCode:          0xEFFF000C,
+			expectedStatus:    LoginStatusTargetRemoved,
+			expectedClass:     LoginStatusClassInitiatorError,
+			expectedDetail:    0x04,
+			expectedRetryable: false,
+			expectedString:    "initiator error: target removed (0x0204)",
+		},
+		{
+			exitCode:          0xEFFF0014,
+			expectedStatus:    LoginStatusOutOfResources,
+			expectedClass:     LoginStatusClassTargetError,
+			expectedDetail:    0x02,
+			expectedRetryable: true,
+			expectedString:    "target error: out of resources (0x0302)",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expectedString, func(t *testing.T) {
+			err := NewWinAPICallError("LoginIScsiTargetW", testCase.exitCode)
+
+			status, ok := err.LoginStatus()
+
+			if assert.True(t, ok) {
+				assert.Equal(t, testCase.expectedStatus, status)
+				assert.Equal(t, testCase.expectedClass, status.Class())
+				assert.Equal(t, testCase.expectedDetail, status.Detail())
+				assert.Equal(t, testCase.expectedRetryable, status.Retryable())
+				assert.Equal(t, testCase.expectedString, status.String())
+			}
+		})
+	}
+
+	t.Run("with an error unrelated to the login status", func(t *testing.T) {
+		err := NewWinAPICallError("LoginIScsiTargetW", 0xEFFF003F)
+
+		_, ok := err.LoginStatus()
+
+		assert.False(t, ok)
+	})
+
+	t.Run("with an unknown status", func(t *testing.T) {
+		assert.Equal(t, "unknown class 0x04: unknown detail (0x0401)", LoginStatus(0x0401).String())
+	})
+}
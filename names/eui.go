@@ -0,0 +1,89 @@
+package names
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// EUI is an IEEE EUI-64 based iSCSI name, e.g. "eui.02004567a425678d".
+// see https://tools.ietf.org/html/rfc3720#section-3.2.6.3.2
+type EUI struct {
+	ID [8]byte
+}
+
+// Type implements `Name`.
+func (eui *EUI) Type() Type {
+	return TypeEUI
+}
+
+func (eui *EUI) String() string {
+	return typePrefixes[TypeEUI] + hex.EncodeToString(eui.ID[:])
+}
+
+// ParseEUI normalizes and parses an EUI name.
+func ParseEUI(name string) (*EUI, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(normalized, typePrefixes[TypeEUI]) {
+		return nil, parseError(name, `EUI names must start with "eui."`)
+	}
+	return parseEUI(name, normalized)
+}
+
+// parseEUI expects normalized to be the normalized form of name, and to start with "eui.".
+func parseEUI(name, normalized string) (*EUI, error) {
+	digits := strings.TrimPrefix(normalized, typePrefixes[TypeEUI])
+	if len(digits) != 16 {
+		return nil, parseError(name, "EUI names must contain exactly 16 hex digits")
+	}
+
+	eui := &EUI{}
+	if _, err := hex.Decode(eui.ID[:], []byte(digits)); err != nil {
+		return nil, parseError(name, "EUI names must contain exactly 16 hex digits")
+	}
+	return eui, nil
+}
+
+// NAA is a T11 Network Address Authority based iSCSI name, e.g. "naa.52004567ba64678d".
+// Its ID is either 64 or 128 bits long.
+// see https://tools.ietf.org/html/rfc3980
+type NAA struct {
+	ID []byte
+}
+
+// Type implements `Name`.
+func (naa *NAA) Type() Type {
+	return TypeNAA
+}
+
+func (naa *NAA) String() string {
+	return typePrefixes[TypeNAA] + hex.EncodeToString(naa.ID)
+}
+
+// ParseNAA normalizes and parses an NAA name.
+func ParseNAA(name string) (*NAA, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(normalized, typePrefixes[TypeNAA]) {
+		return nil, parseError(name, `NAA names must start with "naa."`)
+	}
+	return parseNAA(name, normalized)
+}
+
+// parseNAA expects normalized to be the normalized form of name, and to start with "naa.".
+func parseNAA(name, normalized string) (*NAA, error) {
+	digits := strings.TrimPrefix(normalized, typePrefixes[TypeNAA])
+	if len(digits) != 16 && len(digits) != 32 {
+		return nil, parseError(name, "NAA names must contain exactly 16 or 32 hex digits")
+	}
+
+	id, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, parseError(name, "NAA names must contain exactly 16 or 32 hex digits")
+	}
+	return &NAA{ID: id}, nil
+}
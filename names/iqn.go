@@ -0,0 +1,142 @@
+package names
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IQN is an iSCSI qualified name, e.g. "iqn.1991-05.com.microsoft:target".
+// see https://tools.ietf.org/html/rfc3720#section-3.2.6.3.1
+type IQN struct {
+	// Date is when the naming authority owned its domain name; only its year and month are relevant
+	Date time.Time
+	// NamingAuthority is the naming authority's domain name, in reversed order, e.g. "com.microsoft"
+	NamingAuthority string
+	// Unique is the optional string following the colon, unique within the naming authority
+	Unique string
+}
+
+// Type implements `Name`.
+func (iqn *IQN) Type() Type {
+	return TypeIQN
+}
+
+func (iqn *IQN) String() string {
+	s := fmt.Sprintf("%s%04d-%02d.%s", typePrefixes[TypeIQN], iqn.Date.Year(), int(iqn.Date.Month()), iqn.NamingAuthority)
+	if iqn.Unique != "" {
+		s += ":" + iqn.Unique
+	}
+	return s
+}
+
+// ParseIQN normalizes and parses an IQN.
+func ParseIQN(name string) (*IQN, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(normalized, typePrefixes[TypeIQN]) {
+		return nil, parseError(name, `IQNs must start with "iqn."`)
+	}
+	return parseIQN(name, normalized)
+}
+
+// parseIQN expects normalized to be the normalized form of name, and to start with "iqn.".
+func parseIQN(name, normalized string) (*IQN, error) {
+	rest := strings.TrimPrefix(normalized, typePrefixes[TypeIQN])
+
+	dotIndex := strings.IndexByte(rest, '.')
+	if dotIndex == -1 {
+		return nil, parseError(name, "IQNs must contain a date and a naming authority")
+	}
+	date, err := parseIQNDate(rest[:dotIndex])
+	if err != nil {
+		return nil, parseError(name, "%v", err)
+	}
+	rest = rest[dotIndex+1:]
+
+	iqn := &IQN{Date: date, NamingAuthority: rest}
+	if colonIndex := strings.IndexByte(rest, ':'); colonIndex != -1 {
+		iqn.NamingAuthority = rest[:colonIndex]
+		iqn.Unique = rest[colonIndex+1:]
+		if iqn.Unique == "" {
+			return nil, parseError(name, "the unique part after the colon cannot be empty")
+		}
+	}
+	if err := validateNamingAuthority(iqn.NamingAuthority); err != nil {
+		return nil, parseError(name, "%v", err)
+	}
+
+	return iqn, nil
+}
+
+// parseIQNDate parses a "yyyy-mm" date.
+func parseIQNDate(s string) (time.Time, error) {
+	invalidDate := fmt.Errorf("invalid date %q, must be formatted as yyyy-mm", s)
+
+	if len(s) != 7 || s[4] != '-' {
+		return time.Time{}, invalidDate
+	}
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return time.Time{}, invalidDate
+	}
+	month, err := strconv.Atoi(s[5:])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, invalidDate
+	}
+
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// validateNamingAuthority checks that the naming authority is a reversed domain name.
+func validateNamingAuthority(namingAuthority string) error {
+	if namingAuthority == "" {
+		return fmt.Errorf("the naming authority cannot be empty")
+	}
+	for _, label := range strings.Split(namingAuthority, ".") {
+		if label == "" {
+			return fmt.Errorf("invalid naming authority %q, cannot contain empty labels", namingAuthority)
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid naming authority %q, labels cannot start or end with dashes", namingAuthority)
+		}
+		if strings.ContainsRune(label, ':') {
+			return fmt.Errorf("invalid naming authority %q", namingAuthority)
+		}
+	}
+	return nil
+}
+
+// NewIQN builds a new IQN, and checks that it's valid once normalized.
+// namingAuthority must be a reversed domain name, e.g. "com.example"; unique can be left empty.
+func NewIQN(date time.Time, namingAuthority, unique string) (*IQN, error) {
+	iqn := &IQN{
+		Date:            time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC),
+		NamingAuthority: namingAuthority,
+		Unique:          unique,
+	}
+	if strings.ContainsRune(namingAuthority, ':') {
+		return nil, parseError(iqn.String(), "invalid naming authority %q", namingAuthority)
+	}
+	return ParseIQN(iqn.String())
+}
+
+// IQNFromDomain builds a new IQN for a naming authority owning domain (in the usual order, e.g.
+// "example.com") since date.
+func IQNFromDomain(domain string, date time.Time, unique string) (*IQN, error) {
+	labels := strings.Split(domain, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return NewIQN(date, strings.Join(labels, "."), unique)
+}
+
+// RandomIQN is the same as `IQNFromDomain`, with a random UUID as the unique part.
+func RandomIQN(domain string, date time.Time) (*IQN, error) {
+	return IQNFromDomain(domain, date, uuid.New().String())
+}
@@ -0,0 +1,55 @@
+package names
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIQNGeneration(t *testing.T) {
+	date := time.Date(2019, time.November, 14, 12, 30, 0, 0, time.Local)
+
+	t.Run("NewIQN", func(t *testing.T) {
+		iqn, err := NewIQN(date, "com.Example", "Host-1")
+
+		require.Nil(t, err)
+		assert.Equal(t, "iqn.2019-11.com.example:host-1", iqn.String())
+		assert.Equal(t, time.Date(2019, time.November, 1, 0, 0, 0, 0, time.UTC), iqn.Date)
+		assert.Equal(t, TypeIQN, iqn.Type())
+	})
+
+	t.Run("NewIQN without a unique part", func(t *testing.T) {
+		iqn, err := NewIQN(date, "com.example", "")
+
+		require.Nil(t, err)
+		assert.Equal(t, "iqn.2019-11.com.example", iqn.String())
+	})
+
+	t.Run("NewIQN with an invalid naming authority", func(t *testing.T) {
+		for _, namingAuthority := range []string{"", "com.example:storage", "com example", "com..example"} {
+			_, err := NewIQN(date, namingAuthority, "host-1")
+			assert.NotNil(t, err, namingAuthority)
+		}
+	})
+
+	t.Run("IQNFromDomain", func(t *testing.T) {
+		iqn, err := IQNFromDomain("storage.example.com", date, "array:1")
+
+		require.Nil(t, err)
+		assert.Equal(t, "iqn.2019-11.com.example.storage:array:1", iqn.String())
+	})
+
+	t.Run("RandomIQN", func(t *testing.T) {
+		iqn1, err := RandomIQN("example.com", date)
+		require.Nil(t, err)
+		iqn2, err := RandomIQN("example.com", date)
+		require.Nil(t, err)
+
+		assert.Equal(t, "com.example", iqn1.NamingAuthority)
+		assert.Len(t, iqn1.Unique, 36)
+		assert.NotEqual(t, iqn1.String(), iqn2.String())
+		assert.Nil(t, Validate(iqn1.String()))
+	})
+}
@@ -0,0 +1,168 @@
+// Package names parses, normalizes and validates iSCSI names, as defined by RFC 3720 (section 3.2.6)
+// and RFC 3980 for the NAA format; it also provides helpers to generate compliant IQNs.
+// It's pure go, and works on any platform.
+package names
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the maximum length of an iSCSI name, in bytes once UTF-8 encoded.
+// see https://tools.ietf.org/html/rfc3720#section-3.2.6.1
+// It's the same as Windows' `MAX_ISCSI_NAME_LEN`.
+const MaxLength = 223
+
+// Type is the type of an iSCSI name, i.e. its format.
+type Type int
+
+// The various iSCSI name types.
+const (
+	TypeIQN Type = iota
+	TypeEUI
+	TypeNAA
+)
+
+var typePrefixes = map[Type]string{
+	TypeIQN: "iqn.",
+	TypeEUI: "eui.",
+	TypeNAA: "naa.",
+}
+
+func (t Type) String() string {
+	if prefix, present := typePrefixes[t]; present {
+		return strings.ToUpper(strings.TrimSuffix(prefix, "."))
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Name is a parsed iSCSI name, one of `*IQN`, `*EUI` or `*NAA`.
+type Name interface {
+	// Type returns the name's type.
+	Type() Type
+	// String returns the name's normalized form.
+	String() string
+}
+
+// ParseError is returned when parsing an invalid iSCSI name.
+type ParseError struct {
+	Name   string
+	Reason string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("invalid iSCSI name %q: %s", err.Name, err.Reason)
+}
+
+func parseError(name string, format string, args ...interface{}) *ParseError {
+	return &ParseError{Name: name, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Parse normalizes and parses an iSCSI name of any type.
+func Parse(name string) (Name, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(normalized, typePrefixes[TypeIQN]):
+		return parseIQN(name, normalized)
+	case strings.HasPrefix(normalized, typePrefixes[TypeEUI]):
+		return parseEUI(name, normalized)
+	case strings.HasPrefix(normalized, typePrefixes[TypeNAA]):
+		return parseNAA(name, normalized)
+	default:
+		return nil, parseError(name, `must start with "iqn.", "eui." or "naa."`)
+	}
+}
+
+// Validate checks that name is a valid iSCSI name of any type.
+func Validate(name string) error {
+	_, err := Parse(name)
+	return err
+}
+
+// Equal returns true iff both names are valid, and equal once normalized.
+func Equal(name1, name2 string) bool {
+	normalized1, err := Normalize(name1)
+	if err != nil {
+		return false
+	}
+	normalized2, err := Normalize(name2)
+	return err == nil && normalized1 == normalized2
+}
+
+// Canonical returns name normalized if it's valid, and lower-cased otherwise: Windows doesn't enforce
+// RFC 3722, and can report names that aren't valid, e.g. for targets logged into with `iscsicli`.
+// Unlike `Normalize`, it's then suitable to compare or index names as reported by Windows.
+func Canonical(name string) string {
+	if normalized, err := Normalize(name); err == nil {
+		return normalized
+	}
+	return strings.ToLower(name)
+}
+
+// Match returns true iff both names are equal once canonicalized, see `Canonical`. Unlike `Equal`,
+// names that aren't valid can match.
+func Match(name1, name2 string) bool {
+	return Canonical(name1) == Canonical(name2)
+}
+
+// Normalize applies the stringprep profile for iSCSI names defined by RFC 3722, and checks
+// that the result only contains allowed characters, and isn't too long.
+// see https://tools.ietf.org/html/rfc3722
+// Note that this covers characters mapped to nothing (table B.1) and case folding, but not NFKC
+// normalization: names containing characters whose NFKC form differ from themselves might then not
+// compare equal to their normalized form.
+func Normalize(name string) (string, error) {
+	if name == "" {
+		return "", parseError(name, "cannot be empty")
+	}
+	if !utf8.ValidString(name) {
+		return "", parseError(name, "not valid UTF-8")
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(name))
+	for _, r := range name {
+		if mappedToNothing(r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if !allowedRune(r) {
+			return "", parseError(name, "character %q is not allowed", r)
+		}
+		builder.WriteRune(r)
+	}
+
+	normalized := builder.String()
+	if len(normalized) > MaxLength {
+		return "", parseError(name, "too long, cannot be more than %d bytes", MaxLength)
+	}
+	return normalized, nil
+}
+
+// mappedToNothing returns true for the characters of stringprep's table B.1.
+// see https://tools.ietf.org/html/rfc3454#appendix-B.1
+func mappedToNothing(r rune) bool {
+	switch {
+	case r == 0x00AD, r == 0x034F, r == 0x1806, r == 0x2060, r == 0xFEFF:
+		return true
+	case 0x180B <= r && r <= 0x180D, 0x200B <= r && r <= 0x200D, 0xFE00 <= r && r <= 0xFE0F:
+		return true
+	}
+	return false
+}
+
+// allowedRune returns true for the characters allowed in normalized iSCSI names: ASCII dashes, dots,
+// colons, lower case letters and digits, and non-ASCII letters, marks and digits.
+// see https://tools.ietf.org/html/rfc3720#section-3.2.6.2
+func allowedRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return r == '-' || r == '.' || r == ':' || ('a' <= r && r <= 'z') || ('0' <= r && r <= '9')
+	}
+	return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r)
+}
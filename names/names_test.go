@@ -0,0 +1,197 @@
+package names
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, testCase := range []struct {
+		input              string
+		expectedName       Name
+		expectedNormalized string
+		expectedError      string
+	}{
+		{
+			input: "iqn.1991-05.com.microsoft:target",
+			expectedName: &IQN{
+				Date:            time.Date(1991, time.May, 1, 0, 0, 0, 0, time.UTC),
+				NamingAuthority: "com.microsoft",
+				Unique:          "target",
+			},
+		},
+		{
+			input: "IQN.2001-04.com.Example:Storage:Diskarrays-sn-a8675309",
+			expectedName: &IQN{
+				Date:            time.Date(2001, time.April, 1, 0, 0, 0, 0, time.UTC),
+				NamingAuthority: "com.example",
+				Unique:          "storage:diskarrays-sn-a8675309",
+			},
+			expectedNormalized: "iqn.2001-04.com.example:storage:diskarrays-sn-a8675309",
+		},
+		{
+			input: "iqn.2001-04.com.example",
+			expectedName: &IQN{
+				Date:            time.Date(2001, time.April, 1, 0, 0, 0, 0, time.UTC),
+				NamingAuthority: "com.example",
+			},
+		},
+		{
+			input: "iqn.2001-04.com.example:storage.tape1.sys1.xyz",
+			expectedName: &IQN{
+				Date:            time.Date(2001, time.April, 1, 0, 0, 0, 0, time.UTC),
+				NamingAuthority: "com.example",
+				Unique:          "storage.tape1.sys1.xyz",
+			},
+		},
+		{
+			// with a soft hyphen, mapped to nothing
+			input: "iqn.2001-04.com.exam­ple:ÉTÉ",
+			expectedName: &IQN{
+				Date:            time.Date(2001, time.April, 1, 0, 0, 0, 0, time.UTC),
+				NamingAuthority: "com.example",
+				Unique:          "été",
+			},
+			expectedNormalized: "iqn.2001-04.com.example:été",
+		},
+		{
+			input:              "eui.02004567A425678D",
+			expectedName:       &EUI{ID: [8]byte{0x02, 0x00, 0x45, 0x67, 0xa4, 0x25, 0x67, 0x8d}},
+			expectedNormalized: "eui.02004567a425678d",
+		},
+		{
+			input:        "naa.52004567ba64678d",
+			expectedName: &NAA{ID: []byte{0x52, 0x00, 0x45, 0x67, 0xba, 0x64, 0x67, 0x8d}},
+		},
+		{
+			input: "naa.62004567ba64678d0123456789abcdef",
+			expectedName: &NAA{ID: []byte{0x62, 0x00, 0x45, 0x67, 0xba, 0x64, 0x67, 0x8d,
+				0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}},
+		},
+		{
+			input:         "",
+			expectedError: `invalid iSCSI name "": cannot be empty`,
+		},
+		{
+			input:         "target",
+			expectedError: `invalid iSCSI name "target": must start with "iqn.", "eui." or "naa."`,
+		},
+		{
+			input:         "iqn.1991-05.com.microsoft:my target",
+			expectedError: `invalid iSCSI name "iqn.1991-05.com.microsoft:my target": character ' ' is not allowed`,
+		},
+		{
+			input:         "iqn.1991-05.com.microsoft:target_1",
+			expectedError: `invalid iSCSI name "iqn.1991-05.com.microsoft:target_1": character '_' is not allowed`,
+		},
+		{
+			input:         "iqn.1991-05.com.microsoft:" + strings.Repeat("a", 198),
+			expectedError: `too long, cannot be more than 223 bytes`,
+		},
+		{
+			input:         "iqn.1991-13.com.microsoft:target",
+			expectedError: `invalid iSCSI name "iqn.1991-13.com.microsoft:target": invalid date "1991-13", must be formatted as yyyy-mm`,
+		},
+		{
+			input:         "iqn.91-05.com.microsoft:target",
+			expectedError: `invalid date "91-05", must be formatted as yyyy-mm`,
+		},
+		{
+			input:         "iqn.1991-05",
+			expectedError: `IQNs must contain a date and a naming authority`,
+		},
+		{
+			input:         "iqn.1991-05.:target",
+			expectedError: `the naming authority cannot be empty`,
+		},
+		{
+			input:         "iqn.1991-05.com..microsoft:target",
+			expectedError: `invalid naming authority "com..microsoft", cannot contain empty labels`,
+		},
+		{
+			input:         "iqn.1991-05.com.-microsoft:target",
+			expectedError: `invalid naming authority "com.-microsoft", labels cannot start or end with dashes`,
+		},
+		{
+			input:         "iqn.1991-05.com.microsoft:",
+			expectedError: `the unique part after the colon cannot be empty`,
+		},
+		{
+			input:         "eui.02004567a425678",
+			expectedError: `EUI names must contain exactly 16 hex digits`,
+		},
+		{
+			input:         "eui.02004567a425678g",
+			expectedError: `EUI names must contain exactly 16 hex digits`,
+		},
+		{
+			input:         "naa.52004567ba64678d01",
+			expectedError: `NAA names must contain exactly 16 or 32 hex digits`,
+		},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			name, err := Parse(testCase.input)
+
+			if testCase.expectedError != "" {
+				assert.Nil(t, name)
+				if assert.NotNil(t, err) {
+					assert.IsType(t, &ParseError{}, err)
+					assert.Contains(t, err.Error(), testCase.expectedError)
+				}
+				assert.NotNil(t, Validate(testCase.input))
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Nil(t, Validate(testCase.input))
+			assert.Equal(t, testCase.expectedName, name)
+
+			expectedNormalized := testCase.expectedNormalized
+			if expectedNormalized == "" {
+				expectedNormalized = testCase.input
+			}
+			assert.Equal(t, expectedNormalized, name.String())
+			assert.True(t, Equal(testCase.input, name.String()))
+		})
+	}
+}
+
+func TestTypedParsers(t *testing.T) {
+	_, err := ParseIQN("eui.02004567a425678d")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, `invalid iSCSI name "eui.02004567a425678d": IQNs must start with "iqn."`, err.Error())
+	}
+
+	_, err = ParseEUI("iqn.1991-05.com.microsoft")
+	assert.NotNil(t, err)
+	_, err = ParseNAA("iqn.1991-05.com.microsoft")
+	assert.NotNil(t, err)
+
+	eui, err := ParseEUI("eui.02004567A425678D")
+	require.Nil(t, err)
+	assert.Equal(t, TypeEUI, eui.Type())
+	assert.Equal(t, "EUI", eui.Type().String())
+
+	naa, err := ParseNAA("naa.52004567ba64678d")
+	require.Nil(t, err)
+	assert.Equal(t, TypeNAA, naa.Type())
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal("iqn.1991-05.com.microsoft:Target", "IQN.1991-05.COM.MICROSOFT:target"))
+	assert.False(t, Equal("iqn.1991-05.com.microsoft:target-1", "iqn.1991-05.com.microsoft:target-2"))
+	assert.False(t, Equal("not valid", "not valid"))
+}
+
+func TestMatch(t *testing.T) {
+	assert.Equal(t, "iqn.1991-05.com.microsoft:target", Canonical("IQN.1991-05.com.microsoft:Target"))
+	assert.Equal(t, "iqn.1991-05.com.microsoft:my target", Canonical("iqn.1991-05.com.microsoft:My Target"))
+
+	assert.True(t, Match("iqn.1991-05.com.microsoft:Target", "IQN.1991-05.COM.MICROSOFT:target"))
+	assert.True(t, Match("iqn.1991-05.com.microsoft:My Target", "iqn.1991-05.com.microsoft:my target"))
+	assert.False(t, Match("iqn.1991-05.com.microsoft:target-1", "iqn.1991-05.com.microsoft:target-2"))
+}
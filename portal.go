@@ -0,0 +1,190 @@
+package iscsidsc
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPortalSocket is the port target portals listen on when none is specified.
+const DefaultPortalSocket uint16 = 3260
+
+// ParsePortal parses a portal address, optionally followed by a port, e.g. "10.0.0.5:3261",
+// "[fe80::1%eth0]:3260", "array.example.com" or "fe80::1". IPv6 addresses must be enclosed in square
+// brackets when followed by a port.
+// The socket is left nil if no port is specified.
+func ParsePortal(s string) (*Portal, error) {
+	invalidPortal := func(format string, args ...interface{}) error {
+		return errors.Errorf("invalid portal %q: "+format, append([]interface{}{s}, args...)...)
+	}
+
+	var host, port string
+	switch {
+	case strings.HasPrefix(s, "["):
+		closingIndex := strings.IndexByte(s, ']')
+		if closingIndex == -1 {
+			return nil, invalidPortal("missing closing bracket")
+		}
+		host = s[1:closingIndex]
+		if rest := s[closingIndex+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return nil, invalidPortal("unexpected %q after closing bracket", rest)
+			}
+			port = rest[1:]
+			if port == "" {
+				return nil, invalidPortal("missing port after colon")
+			}
+		}
+		if !isIPv6(host) {
+			return nil, invalidPortal("only IPv6 addresses can be enclosed in brackets")
+		}
+	case strings.Count(s, ":") > 1:
+		// bare IPv6 address, without a port
+		host = s
+		if !isIPv6(host) {
+			return nil, invalidPortal("invalid IPv6 address, or missing brackets")
+		}
+	default:
+		host = s
+		if colonIndex := strings.IndexByte(s, ':'); colonIndex != -1 {
+			host = s[:colonIndex]
+			port = s[colonIndex+1:]
+			if port == "" {
+				return nil, invalidPortal("missing port after colon")
+			}
+		}
+	}
+
+	if host == "" {
+		return nil, invalidPortal("missing address")
+	}
+	if strings.ContainsAny(host, " /[]") {
+		return nil, invalidPortal("invalid address %q", host)
+	}
+
+	portal := &Portal{Address: host}
+	if port != "" {
+		socket, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || socket == 0 {
+			return nil, invalidPortal("invalid port %q", port)
+		}
+		socket16 := uint16(socket)
+		portal.Socket = &socket16
+	}
+
+	return portal, nil
+}
+
+// isIPv6 returns true iff address is an IPv6 address, optionally with a zone.
+func isIPv6(address string) bool {
+	if zoneIndex := strings.IndexByte(address, '%'); zoneIndex != -1 {
+		if zoneIndex == len(address)-1 {
+			return false
+		}
+		address = address[:zoneIndex]
+	}
+	return strings.ContainsRune(address, ':') && net.ParseIP(address) != nil
+}
+
+// socketOrDefault returns the portal's socket, or `DefaultPortalSocket` if not set.
+func (p *Portal) socketOrDefault() uint16 {
+	if p.Socket == nil {
+		return DefaultPortalSocket
+	}
+	return *p.Socket
+}
+
+// String returns the portal's address and port, e.g. "10.0.0.5:3260" or "[fe80::1%eth0]:3260";
+// the port is the default one if not set. `ParsePortal` parses that format.
+func (p *Portal) String() string {
+	return net.JoinHostPort(unbracketAddress(p.Address), strconv.Itoa(int(p.socketOrDefault())))
+}
+
+// Raw returns a copy of the portal as it's passed to Windows' API: its socket is set to
+// `DefaultPortalSocket` if it was nil, and brackets around IPv6 addresses are removed; unlike with
+// `Normalize`, the address is otherwise kept as given, as Windows does.
+func (p *Portal) Raw() *Portal {
+	socket := p.socketOrDefault()
+	return &Portal{
+		SymbolicName: p.SymbolicName,
+		Address:      unbracketAddress(p.Address),
+		Socket:       &socket,
+	}
+}
+
+// Normalize returns a normalized copy of the portal: its socket is set to `DefaultPortalSocket` if it
+// was nil, brackets around IPv6 addresses are removed, IP addresses are put in canonical form (e.g.
+// "FE80:0::1" becomes "fe80::1", zones are kept as is), and DNS names are lower-cased and stripped of
+// their trailing dot if any. The symbolic name is left untouched.
+func (p *Portal) Normalize() *Portal {
+	normalized := p.Raw()
+	normalized.Address = normalizeAddress(normalized.Address)
+	return normalized
+}
+
+// Key returns a string that's the same for any two portals that are equal as per `Equal`, and different
+// otherwise; it's suitable to index portals in maps.
+func (p *Portal) Key() string {
+	return p.Normalize().String()
+}
+
+// unbracketAddress removes brackets around IPv6 addresses, if any.
+func unbracketAddress(address string) string {
+	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+		return address[1 : len(address)-1]
+	}
+	return address
+}
+
+func normalizeAddress(address string) string {
+	address = unbracketAddress(address)
+
+	host, zone := address, ""
+	if zoneIndex := strings.IndexByte(address, '%'); zoneIndex != -1 {
+		host, zone = address[:zoneIndex], address[zoneIndex:]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String() + zone
+	}
+
+	return strings.TrimSuffix(strings.ToLower(address), ".")
+}
+
+// Equal returns true iff both portals have the same address and port once normalized;
+// symbolic names are ignored. Nil portals are only equal to each other.
+// Note that Windows keeps portal addresses as they were given, and so can register several portals
+// that are equal as per this method, see `DedupePortalInfos`.
+func (p *Portal) Equal(other *Portal) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	return normalizeAddress(p.Address) == normalizeAddress(other.Address) && p.socketOrDefault() == other.socketOrDefault()
+}
+
+// DedupePortalInfos returns the portal infos that aren't for the same portal (as per `Portal.Equal`) and
+// the same initiator as a previous one in the list, reusing the list's storage.
+// Windows keeps portal addresses as they were given, so it can list the same portal several times, e.g.
+// with different spellings of the same IPv6 address, or with a DNS name in different cases; and it
+// treats each of those registrations separately, e.g. each needs to be removed on its own.
+func DedupePortalInfos(portalInfos []PortalInfo) []PortalInfo {
+	deduped := portalInfos[:0]
+
+	for _, portalInfo := range portalInfos {
+		duplicate := false
+		for _, previous := range deduped {
+			if previous.Portal.Equal(&portalInfo.Portal) &&
+				previous.InitiatorName == portalInfo.InitiatorName &&
+				previous.InitiatorPortNumber == portalInfo.InitiatorPortNumber {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			deduped = append(deduped, portalInfo)
+		}
+	}
+
+	return deduped
+}
@@ -0,0 +1,223 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePortal(t *testing.T) {
+	socketPtr := func(s uint16) *uint16 { return &s }
+
+	for _, testCase := range []struct {
+		input          string
+		expectedPortal *Portal
+		expectedString string
+		expectedError  string
+	}{
+		{
+			input:          "10.0.0.5:3261",
+			expectedPortal: &Portal{Address: "10.0.0.5", Socket: socketPtr(3261)},
+		},
+		{
+			input:          "10.0.0.5",
+			expectedPortal: &Portal{Address: "10.0.0.5"},
+			expectedString: "10.0.0.5:3260",
+		},
+		{
+			input:          "array.example.com",
+			expectedPortal: &Portal{Address: "array.example.com"},
+			expectedString: "array.example.com:3260",
+		},
+		{
+			input:          "array.example.com:3262",
+			expectedPortal: &Portal{Address: "array.example.com", Socket: socketPtr(3262)},
+		},
+		{
+			input:          "[fe80::1%eth0]:3260",
+			expectedPortal: &Portal{Address: "fe80::1%eth0", Socket: socketPtr(3260)},
+		},
+		{
+			input:          "[2001:db8::5]",
+			expectedPortal: &Portal{Address: "2001:db8::5"},
+			expectedString: "[2001:db8::5]:3260",
+		},
+		{
+			input:          "fe80::1%12",
+			expectedPortal: &Portal{Address: "fe80::1%12"},
+			expectedString: "[fe80::1%12]:3260",
+		},
+		{
+			input:         "",
+			expectedError: `invalid portal "": missing address`,
+		},
+		{
+			input:         ":3260",
+			expectedError: `invalid portal ":3260": missing address`,
+		},
+		{
+			input:         "10.0.0.5:",
+			expectedError: `invalid portal "10.0.0.5:": missing port after colon`,
+		},
+		{
+			input:         "10.0.0.5:port",
+			expectedError: `invalid portal "10.0.0.5:port": invalid port "port"`,
+		},
+		{
+			input:         "10.0.0.5:65536",
+			expectedError: `invalid portal "10.0.0.5:65536": invalid port "65536"`,
+		},
+		{
+			input:         "10.0.0.5:0",
+			expectedError: `invalid portal "10.0.0.5:0": invalid port "0"`,
+		},
+		{
+			input:         "[fe80::1%eth0:3260",
+			expectedError: `invalid portal "[fe80::1%eth0:3260": missing closing bracket`,
+		},
+		{
+			input:         "[fe80::1]3260",
+			expectedError: `invalid portal "[fe80::1]3260": unexpected "3260" after closing bracket`,
+		},
+		{
+			input:         "[10.0.0.5]:3260",
+			expectedError: `invalid portal "[10.0.0.5]:3260": only IPv6 addresses can be enclosed in brackets`,
+		},
+		{
+			input:         "fe80::1:3260:abcd:abcd:abcd:abcd:abcd:abcd",
+			expectedError: `invalid IPv6 address, or missing brackets`,
+		},
+		{
+			input:         "array example com",
+			expectedError: `invalid portal "array example com": invalid address "array example com"`,
+		},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			portal, err := ParsePortal(testCase.input)
+
+			if testCase.expectedError != "" {
+				assert.Nil(t, portal)
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), testCase.expectedError)
+				}
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, testCase.expectedPortal, portal)
+
+			expectedString := testCase.expectedString
+			if expectedString == "" {
+				expectedString = testCase.input
+			}
+			assert.Equal(t, expectedString, portal.String())
+
+			// and it should parse its own output
+			reparsed, err := ParsePortal(portal.String())
+			require.Nil(t, err)
+			assert.True(t, portal.Equal(reparsed))
+		})
+	}
+}
+
+func TestPortalNormalize(t *testing.T) {
+	socket := uint16(3261)
+
+	for _, testCase := range []struct {
+		input           *Portal
+		expectedAddress string
+		expectedSocket  uint16
+	}{
+		{&Portal{Address: "10.0.0.5"}, "10.0.0.5", 3260},
+		{&Portal{Address: "10.0.0.5", Socket: &socket}, "10.0.0.5", 3261},
+		{&Portal{Address: "Array.Example.COM."}, "array.example.com", 3260},
+		{&Portal{Address: "[FE80:0:0::1]"}, "fe80::1", 3260},
+		{&Portal{Address: "FE80:0::1%Ethernet0"}, "fe80::1%Ethernet0", 3260},
+		{&Portal{Address: ""}, "", 3260},
+	} {
+		t.Run(testCase.input.Address, func(t *testing.T) {
+			testCase.input.SymbolicName = "name"
+
+			normalized := testCase.input.Normalize()
+
+			assert.Equal(t, &Portal{SymbolicName: "name", Address: testCase.expectedAddress, Socket: &testCase.expectedSocket}, normalized)
+			assert.True(t, normalized.Equal(testCase.input))
+		})
+	}
+}
+
+func TestPortalRaw(t *testing.T) {
+	socket := uint16(3261)
+
+	for _, testCase := range []struct {
+		input           *Portal
+		expectedAddress string
+		expectedSocket  uint16
+	}{
+		{&Portal{Address: "10.0.0.5"}, "10.0.0.5", 3260},
+		{&Portal{Address: "10.0.0.5", Socket: &socket}, "10.0.0.5", 3261},
+		{&Portal{Address: "Array.Example.COM."}, "Array.Example.COM.", 3260},
+		{&Portal{Address: "[FE80:0:0::1]"}, "FE80:0:0::1", 3260},
+		{&Portal{Address: "::ffff:10.0.0.5"}, "::ffff:10.0.0.5", 3260},
+	} {
+		t.Run(testCase.input.Address, func(t *testing.T) {
+			testCase.input.SymbolicName = "name"
+
+			assert.Equal(t, &Portal{SymbolicName: "name", Address: testCase.expectedAddress, Socket: &testCase.expectedSocket}, testCase.input.Raw())
+		})
+	}
+}
+
+func TestPortalEqual(t *testing.T) {
+	defaultSocket := DefaultPortalSocket
+	otherSocket := uint16(3261)
+
+	assert.True(t, (&Portal{Address: "10.0.0.5"}).Equal(&Portal{Address: "10.0.0.5", Socket: &defaultSocket}))
+	assert.True(t, (&Portal{Address: "array.example.com", SymbolicName: "a"}).Equal(&Portal{Address: "ARRAY.example.com", SymbolicName: "b"}))
+	assert.True(t, (&Portal{Address: "[2001:db8::5]"}).Equal(&Portal{Address: "2001:0db8:0:0::5"}))
+
+	assert.False(t, (&Portal{Address: "10.0.0.5"}).Equal(&Portal{Address: "10.0.0.5", Socket: &otherSocket}))
+	assert.False(t, (&Portal{Address: "10.0.0.5"}).Equal(&Portal{Address: "10.0.0.6"}))
+	assert.False(t, (&Portal{Address: "fe80::1%1"}).Equal(&Portal{Address: "fe80::1%2"}))
+	assert.False(t, (&Portal{Address: "10.0.0.5"}).Equal(nil))
+
+	var nilPortal *Portal
+	assert.True(t, nilPortal.Equal(nil))
+}
+
+func TestPortalKey(t *testing.T) {
+	defaultSocket := DefaultPortalSocket
+	otherSocket := uint16(3261)
+
+	assert.Equal(t, "10.0.0.5:3260", (&Portal{Address: "10.0.0.5"}).Key())
+	assert.Equal(t, (&Portal{Address: "10.0.0.5"}).Key(), (&Portal{Address: "10.0.0.5", Socket: &defaultSocket}).Key())
+	assert.Equal(t, (&Portal{Address: "array.example.com."}).Key(), (&Portal{Address: "ARRAY.example.com", SymbolicName: "b"}).Key())
+	assert.Equal(t, (&Portal{Address: "[2001:db8::5]"}).Key(), (&Portal{Address: "2001:0db8:0:0::5"}).Key())
+
+	assert.NotEqual(t, (&Portal{Address: "10.0.0.5"}).Key(), (&Portal{Address: "10.0.0.5", Socket: &otherSocket}).Key())
+	assert.NotEqual(t, (&Portal{Address: "fe80::1%1"}).Key(), (&Portal{Address: "fe80::1%2"}).Key())
+}
+
+func TestDedupePortalInfos(t *testing.T) {
+	socket := uint16(3260)
+	otherSocket := uint16(3261)
+
+	portalInfos := []PortalInfo{
+		{Portal: Portal{Address: "array.example.com", Socket: &socket}, InitiatorPortNumber: 1},
+		{Portal: Portal{Address: "2001:db8::5", Socket: &socket}},
+		{Portal: Portal{Address: "ARRAY.example.com", Socket: &socket}, InitiatorPortNumber: 1},
+		{Portal: Portal{Address: "array.example.com", Socket: &otherSocket}, InitiatorPortNumber: 1},
+		{Portal: Portal{Address: "2001:0DB8:0::5", Socket: &socket}},
+		{Portal: Portal{Address: "array.example.com", Socket: &socket}, InitiatorPortNumber: 2},
+	}
+
+	deduped := DedupePortalInfos(portalInfos)
+
+	assert.Equal(t, []PortalInfo{
+		{Portal: Portal{Address: "array.example.com", Socket: &socket}, InitiatorPortNumber: 1},
+		{Portal: Portal{Address: "2001:db8::5", Socket: &socket}},
+		{Portal: Portal{Address: "array.example.com", Socket: &otherSocket}, InitiatorPortNumber: 1},
+		{Portal: Portal{Address: "array.example.com", Socket: &socket}, InitiatorPortNumber: 2},
+	}, deduped)
+}
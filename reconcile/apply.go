@@ -0,0 +1,171 @@
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/names"
+)
+
+// Executor performs actions. It's satisfied by `*iscsidsc.Client`.
+type Executor interface {
+	AddPortal(request *iscsidsc.AddPortalRequest) error
+	Login(request *iscsidsc.LoginRequest) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error)
+	AddConnection(request *iscsidsc.AddConnectionRequest) (*iscsidsc.ConnectionID, error)
+	// used to find existing sessions when logging in reports that there already is one
+	GetIScsiSessionList() ([]iscsidsc.SessionInfo, error)
+}
+
+// Status is the status of an applied action.
+type Status int
+
+// The various statuses of applied actions.
+const (
+	// Done means that the action succeeded.
+	Done Status = iota
+	// AlreadyDone means that the action failed with an error code saying that what it's meant
+	// to create already exists, which counts as a success.
+	AlreadyDone
+	// Failed means that the action failed.
+	Failed
+	// Skipped means that the action couldn't be attempted, because it depends on an action that failed.
+	Skipped
+)
+
+func (status Status) String() string {
+	switch status {
+	case Done:
+		return "done"
+	case AlreadyDone:
+		return "already done"
+	case Failed:
+		return "failed"
+	case Skipped:
+		return "skipped"
+	default:
+		return fmt.Sprintf("Status(%d)", int(status))
+	}
+}
+
+// Outcome is the outcome of applying an action.
+type Outcome struct {
+	Action *Action
+	Status Status
+	// only set for failed and skipped actions
+	Err error
+}
+
+// Outcomes are the outcomes of applying a list of actions.
+type Outcomes []Outcome
+
+// Err returns an error describing all the actions that failed or were skipped, if any; and nil otherwise.
+func (outcomes Outcomes) Err() error {
+	var failures []string
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", outcome.Action, outcome.Err))
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return errors.Errorf("%d action(s) out of %d failed: %s", len(failures), len(outcomes), strings.Join(failures, "; "))
+}
+
+// Apply applies actions in order, as returned by `Plan`, and returns their outcomes, in the same order.
+// Failing actions don't stop the others from being applied, except for connections to add to sessions
+// that failed to be created, which are skipped, with the login's error as cause.
+// Applying the same actions again is harmless, as errors saying that things already exist count as
+// successes; so if the state changed in-between, it's also safe to apply an outdated plan.
+// Use `iscsidsc.DefaultClient()` as executor to actually apply actions.
+func Apply(executor Executor, actions []*Action) Outcomes {
+	outcomes := make(Outcomes, len(actions))
+	// the sessions created by login actions, by normalized target name
+	sessionIDs := make(map[string]iscsidsc.SessionID)
+	// why login actions didn't yield sessions, by normalized target name
+	loginErrs := make(map[string]error)
+
+	for i, action := range actions {
+		outcome := Outcome{Action: action}
+
+		var err error
+		switch action.Type {
+		case AddPortal:
+			err = executor.AddPortal(action.AddPortalRequest)
+		case Login:
+			var sessionID *iscsidsc.SessionID
+			sessionID, _, err = executor.Login(action.LoginRequest)
+			if isAlreadyExists(action.Type, err) {
+				var findErr error
+				if sessionID, findErr = findSession(executor, action.TargetName); findErr != nil {
+					err = errors.Wrapf(findErr, "unable to find the existing session to target %s", action.TargetName)
+				}
+			}
+			if sessionID != nil {
+				sessionIDs[names.Canonical(action.TargetName)] = *sessionID
+			} else if err != nil {
+				loginErrs[names.Canonical(action.TargetName)] = err
+			}
+		case AddConnection:
+			request := *action.AddConnectionRequest
+			if request.SessionID.IsZero() {
+				sessionID, present := sessionIDs[names.Canonical(action.TargetName)]
+				if !present {
+					outcome.Status = Skipped
+					if loginErr, failed := loginErrs[names.Canonical(action.TargetName)]; failed {
+						outcome.Err = errors.Wrapf(loginErr, "no session to target %s", action.TargetName)
+					} else {
+						outcome.Err = errors.Errorf("no session to target %s", action.TargetName)
+					}
+					outcomes[i] = outcome
+					continue
+				}
+				request.SessionID = sessionID
+			}
+			_, err = executor.AddConnection(&request)
+		default:
+			err = errors.Errorf("unknown action type %v", action.Type)
+		}
+
+		switch {
+		case err == nil:
+			outcome.Status = Done
+		case isAlreadyExists(action.Type, err):
+			outcome.Status = AlreadyDone
+		default:
+			outcome.Status = Failed
+			outcome.Err = err
+		}
+		outcomes[i] = outcome
+	}
+
+	return outcomes
+}
+
+// isAlreadyExists returns true iff err means that the action's outcome already exists.
+func isAlreadyExists(actionType ActionType, err error) bool {
+	switch actionType {
+	case AddPortal:
+		return iscsidsc.HasErrorCode(err, iscsidsc.ErrTargetPortalAlreadyExists, iscsidsc.ErrTargetAddressAlreadyExists)
+	case Login:
+		return iscsidsc.IsAlreadyLoggedIn(err)
+	case AddConnection:
+		return iscsidsc.HasErrorCode(err, iscsidsc.ErrConnectionAlreadyExists)
+	default:
+		return false
+	}
+}
+
+// findSession looks for an existing session to the given target.
+func findSession(executor Executor, targetName string) (*iscsidsc.SessionID, error) {
+	sessions, err := executor.GetIScsiSessionList()
+	if err != nil {
+		return nil, err
+	}
+	if session := iscsidsc.FindSession(sessions, targetName, nil); session != nil {
+		return &session.SessionID, nil
+	}
+	return nil, nil
+}
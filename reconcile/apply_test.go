@@ -0,0 +1,208 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// fakeExecutor records the requests it gets, and fails them as told.
+type fakeExecutor struct {
+	sessions    []iscsidsc.SessionInfo
+	sessionsErr error
+	// by action type, the errors to return, in order; nil when out of errors
+	errs     map[ActionType][]error
+	requests []interface{}
+	// the session IDs returned by successful logins
+	nextSessionID iscsidsc.SessionID
+}
+
+func (e *fakeExecutor) nextErr(actionType ActionType) error {
+	errs := e.errs[actionType]
+	if len(errs) == 0 {
+		return nil
+	}
+	e.errs[actionType] = errs[1:]
+	return errs[0]
+}
+
+func (e *fakeExecutor) AddPortal(request *iscsidsc.AddPortalRequest) error {
+	e.requests = append(e.requests, request)
+	return e.nextErr(AddPortal)
+}
+
+func (e *fakeExecutor) Login(request *iscsidsc.LoginRequest) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	e.requests = append(e.requests, request)
+	if err := e.nextErr(Login); err != nil {
+		return nil, nil, err
+	}
+	sessionID := e.nextSessionID
+	return &sessionID, &iscsidsc.ConnectionID{}, nil
+}
+
+func (e *fakeExecutor) AddConnection(request *iscsidsc.AddConnectionRequest) (*iscsidsc.ConnectionID, error) {
+	e.requests = append(e.requests, request)
+	if err := e.nextErr(AddConnection); err != nil {
+		return nil, err
+	}
+	return &iscsidsc.ConnectionID{}, nil
+}
+
+func (e *fakeExecutor) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return e.sessions, e.sessionsErr
+}
+
+var _ Executor = &iscsidsc.Client{}
+
+func winAPIError(code iscsidsc.ErrorCode) error {
+	return iscsidsc.NewWinAPICallError("Dummy", uintptr(code))
+}
+
+func plannedActions(t *testing.T) []*Action {
+	actions, err := Plan(snapshot(t, &fixtureFetcher{}), &DesiredState{
+		Portals: []DesiredPortal{{Portal: portal("10.0.0.5")}},
+		Targets: []DesiredTarget{
+			{Name: target1, Connections: []iscsidsc.Portal{portal("10.0.0.5"), portal("10.0.0.6")}},
+			{Name: target2, Connections: []iscsidsc.Portal{portal("10.0.0.5"), portal("10.0.0.6")}},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 5, len(actions))
+	return actions
+}
+
+func statuses(outcomes Outcomes) (result []Status) {
+	for _, outcome := range outcomes {
+		result = append(result, outcome.Status)
+	}
+	return
+}
+
+func TestApply(t *testing.T) {
+	t.Run("when all goes well", func(t *testing.T) {
+		actions := plannedActions(t)
+		executor := &fakeExecutor{nextSessionID: session1}
+
+		outcomes := Apply(executor, actions)
+
+		assert.Equal(t, []Status{Done, Done, Done, Done, Done}, statuses(outcomes))
+		assert.Nil(t, outcomes.Err())
+		for i, outcome := range outcomes {
+			assert.Equal(t, actions[i], outcome.Action)
+		}
+
+		// connections are added to the sessions just created
+		require.Equal(t, 5, len(executor.requests))
+		addConnectionRequest := executor.requests[2].(*iscsidsc.AddConnectionRequest)
+		assert.Equal(t, session1, addConnectionRequest.SessionID)
+		// without modifying the plan
+		assert.True(t, actions[2].AddConnectionRequest.SessionID.IsZero())
+	})
+
+	t.Run("things that already exist count as successes", func(t *testing.T) {
+		actions := plannedActions(t)
+		executor := &fakeExecutor{
+			sessions: []iscsidsc.SessionInfo{sessionInfo(session2, "IQN.1991-05.com.microsoft:Target-1")},
+			errs: map[ActionType][]error{
+				AddPortal:     {winAPIError(iscsidsc.ErrTargetPortalAlreadyExists)},
+				Login:         {winAPIError(iscsidsc.ErrTargetAlreadyLoggedIn)},
+				AddConnection: {nil, winAPIError(iscsidsc.ErrConnectionAlreadyExists)},
+			},
+			nextSessionID: session3,
+		}
+
+		outcomes := Apply(executor, actions)
+
+		assert.Equal(t, []Status{AlreadyDone, AlreadyDone, Done, Done, AlreadyDone}, statuses(outcomes))
+		assert.Nil(t, outcomes.Err())
+
+		// the connection gets added to the existing session
+		assert.Equal(t, session2, executor.requests[2].(*iscsidsc.AddConnectionRequest).SessionID)
+		assert.Equal(t, session3, executor.requests[4].(*iscsidsc.AddConnectionRequest).SessionID)
+	})
+
+	t.Run("failures don't stop other actions, but skip those depending on them", func(t *testing.T) {
+		actions := plannedActions(t)
+		portalErr := winAPIError(iscsidsc.ErrConnectionFailed)
+		loginErr := winAPIError(iscsidsc.ErrLoginAuthFailed)
+		executor := &fakeExecutor{
+			errs: map[ActionType][]error{
+				AddPortal: {portalErr},
+				Login:     {loginErr},
+			},
+			nextSessionID: session1,
+		}
+
+		outcomes := Apply(executor, actions)
+
+		assert.Equal(t, []Status{Failed, Failed, Skipped, Done, Done}, statuses(outcomes))
+		assert.Equal(t, portalErr, outcomes[0].Err)
+		assert.Equal(t, loginErr, outcomes[1].Err)
+		assert.Equal(t, "no session to target iqn.1991-05.com.microsoft:target-1: "+loginErr.Error(), outcomes[2].Err.Error())
+		assert.Equal(t, loginErr, errors.Cause(outcomes[2].Err))
+		// the skipped action wasn't attempted
+		assert.Equal(t, 4, len(executor.requests))
+
+		err := outcomes.Err()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "3 action(s) out of 5 failed: add portal 10.0.0.5:3260: ")
+			assert.Contains(t, err.Error(), "; add connection to iqn.1991-05.com.microsoft:target-1 via 10.0.0.6:3260: no session to target")
+		}
+	})
+
+	t.Run("failing to find the existing session fails the login", func(t *testing.T) {
+		actions := plannedActions(t)
+		listErr := winAPIError(iscsidsc.ErrConnectionFailed)
+		executor := &fakeExecutor{
+			sessionsErr: listErr,
+			errs: map[ActionType][]error{
+				Login: {winAPIError(iscsidsc.ErrSessionAlreadyExists)},
+			},
+			nextSessionID: session1,
+		}
+
+		outcomes := Apply(executor, actions)
+
+		assert.Equal(t, []Status{Done, Failed, Skipped, Done, Done}, statuses(outcomes))
+		assert.Equal(t, listErr, errors.Cause(outcomes[1].Err))
+		assert.Equal(t, "unable to find the existing session to target iqn.1991-05.com.microsoft:target-1: "+listErr.Error(), outcomes[1].Err.Error())
+		assert.Equal(t, listErr, errors.Cause(outcomes[2].Err))
+		assert.Contains(t, outcomes[2].Err.Error(), "no session to target iqn.1991-05.com.microsoft:target-1: unable to find the existing session")
+	})
+
+	t.Run("it's idempotent", func(t *testing.T) {
+		actions := plannedActions(t)
+		executor := &fakeExecutor{nextSessionID: session1}
+		require.Nil(t, Apply(executor, actions).Err())
+
+		// applying again, the system now says everything already exists
+		executor.sessions = []iscsidsc.SessionInfo{sessionInfo(session1, target1), sessionInfo(session2, target2)}
+		executor.errs = map[ActionType][]error{
+			AddPortal:     {winAPIError(iscsidsc.ErrTargetPortalAlreadyExists)},
+			Login:         {winAPIError(iscsidsc.ErrTargetAlreadyLoggedIn), winAPIError(iscsidsc.ErrSessionAlreadyExists)},
+			AddConnection: {winAPIError(iscsidsc.ErrConnectionAlreadyExists), winAPIError(iscsidsc.ErrConnectionAlreadyExists)},
+		}
+
+		outcomes := Apply(executor, actions)
+
+		assert.Equal(t, []Status{AlreadyDone, AlreadyDone, AlreadyDone, AlreadyDone, AlreadyDone}, statuses(outcomes))
+		assert.Equal(t, session2, executor.requests[9].(*iscsidsc.AddConnectionRequest).SessionID)
+	})
+
+	t.Run("other errors aren't treated as already existing", func(t *testing.T) {
+		err := errors.Wrap(winAPIError(iscsidsc.ErrTargetAlreadyLoggedIn), "wrapped")
+		assert.True(t, isAlreadyExists(Login, err))
+		assert.False(t, isAlreadyExists(AddConnection, err))
+		assert.False(t, isAlreadyExists(Login, errors.New("dummy")))
+		assert.False(t, isAlreadyExists(Login, nil))
+	})
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "already done", AlreadyDone.String())
+	assert.Equal(t, "Status(12)", Status(12).String())
+	assert.Equal(t, "ActionType(12)", ActionType(12).String())
+}
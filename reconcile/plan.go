@@ -0,0 +1,266 @@
+package reconcile
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/names"
+	"github.com/wk8/go-win-iscsidsc/topology"
+)
+
+// DesiredState describes the portals a node should have registered, and the targets it should be
+// logged in to.
+type DesiredState struct {
+	Portals []DesiredPortal
+	Targets []DesiredTarget
+}
+
+// DesiredPortal is a send target portal that should be registered.
+// Portals are only compared by address and port: the other fields are only used when registering them.
+type DesiredPortal struct {
+	Portal              iscsidsc.Portal
+	InitiatorInstance   *string
+	InitiatorPortNumber *uint32
+	SecurityFlags       *iscsidsc.SecurityFlags
+	LoginOptions        *iscsidsc.LoginOptions
+}
+
+// DesiredTarget is a target that there should be a session to.
+// All fields other than the target name and connections are only used when logging in or adding connections:
+// Windows' API doesn't report them for existing sessions, so they're not compared.
+type DesiredTarget struct {
+	Name string
+	// one entry per connection the session should have, through the given portal; the first one is used
+	// to log in, and the others are added to the session. If empty, Windows picks the portal to log in
+	// through, and the session's connections are not checked.
+	Connections         []iscsidsc.Portal
+	InitiatorInstance   *string
+	InitiatorPortNumber *uint32
+	SecurityFlags       *iscsidsc.SecurityFlags
+	LoginOptions        *iscsidsc.LoginOptions
+	Key                 *iscsidsc.Secret
+	IsPersistent        bool
+}
+
+// ActionType is the type of an `Action`.
+type ActionType int
+
+// The various types of actions.
+const (
+	AddPortal ActionType = iota
+	Login
+	AddConnection
+)
+
+func (actionType ActionType) String() string {
+	switch actionType {
+	case AddPortal:
+		return "add portal"
+	case Login:
+		return "log in"
+	case AddConnection:
+		return "add connection"
+	default:
+		return fmt.Sprintf("ActionType(%d)", int(actionType))
+	}
+}
+
+// Action is one of the operations needed to reach the desired state.
+// Only the request matching the action's type is set.
+type Action struct {
+	Type ActionType
+	// the target the action is about; empty for portals
+	TargetName       string
+	AddPortalRequest *iscsidsc.AddPortalRequest
+	LoginRequest     *iscsidsc.LoginRequest
+	// its session ID is left zero when the session is to be created by a previous login action, and is
+	// then filled when applying
+	AddConnectionRequest *iscsidsc.AddConnectionRequest
+}
+
+// String describes the action, e.g. "log in to iqn.1991-05.com.microsoft:target via 10.0.0.5:3260".
+func (action *Action) String() string {
+	switch action.Type {
+	case AddPortal:
+		return fmt.Sprintf("add portal %v", action.AddPortalRequest.Portal)
+	case Login:
+		if action.LoginRequest.TargetPortal == nil {
+			return fmt.Sprintf("log in to %s", action.TargetName)
+		}
+		return fmt.Sprintf("log in to %s via %v", action.TargetName, action.LoginRequest.TargetPortal)
+	case AddConnection:
+		return fmt.Sprintf("add connection to %s via %v", action.TargetName, action.AddConnectionRequest.TargetPortal)
+	default:
+		return action.Type.String()
+	}
+}
+
+// Plan returns the actions needed to go from the current state to the desired state, in the order they
+// should be applied in: portals first, then targets in the order they're listed in, each target's login
+// followed by the connections to add to its session.
+// Plans only ever add things: portals, sessions and connections that aren't desired are left alone.
+// When there are several sessions to a desired target, the one with the most desired connections is
+// completed.
+// Persistence is not reconciled: Windows doesn't report whether sessions are persistent, so an existing
+// session satisfies a desired target regardless of its `IsPersistent` field, which is only used when
+// logging in.
+// A nil current snapshot is treated as an empty snapshot.
+func Plan(current *topology.Snapshot, desired *DesiredState) ([]*Action, error) {
+	if err := desired.validate(); err != nil {
+		return nil, err
+	}
+	if current == nil {
+		current = &topology.Snapshot{}
+	}
+
+	var actions []*Action
+
+	for i := range desired.Portals {
+		desiredPortal := &desired.Portals[i]
+		if portal := current.Portal(&desiredPortal.Portal); portal != nil && len(portal.Registrations) != 0 {
+			continue
+		}
+
+		portal := desiredPortal.Portal
+		actions = append(actions, &Action{
+			Type: AddPortal,
+			AddPortalRequest: &iscsidsc.AddPortalRequest{
+				InitiatorInstance:   desiredPortal.InitiatorInstance,
+				InitiatorPortNumber: desiredPortal.InitiatorPortNumber,
+				LoginOptions:        desiredPortal.LoginOptions,
+				SecurityFlags:       desiredPortal.SecurityFlags,
+				Portal:              &portal,
+			},
+		})
+	}
+
+	for i := range desired.Targets {
+		actions = append(actions, planTarget(current, &desired.Targets[i])...)
+	}
+
+	return actions, nil
+}
+
+func planTarget(current *topology.Snapshot, desiredTarget *DesiredTarget) (actions []*Action) {
+	missingConnections := desiredTarget.Connections
+	var sessionID iscsidsc.SessionID
+
+	if session := bestSession(current, desiredTarget); session != nil {
+		sessionID = session.Info.SessionID
+		missingConnections = missingPortals(desiredTarget.Connections, session)
+	} else {
+		loginRequest := &iscsidsc.LoginRequest{
+			TargetName:          desiredTarget.Name,
+			InitiatorInstance:   desiredTarget.InitiatorInstance,
+			InitiatorPortNumber: desiredTarget.InitiatorPortNumber,
+			SecurityFlags:       desiredTarget.SecurityFlags,
+			LoginOptions:        desiredTarget.LoginOptions,
+			Key:                 desiredTarget.Key,
+			IsPersistent:        desiredTarget.IsPersistent,
+		}
+		if len(missingConnections) != 0 {
+			portal := missingConnections[0]
+			loginRequest.TargetPortal = &portal
+			missingConnections = missingConnections[1:]
+		}
+		actions = append(actions, &Action{
+			Type:         Login,
+			TargetName:   desiredTarget.Name,
+			LoginRequest: loginRequest,
+		})
+	}
+
+	for _, connection := range missingConnections {
+		portal := connection
+		actions = append(actions, &Action{
+			Type:       AddConnection,
+			TargetName: desiredTarget.Name,
+			AddConnectionRequest: &iscsidsc.AddConnectionRequest{
+				SessionID:           sessionID,
+				InitiatorPortNumber: desiredTarget.InitiatorPortNumber,
+				TargetPortal:        &portal,
+				SecurityFlags:       desiredTarget.SecurityFlags,
+				LoginOptions:        desiredTarget.LoginOptions,
+				Key:                 desiredTarget.Key,
+			},
+		})
+	}
+
+	return
+}
+
+// bestSession returns the existing session to the target with the fewest missing connections, if any.
+func bestSession(current *topology.Snapshot, desiredTarget *DesiredTarget) (best *topology.Session) {
+	target := current.Target(desiredTarget.Name)
+	if target == nil {
+		return nil
+	}
+
+	bestMissingCount := 0
+	for _, id := range target.SessionIDs {
+		session := current.Session(id)
+		if session == nil {
+			continue
+		}
+		missingCount := len(missingPortals(desiredTarget.Connections, session))
+		if best == nil || missingCount < bestMissingCount {
+			best, bestMissingCount = session, missingCount
+		}
+	}
+	return
+}
+
+// missingPortals returns the desired connections that the session doesn't have.
+func missingPortals(desiredConnections []iscsidsc.Portal, session *topology.Session) (missing []iscsidsc.Portal) {
+	existing := make(map[string]int)
+	for _, connection := range session.Info.Connections {
+		existing[connection.TargetPortal().Key()]++
+	}
+
+	for i := range desiredConnections {
+		key := desiredConnections[i].Key()
+		if existing[key] > 0 {
+			existing[key]--
+		} else {
+			missing = append(missing, desiredConnections[i])
+		}
+	}
+	return
+}
+
+func (desired *DesiredState) validate() error {
+	portals := make(map[string]bool)
+	for i := range desired.Portals {
+		portal := &desired.Portals[i].Portal
+		if portal.Address == "" {
+			return errors.Errorf("desired portal #%d has no address", i+1)
+		}
+		key := portal.Key()
+		if portals[key] {
+			return errors.Errorf("portal %s is listed more than once", key)
+		}
+		portals[key] = true
+	}
+
+	targets := make(map[string]bool)
+	for i := range desired.Targets {
+		target := &desired.Targets[i]
+		normalized, err := names.Normalize(target.Name)
+		if err != nil {
+			return errors.Wrapf(err, "invalid desired target #%d", i+1)
+		}
+		if targets[normalized] {
+			return errors.Errorf("target %s is listed more than once", target.Name)
+		}
+		targets[normalized] = true
+
+		for j := range target.Connections {
+			if target.Connections[j].Address == "" {
+				return errors.Errorf("connection #%d to target %s has no address", j+1, target.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/topology"
+)
+
+// fixtureFetcher serves a fixed state.
+type fixtureFetcher struct {
+	portalInfos []iscsidsc.PortalInfo
+	sessions    []iscsidsc.SessionInfo
+}
+
+func (f *fixtureFetcher) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	return f.portalInfos, nil
+}
+
+func (f *fixtureFetcher) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fixtureFetcher) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return f.sessions, nil
+}
+
+func (f *fixtureFetcher) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	return nil, nil
+}
+
+const (
+	target1 = "iqn.1991-05.com.microsoft:target-1"
+	target2 = "iqn.1991-05.com.microsoft:target-2"
+	target3 = "iqn.1991-05.com.microsoft:target-3"
+)
+
+var (
+	session1 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000001}
+	session2 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002}
+	session3 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000003}
+)
+
+func sessionInfo(id iscsidsc.SessionID, targetName string, addresses ...string) iscsidsc.SessionInfo {
+	info := iscsidsc.SessionInfo{SessionID: id, TargetNodeName: targetName}
+	for _, address := range addresses {
+		info.Connections = append(info.Connections, iscsidsc.ConnectionInfo{TargetAddress: address, TargetSocket: 3260})
+	}
+	return info
+}
+
+func snapshot(t *testing.T, fetcher *fixtureFetcher) *topology.Snapshot {
+	result, err := topology.Build(fetcher)
+	require.Nil(t, err)
+	return result
+}
+
+func portal(address string) iscsidsc.Portal {
+	return iscsidsc.Portal{Address: address}
+}
+
+func TestPlan(t *testing.T) {
+	current := snapshot(t, &fixtureFetcher{
+		portalInfos: []iscsidsc.PortalInfo{{Portal: portal("10.0.0.5")}},
+		sessions: []iscsidsc.SessionInfo{
+			sessionInfo(session1, target1, "10.0.0.5"),
+			sessionInfo(session2, target2, "10.0.0.5"),
+			sessionInfo(session3, target2, "10.0.0.5", "10.0.0.6"),
+		},
+	})
+
+	securityFlags := iscsidsc.SecurityFlagIkeIpsecEnabled
+	desired := &DesiredState{
+		Portals: []DesiredPortal{
+			// already registered, with an explicit port this time
+			{Portal: iscsidsc.Portal{Address: "10.0.0.5", Socket: uint16Ptr(3260)}},
+			{Portal: portal("10.0.0.6"), SecurityFlags: &securityFlags},
+		},
+		Targets: []DesiredTarget{
+			// one connection is missing
+			{Name: target1, Connections: []iscsidsc.Portal{portal("10.0.0.5"), portal("10.0.0.6")}},
+			// session 3 already has all the desired connections
+			{Name: "IQN.1991-05.com.microsoft:TARGET-2", Connections: []iscsidsc.Portal{portal("10.0.0.6"), portal("10.0.0.5")}},
+			// no session yet
+			{Name: target3, Connections: []iscsidsc.Portal{portal("10.0.0.6"), portal("10.0.0.6")}, IsPersistent: true},
+		},
+	}
+
+	actions, err := Plan(current, desired)
+	require.Nil(t, err)
+
+	var descriptions []string
+	for _, action := range actions {
+		descriptions = append(descriptions, action.String())
+	}
+	assert.Equal(t, []string{
+		"add portal 10.0.0.6:3260",
+		"add connection to iqn.1991-05.com.microsoft:target-1 via 10.0.0.6:3260",
+		"log in to iqn.1991-05.com.microsoft:target-3 via 10.0.0.6:3260",
+		"add connection to iqn.1991-05.com.microsoft:target-3 via 10.0.0.6:3260",
+	}, descriptions)
+
+	assert.Equal(t, &securityFlags, actions[0].AddPortalRequest.SecurityFlags)
+	assert.Equal(t, session1, actions[1].AddConnectionRequest.SessionID)
+	assert.Equal(t, target3, actions[2].LoginRequest.TargetName)
+	assert.True(t, actions[2].LoginRequest.IsPersistent)
+	// to be resolved when applying
+	assert.True(t, actions[3].AddConnectionRequest.SessionID.IsZero())
+
+	t.Run("there's nothing to do once the desired state is reached", func(t *testing.T) {
+		reached := snapshot(t, &fixtureFetcher{
+			portalInfos: []iscsidsc.PortalInfo{{Portal: portal("10.0.0.5")}, {Portal: portal("10.0.0.6")}},
+			sessions: []iscsidsc.SessionInfo{
+				sessionInfo(session1, target1, "10.0.0.6", "10.0.0.5"),
+				sessionInfo(session2, target2, "10.0.0.5", "10.0.0.6"),
+				sessionInfo(session3, target3, "10.0.0.6", "10.0.0.6", "10.0.0.7"),
+			},
+		})
+
+		actions, err := Plan(reached, desired)
+
+		require.Nil(t, err)
+		assert.Equal(t, 0, len(actions))
+	})
+
+	t.Run("targets without connections only need a session", func(t *testing.T) {
+		actions, err := Plan(current, &DesiredState{Targets: []DesiredTarget{{Name: target1}, {Name: target3}}})
+
+		require.Nil(t, err)
+		require.Equal(t, 1, len(actions))
+		assert.Equal(t, "log in to iqn.1991-05.com.microsoft:target-3", actions[0].String())
+		assert.Nil(t, actions[0].LoginRequest.TargetPortal)
+	})
+
+	t.Run("a nil current state is an empty one", func(t *testing.T) {
+		actions, err := Plan(nil, desired)
+
+		require.Nil(t, err)
+		fromEmpty, err := Plan(snapshot(t, &fixtureFetcher{}), desired)
+		require.Nil(t, err)
+		assert.Equal(t, fromEmpty, actions)
+		assert.Equal(t, 8, len(actions))
+	})
+
+	t.Run("persistence isn't reconciled", func(t *testing.T) {
+		// session 1 may well not be persistent
+		actions, err := Plan(current, &DesiredState{Targets: []DesiredTarget{{Name: target1, IsPersistent: true}}})
+
+		require.Nil(t, err)
+		assert.Equal(t, 0, len(actions))
+	})
+}
+
+func TestPlanWithInvalidDesiredStates(t *testing.T) {
+	current := snapshot(t, &fixtureFetcher{})
+
+	for _, testCase := range []struct {
+		name          string
+		desired       *DesiredState
+		expectedError string
+	}{
+		{
+			name:          "portal without an address",
+			desired:       &DesiredState{Portals: []DesiredPortal{{Portal: portal("10.0.0.5")}, {}}},
+			expectedError: "desired portal #2 has no address",
+		},
+		{
+			name:          "duplicate portals",
+			desired:       &DesiredState{Portals: []DesiredPortal{{Portal: portal("10.0.0.5")}, {Portal: iscsidsc.Portal{Address: "10.0.0.5", Socket: uint16Ptr(3260)}}}},
+			expectedError: "portal 10.0.0.5:3260 is listed more than once",
+		},
+		{
+			name:          "invalid target name",
+			desired:       &DesiredState{Targets: []DesiredTarget{{Name: "iqn.1991-05.com.microsoft:target 1"}}},
+			expectedError: `invalid desired target #1: invalid iSCSI name "iqn.1991-05.com.microsoft:target 1"`,
+		},
+		{
+			name:          "duplicate targets",
+			desired:       &DesiredState{Targets: []DesiredTarget{{Name: target1}, {Name: "IQN.1991-05.com.microsoft:target-1"}}},
+			expectedError: "target IQN.1991-05.com.microsoft:target-1 is listed more than once",
+		},
+		{
+			name:          "connection without an address",
+			desired:       &DesiredState{Targets: []DesiredTarget{{Name: target1, Connections: []iscsidsc.Portal{{}}}}},
+			expectedError: "connection #1 to target iqn.1991-05.com.microsoft:target-1 has no address",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			actions, err := Plan(current, testCase.desired)
+
+			assert.Nil(t, actions)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), testCase.expectedError)
+			}
+		})
+	}
+}
+
+func uint16Ptr(value uint16) *uint16 {
+	return &value
+}
@@ -0,0 +1,279 @@
+package iscsidsc
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wk8/go-win-iscsidsc/names"
+)
+
+// This file contains request types for the operations that take many arguments, most of them optional.
+// Requests can either be built as struct literals, or with their `New...Request` constructors and `RequestOption`s.
+
+// LoginRequest gathers the arguments of `Client.LoginIscsiTarget`.
+// Only TargetName is required.
+type LoginRequest struct {
+	TargetName             string
+	IsInformationalSession bool
+	InitiatorInstance      *string
+	InitiatorPortNumber    *uint32
+	TargetPortal           *Portal
+	SecurityFlags          *SecurityFlags
+	LoginOptions           *LoginOptions
+	Key                    *Secret
+	IsPersistent           bool
+
+	unsupportedOptions []string
+}
+
+// AddPortalRequest gathers the arguments of `Client.AddIScsiSendTargetPortal`.
+// Only Portal is required.
+type AddPortalRequest struct {
+	InitiatorInstance   *string
+	InitiatorPortNumber *uint32
+	LoginOptions        *LoginOptions
+	SecurityFlags       *SecurityFlags
+	Portal              *Portal
+
+	unsupportedOptions []string
+}
+
+// AddConnectionRequest gathers the arguments of `Client.AddIScsiConnection`.
+// Only SessionID and TargetPortal are required.
+type AddConnectionRequest struct {
+	SessionID           SessionID
+	InitiatorPortNumber *uint32
+	TargetPortal        *Portal
+	SecurityFlags       *SecurityFlags
+	LoginOptions        *LoginOptions
+	Key                 *Secret
+
+	unsupportedOptions []string
+}
+
+// requestFields holds the optional fields set by `RequestOption`s, until they're copied to a request.
+type requestFields struct {
+	isInformationalSession bool
+	initiatorInstance      *string
+	initiatorPortNumber    *uint32
+	targetPortal           *Portal
+	securityFlags          *SecurityFlags
+	loginOptions           *LoginOptions
+	key                    *Secret
+	isPersistent           bool
+
+	// set lists the names of the options that have been applied
+	set map[string]bool
+}
+
+// RequestOption sets one of the optional fields of a request.
+// Options apply to all the request types having the corresponding field; using one on a request
+// type that doesn't have that field makes the request invalid.
+type RequestOption struct {
+	name  string
+	apply func(fields *requestFields)
+}
+
+// WithInformationalSession makes the login request establish an informational session.
+func WithInformationalSession() RequestOption {
+	return RequestOption{"WithInformationalSession", func(fields *requestFields) {
+		fields.isInformationalSession = true
+	}}
+}
+
+// WithInitiatorInstance sets the initiator HBA to use.
+func WithInitiatorInstance(initiatorInstance string) RequestOption {
+	return RequestOption{"WithInitiatorInstance", func(fields *requestFields) {
+		fields.initiatorInstance = &initiatorInstance
+	}}
+}
+
+// WithInitiatorPortNumber sets the port number of the initiator HBA to use.
+func WithInitiatorPortNumber(initiatorPortNumber uint32) RequestOption {
+	return RequestOption{"WithInitiatorPortNumber", func(fields *requestFields) {
+		fields.initiatorPortNumber = &initiatorPortNumber
+	}}
+}
+
+// WithTargetPortal sets the portal to log in through.
+func WithTargetPortal(targetPortal *Portal) RequestOption {
+	return RequestOption{"WithTargetPortal", func(fields *requestFields) {
+		fields.targetPortal = targetPortal
+	}}
+}
+
+// WithSecurityFlags sets the security flags.
+func WithSecurityFlags(securityFlags SecurityFlags) RequestOption {
+	return RequestOption{"WithSecurityFlags", func(fields *requestFields) {
+		fields.securityFlags = &securityFlags
+	}}
+}
+
+// WithLoginOptions sets the login options.
+func WithLoginOptions(loginOptions *LoginOptions) RequestOption {
+	return RequestOption{"WithLoginOptions", func(fields *requestFields) {
+		fields.loginOptions = loginOptions
+	}}
+}
+
+// WithKey sets the IPsec pre-shared key.
+func WithKey(key *Secret) RequestOption {
+	return RequestOption{"WithKey", func(fields *requestFields) {
+		fields.key = key
+	}}
+}
+
+// WithPersistence makes the login request persistent, i.e. Windows will log in again after reboots.
+func WithPersistence() RequestOption {
+	return RequestOption{"WithPersistence", func(fields *requestFields) {
+		fields.isPersistent = true
+	}}
+}
+
+// applyRequestOptions applies opts, and returns the resulting fields as well as the sorted names of the
+// options that aren't in supported.
+func applyRequestOptions(opts []RequestOption, supported ...string) (*requestFields, []string) {
+	fields := &requestFields{set: make(map[string]bool)}
+	for _, opt := range opts {
+		opt.apply(fields)
+		fields.set[opt.name] = true
+	}
+
+	for _, name := range supported {
+		delete(fields.set, name)
+	}
+	var unsupported []string
+	for name := range fields.set {
+		unsupported = append(unsupported, name)
+	}
+	sort.Strings(unsupported)
+
+	return fields, unsupported
+}
+
+// NewLoginRequest builds a new `LoginRequest`.
+func NewLoginRequest(targetName string, opts ...RequestOption) *LoginRequest {
+	// all options apply to login requests
+	fields, unsupported := applyRequestOptions(opts, "WithInformationalSession", "WithInitiatorInstance", "WithInitiatorPortNumber",
+		"WithTargetPortal", "WithSecurityFlags", "WithLoginOptions", "WithKey", "WithPersistence")
+
+	return &LoginRequest{
+		TargetName:             targetName,
+		IsInformationalSession: fields.isInformationalSession,
+		InitiatorInstance:      fields.initiatorInstance,
+		InitiatorPortNumber:    fields.initiatorPortNumber,
+		TargetPortal:           fields.targetPortal,
+		SecurityFlags:          fields.securityFlags,
+		LoginOptions:           fields.loginOptions,
+		Key:                    fields.key,
+		IsPersistent:           fields.isPersistent,
+		unsupportedOptions:     unsupported,
+	}
+}
+
+// NewAddPortalRequest builds a new `AddPortalRequest`.
+func NewAddPortalRequest(portal *Portal, opts ...RequestOption) *AddPortalRequest {
+	fields, unsupported := applyRequestOptions(opts, "WithInitiatorInstance", "WithInitiatorPortNumber", "WithSecurityFlags", "WithLoginOptions")
+
+	return &AddPortalRequest{
+		InitiatorInstance:   fields.initiatorInstance,
+		InitiatorPortNumber: fields.initiatorPortNumber,
+		LoginOptions:        fields.loginOptions,
+		SecurityFlags:       fields.securityFlags,
+		Portal:              portal,
+		unsupportedOptions:  unsupported,
+	}
+}
+
+// NewAddConnectionRequest builds a new `AddConnectionRequest`.
+func NewAddConnectionRequest(sessionID SessionID, targetPortal *Portal, opts ...RequestOption) *AddConnectionRequest {
+	fields, unsupported := applyRequestOptions(opts, "WithInitiatorPortNumber", "WithSecurityFlags", "WithLoginOptions", "WithKey")
+
+	return &AddConnectionRequest{
+		SessionID:           sessionID,
+		InitiatorPortNumber: fields.initiatorPortNumber,
+		TargetPortal:        targetPortal,
+		SecurityFlags:       fields.securityFlags,
+		LoginOptions:        fields.loginOptions,
+		Key:                 fields.key,
+		unsupportedOptions:  unsupported,
+	}
+}
+
+// Validate checks that the request is well-formed.
+func (r *LoginRequest) Validate() error {
+	if err := checkUnsupportedOptions("login", r.unsupportedOptions); err != nil {
+		return err
+	}
+	if r.TargetName == "" {
+		return errors.Errorf("targetName is required")
+	}
+	if err := names.Validate(r.TargetName); err != nil {
+		return errors.Wrap(err, "invalid targetName")
+	}
+	if r.TargetPortal != nil {
+		if err := validateRequestPortal(r.TargetPortal); err != nil {
+			return errors.Wrap(err, "invalid targetPortal")
+		}
+	}
+	if err := validateSecurityFlags(r.SecurityFlags); err != nil {
+		return err
+	}
+	return errors.Wrap(r.LoginOptions.Validate(), "invalid loginOptions")
+}
+
+// Validate checks that the request is well-formed.
+func (r *AddPortalRequest) Validate() error {
+	if err := checkUnsupportedOptions("add portal", r.unsupportedOptions); err != nil {
+		return err
+	}
+	if r.Portal == nil {
+		return errors.Errorf("portal is required")
+	}
+	if err := validateRequestPortal(r.Portal); err != nil {
+		return errors.Wrap(err, "invalid portal")
+	}
+	if err := validateSecurityFlags(r.SecurityFlags); err != nil {
+		return err
+	}
+	return errors.Wrap(r.LoginOptions.Validate(), "invalid loginOptions")
+}
+
+// Validate checks that the request is well-formed.
+func (r *AddConnectionRequest) Validate() error {
+	if err := checkUnsupportedOptions("add connection", r.unsupportedOptions); err != nil {
+		return err
+	}
+	if r.TargetPortal == nil {
+		return errors.Errorf("targetPortal is required")
+	}
+	if err := validateRequestPortal(r.TargetPortal); err != nil {
+		return errors.Wrap(err, "invalid targetPortal")
+	}
+	if err := validateSecurityFlags(r.SecurityFlags); err != nil {
+		return err
+	}
+	return errors.Wrap(r.LoginOptions.Validate(), "invalid loginOptions")
+}
+
+func checkUnsupportedOptions(requestType string, unsupportedOptions []string) error {
+	if len(unsupportedOptions) == 0 {
+		return nil
+	}
+	return errors.Errorf("unsupported option(s) for %s requests: %s", requestType, strings.Join(unsupportedOptions, ", "))
+}
+
+func validateSecurityFlags(securityFlags *SecurityFlags) error {
+	if securityFlags == nil {
+		return nil
+	}
+	return errors.Wrap(securityFlags.Validate(), "invalid securityFlags")
+}
+
+func validateRequestPortal(portal *Portal) error {
+	if portal.Address == "" {
+		return errors.Errorf("address is required")
+	}
+	return nil
+}
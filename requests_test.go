@@ -0,0 +1,187 @@
+package iscsidsc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequests(t *testing.T) {
+	portal := &Portal{Address: "10.0.0.1"}
+	loginOptions := &LoginOptions{LoginFlags: LoginFlagMultipathEnabled}
+
+	t.Run("NewLoginRequest applies all options", func(t *testing.T) {
+		request := NewLoginRequest("iqn.1991-05.com.microsoft:target",
+			WithInformationalSession(),
+			WithInitiatorInstance("ROOT\\ISCSIPRT\\0000_0"),
+			WithInitiatorPortNumber(12),
+			WithTargetPortal(portal),
+			WithSecurityFlags(SecurityFlagIkeIpsecEnabled),
+			WithLoginOptions(loginOptions),
+			WithKey(NewSecretFromString("secret")),
+			WithPersistence(),
+		)
+
+		require.Nil(t, request.Validate())
+		assert.Equal(t, "iqn.1991-05.com.microsoft:target", request.TargetName)
+		assert.True(t, request.IsInformationalSession)
+		assert.Equal(t, "ROOT\\ISCSIPRT\\0000_0", *request.InitiatorInstance)
+		assert.Equal(t, uint32(12), *request.InitiatorPortNumber)
+		assert.Equal(t, portal, request.TargetPortal)
+		assert.Equal(t, SecurityFlagIkeIpsecEnabled, *request.SecurityFlags)
+		assert.Equal(t, loginOptions, request.LoginOptions)
+		assert.Equal(t, NewSecretFromString("secret"), request.Key)
+		assert.True(t, request.IsPersistent)
+	})
+
+	t.Run("NewAddPortalRequest", func(t *testing.T) {
+		request := NewAddPortalRequest(portal, WithInitiatorPortNumber(12), WithLoginOptions(loginOptions))
+
+		require.Nil(t, request.Validate())
+		assert.Equal(t, portal, request.Portal)
+		assert.Equal(t, uint32(12), *request.InitiatorPortNumber)
+		assert.Equal(t, loginOptions, request.LoginOptions)
+		assert.Nil(t, request.InitiatorInstance)
+		assert.Nil(t, request.SecurityFlags)
+	})
+
+	t.Run("NewAddConnectionRequest", func(t *testing.T) {
+		sessionID := SessionID{AdapterUnique: 1, AdapterSpecific: 2}
+		request := NewAddConnectionRequest(sessionID, portal, WithKey(NewSecretFromString("secret")))
+
+		require.Nil(t, request.Validate())
+		assert.Equal(t, sessionID, request.SessionID)
+		assert.Equal(t, portal, request.TargetPortal)
+		assert.Equal(t, NewSecretFromString("secret"), request.Key)
+	})
+}
+
+func TestRequestsValidate(t *testing.T) {
+	portal := &Portal{Address: "10.0.0.1"}
+
+	for _, testCase := range []struct {
+		name          string
+		request       interface{ Validate() error }
+		expectedError string
+	}{
+		{
+			name:          "login request without a target name",
+			request:       NewLoginRequest(""),
+			expectedError: "targetName is required",
+		},
+		{
+			name:          "login request with a too long target name",
+			request:       NewLoginRequest("iqn.1991-05.com.microsoft:" + strings.Repeat("a", 200)),
+			expectedError: "invalid targetName: invalid iSCSI name \"iqn.1991-05.com.microsoft:" + strings.Repeat("a", 200) + "\": too long, cannot be more than 223 bytes",
+		},
+		{
+			name:          "login request with a malformed target name",
+			request:       NewLoginRequest("iqn.1991-05.com.microsoft target"),
+			expectedError: "invalid targetName: invalid iSCSI name \"iqn.1991-05.com.microsoft target\": character ' ' is not allowed",
+		},
+		{
+			name:          "login request with a portal without an address",
+			request:       NewLoginRequest("iqn.1991-05.com.microsoft:target", WithTargetPortal(&Portal{})),
+			expectedError: "invalid targetPortal: address is required",
+		},
+		{
+			name:          "add portal request without a portal",
+			request:       NewAddPortalRequest(nil),
+			expectedError: "portal is required",
+		},
+		{
+			name:          "add portal request with unsupported options",
+			request:       NewAddPortalRequest(portal, WithPersistence(), WithKey(NewSecretFromString("secret")), WithInitiatorPortNumber(1)),
+			expectedError: "unsupported option(s) for add portal requests: WithKey, WithPersistence",
+		},
+		{
+			name:          "add connection request without a portal",
+			request:       &AddConnectionRequest{},
+			expectedError: "targetPortal is required",
+		},
+		{
+			name:          "add connection request with unsupported options",
+			request:       NewAddConnectionRequest(SessionID{}, portal, WithTargetPortal(portal)),
+			expectedError: "unsupported option(s) for add connection requests: WithTargetPortal",
+		},
+		{
+			name:          "add connection request with invalid login options",
+			request:       NewAddConnectionRequest(SessionID{}, portal, WithLoginOptions(&LoginOptions{MaximumConnections: new(uint32)})),
+			expectedError: "invalid loginOptions: MaximumConnections is 0, must be between 1 and 65535",
+		},
+		{
+			name: "login request with mutually exclusive security flags",
+			request: NewLoginRequest("iqn.1991-05.com.microsoft:target",
+				WithSecurityFlags(SecurityFlagIkeIpsecEnabled|SecurityFlagTransportModePreferred|SecurityFlagTunnelModePreferred)),
+			expectedError: "invalid securityFlags: security flags transport-mode and tunnel-mode are mutually exclusive",
+		},
+		{
+			name:    "struct literals are valid too",
+			request: &LoginRequest{TargetName: "iqn.1991-05.com.microsoft:target"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.request.Validate()
+
+			if testCase.expectedError == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, testCase.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+// loginBackend records the arguments of the login calls it receives.
+type loginBackend struct {
+	Backend
+
+	requests []LoginRequest
+}
+
+func (b *loginBackend) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *Portal,
+	securityFlags *SecurityFlags, loginOptions *LoginOptions, key *Secret, isPersistent bool) (*SessionID, *ConnectionID, error) {
+	b.requests = append(b.requests, LoginRequest{
+		TargetName:             targetName,
+		IsInformationalSession: isInformationalSession,
+		InitiatorInstance:      initiatorInstance,
+		InitiatorPortNumber:    initiatorPortNumber,
+		TargetPortal:           targetPortal,
+		SecurityFlags:          securityFlags,
+		LoginOptions:           loginOptions,
+		Key:                    key,
+		IsPersistent:           isPersistent,
+	})
+	return &SessionID{AdapterUnique: 1}, &ConnectionID{AdapterUnique: 2}, nil
+}
+
+func TestClientLogin(t *testing.T) {
+	t.Run("it passes valid requests on to the backend", func(t *testing.T) {
+		backend := &loginBackend{}
+		client := NewClient(WithBackend(backend))
+
+		request := NewLoginRequest("iqn.1991-05.com.microsoft:target", WithInitiatorPortNumber(12), WithPersistence())
+		sessionID, connectionID, err := client.Login(request)
+
+		require.Nil(t, err)
+		assert.Equal(t, &SessionID{AdapterUnique: 1}, sessionID)
+		assert.Equal(t, &ConnectionID{AdapterUnique: 2}, connectionID)
+		assert.Equal(t, []LoginRequest{*request}, backend.requests)
+	})
+
+	t.Run("it rejects invalid requests without calling the backend", func(t *testing.T) {
+		backend := &loginBackend{}
+		client := NewClient(WithBackend(backend))
+
+		sessionID, connectionID, err := client.LoginIscsiTarget("", false, nil, nil, nil, nil, nil, nil, false)
+
+		assert.Nil(t, sessionID)
+		assert.Nil(t, connectionID)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "invalid login request: targetName is required", err.Error())
+		}
+		assert.Empty(t, backend.requests)
+	})
+}
@@ -0,0 +1,82 @@
+package retry
+
+import (
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// Class describes whether an operation that failed with a given error can be retried.
+type Class int
+
+const (
+	// Permanent errors won't go away by retrying the same operation.
+	Permanent Class = iota
+	// Transient errors might go away by retrying the same operation later.
+	Transient
+	// Ambiguous errors leave the outcome of the operation unknown: it might or might not
+	// have taken effect; the state of the system needs to be checked before retrying.
+	Ambiguous
+)
+
+var transientErrorCodes = map[iscsidsc.ErrorCode]bool{
+	iscsidsc.ErrConnectionFailed:           true,
+	iscsidsc.ErrSessionBusy:                true,
+	iscsidsc.ErrSendFailed:                 true,
+	iscsidsc.ErrTransportError:             true,
+	iscsidsc.ErrTargetOutOfResources:       true,
+	iscsidsc.ErrISNSServerNotFound:         true,
+	iscsidsc.ErrServiceNotRunning:          true,
+	iscsidsc.ErrDeviceBusyOnSession:        true,
+	iscsidsc.ErrDNSNameUnresolved:          true,
+	iscsidsc.ErrNoConnectionAvailable:      true,
+	iscsidsc.ErrRemoveConnectionInProgress: true,
+}
+
+var ambiguousErrorCodes = map[iscsidsc.ErrorCode]bool{
+	iscsidsc.ErrNonSpecific:            true,
+	iscsidsc.ErrLoginFailed:            true,
+	iscsidsc.ErrDriverBug:              true,
+	iscsidsc.ErrServiceDidNotRespond:   true,
+	iscsidsc.ErrPersistentLoginTimeout: true,
+	iscsidsc.ErrSemTimeout:             true,
+	iscsidsc.ErrTimeout:                true,
+	iscsidsc.ErrRPCServerUnavailable:   true,
+}
+
+// Classify returns the retry class of an error returned by this library.
+// Only errors caused by `*iscsidsc.WinAPICallError`s can be transient or ambiguous; any other error, e.g. when
+// validating arguments, is permanent.
+// Errors resulting from a target rejecting a login are classified according to their
+// login status, see `iscsidsc.LoginStatus.Retryable`.
+func Classify(err error) Class {
+	code, ok := iscsidsc.ErrorCodeOf(err)
+	if !ok {
+		return Permanent
+	}
+
+	if status, isLoginStatus := code.LoginStatus(); isLoginStatus {
+		if status.Retryable() {
+			return Transient
+		}
+		return Permanent
+	}
+	if transientErrorCodes[code] {
+		return Transient
+	}
+	if ambiguousErrorCodes[code] {
+		return Ambiguous
+	}
+	return Permanent
+}
+
+func (class Class) String() string {
+	switch class {
+	case Permanent:
+		return "permanent"
+	case Transient:
+		return "transient"
+	case Ambiguous:
+		return "ambiguous"
+	default:
+		return "unknown"
+	}
+}
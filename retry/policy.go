@@ -0,0 +1,117 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// Policy configures how operations are retried.
+type Policy struct {
+	// MaxAttempts is the maximum number of times an operation is attempted, including the
+	// first attempt. Values lower than 1 are treated as 1.
+	MaxAttempts int
+	// InitialBackoff is how long to wait before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps how long to wait between two attempts; 0 means no cap.
+	MaxBackoff time.Duration
+	// Multiplier is applied to the backoff after each retry; values lower than 1 are treated as 1.
+	Multiplier float64
+	// Jitter randomizes each backoff by up to that fraction of its value, e.g. 0.1 for +/- 10%.
+	Jitter float64
+
+	// Client performs the operations; defaults to the default client, see `iscsidsc.DefaultClient`.
+	Client *iscsidsc.Client
+	// Clock waits between attempts; defaults to `iscsidsc.SystemClock`.
+	Clock iscsidsc.Clock
+}
+
+// DefaultPolicy tries operations up to 3 times, waiting 1 then 2 seconds between attempts.
+var DefaultPolicy = Policy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.1,
+}
+
+// backoff returns how long to wait after the given attempt (starting at 1) failed.
+func (p *Policy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(backoff)
+}
+
+func (p *Policy) client() *iscsidsc.Client {
+	if p.Client == nil {
+		return iscsidsc.DefaultClient()
+	}
+	return p.Client
+}
+
+func (p *Policy) sleep(d time.Duration) {
+	clock := p.Clock
+	if clock == nil {
+		clock = iscsidsc.SystemClock{}
+	}
+	<-clock.After(d)
+}
+
+// do runs op until it succeeds, fails with a permanent error, or the policy's attempts are exhausted;
+// it then returns the last error as-is.
+// After each failure other than a permanent one, if checkDone is not nil, it is called to check whether
+// the attempt actually took effect despite the error, in which case do returns nil without retrying;
+// ambiguous errors are only retried when there is such a check. That check is also made after
+// ambiguous failures of the last attempt, so that an attempt that did succeed isn't reported as failed.
+func (p *Policy) do(op func() error, checkDone func() (bool, error)) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		class := Classify(err)
+		if class == Permanent || class == Ambiguous && checkDone == nil {
+			return err
+		}
+		lastAttempt := attempt >= maxAttempts
+		if lastAttempt && class == Transient {
+			return err
+		}
+
+		p.sleep(p.backoff(attempt))
+
+		if checkDone != nil {
+			if done, checkErr := checkDone(); checkErr == nil && done {
+				return nil
+			}
+		}
+		if lastAttempt {
+			return err
+		}
+	}
+}
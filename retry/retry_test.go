@@ -0,0 +1,334 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected Class
+	}{
+		{"non Windows API error", errors.New("invalid argument"), Permanent},
+		{"not supported", iscsidsc.ErrNotSupported, Permanent},
+		{"connection failure", iscsidsc.NewWinAPICallError("AddIScsiSendTargetPortalW", 0xEFFF0003), Transient},
+		{"authentication failure", iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0xEFFF0009), Permanent},
+		{"target out of resources", iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0xEFFF0014), Transient},
+		{"target already logged in", iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0xEFFF003F), Permanent},
+		{"timeout", iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0x000005B4), Ambiguous},
+		{"wrapped timeout", errors.Wrap(iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0x000005B4), "custom backend"), Ambiguous},
+		{"unknown code", iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0xEFFFFFFF), Permanent},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, Classify(testCase.err))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &Policy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	assert.Equal(t, 5*time.Second, policy.backoff(4))
+	assert.Equal(t, 5*time.Second, policy.backoff(100))
+
+	t.Run("with jitter", func(t *testing.T) {
+		policy.Jitter = 0.1
+
+		for i := 0; i < 100; i++ {
+			backoff := policy.backoff(1)
+			assert.True(t, 900*time.Millisecond <= backoff && backoff <= 1100*time.Millisecond, "unexpected backoff %v", backoff)
+		}
+	})
+}
+
+var (
+	transientErr = iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0xEFFF0003)
+	ambiguousErr = iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0x000005B4)
+	permanentErr = iscsidsc.NewWinAPICallError("LoginIScsiTargetW", 0xEFFF0009)
+)
+
+func TestDo(t *testing.T) {
+	newPolicy := func(maxAttempts int) (*Policy, *[]time.Duration) {
+		clock := &fakeClock{}
+		return &Policy{
+			MaxAttempts:    maxAttempts,
+			InitialBackoff: time.Second,
+			Multiplier:     2,
+			Clock:          clock,
+		}, &clock.waits
+	}
+
+	t.Run("it retries transient errors until it succeeds", func(t *testing.T) {
+		policy, sleeps := newPolicy(3)
+		errs := []error{transientErr, transientErr, nil}
+
+		err := policy.do(scriptedOp(&errs), nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(errs))
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *sleeps)
+	})
+
+	t.Run("it gives up after the max number of attempts", func(t *testing.T) {
+		policy, _ := newPolicy(3)
+		errs := []error{transientErr, transientErr, transientErr, nil}
+
+		err := policy.do(scriptedOp(&errs), nil)
+
+		assert.Equal(t, transientErr, err)
+		assert.Equal(t, 1, len(errs))
+	})
+
+	t.Run("it doesn't retry permanent errors", func(t *testing.T) {
+		policy, sleeps := newPolicy(3)
+		errs := []error{permanentErr, nil}
+
+		err := policy.do(scriptedOp(&errs), func() (bool, error) { return true, nil })
+
+		assert.Equal(t, permanentErr, err)
+		assert.Equal(t, 0, len(*sleeps))
+	})
+
+	t.Run("it doesn't retry ambiguous errors when it can't check the state", func(t *testing.T) {
+		policy, _ := newPolicy(3)
+		errs := []error{ambiguousErr, nil}
+
+		err := policy.do(scriptedOp(&errs), nil)
+
+		assert.Equal(t, ambiguousErr, err)
+	})
+
+	t.Run("it stops retrying if the previous attempt actually succeeded", func(t *testing.T) {
+		policy, _ := newPolicy(3)
+		errs := []error{ambiguousErr, nil}
+
+		err := policy.do(scriptedOp(&errs), func() (bool, error) { return true, nil })
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(errs))
+	})
+
+	t.Run("it checks whether the last attempt actually succeeded after an ambiguous error", func(t *testing.T) {
+		policy, _ := newPolicy(3)
+		errs := []error{ambiguousErr, ambiguousErr, ambiguousErr}
+		checks := 0
+
+		err := policy.do(scriptedOp(&errs), func() (bool, error) {
+			checks++
+			return checks == 3, nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(errs))
+		assert.Equal(t, 3, checks)
+	})
+
+	t.Run("it returns the last ambiguous error if the last attempt didn't succeed", func(t *testing.T) {
+		policy, _ := newPolicy(1)
+		errs := []error{ambiguousErr}
+
+		err := policy.do(scriptedOp(&errs), func() (bool, error) { return false, nil })
+
+		assert.Equal(t, ambiguousErr, err)
+	})
+
+	t.Run("it doesn't check the state after the last transient error", func(t *testing.T) {
+		policy, _ := newPolicy(1)
+		errs := []error{transientErr}
+
+		err := policy.do(scriptedOp(&errs), func() (bool, error) {
+			t.Fatal("should not have checked the state")
+			return false, nil
+		})
+
+		assert.Equal(t, transientErr, err)
+	})
+}
+
+// fakeBackend serves scripted session lists, and performs logins and logouts as told.
+type fakeBackend struct {
+	iscsidsc.Backend
+
+	// returned in order by GetIScsiSessionList, which then keeps returning the last one
+	sessionLists [][]iscsidsc.SessionInfo
+	login        func(attempt int) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error)
+	logout       func(attempt int) error
+
+	loginCalls, logoutCalls int
+}
+
+func (b *fakeBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	sessions := b.sessionLists[0]
+	if len(b.sessionLists) > 1 {
+		b.sessionLists = b.sessionLists[1:]
+	}
+	return sessions, nil
+}
+
+func (b *fakeBackend) LoginIscsiTarget(string, bool, *string, *uint32, *iscsidsc.Portal, *iscsidsc.SecurityFlags, *iscsidsc.LoginOptions,
+	*iscsidsc.Secret, bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	b.loginCalls++
+	return b.login(b.loginCalls)
+}
+
+func (b *fakeBackend) LogoutIScsiTarget(iscsidsc.SessionID) error {
+	b.logoutCalls++
+	return b.logout(b.logoutCalls)
+}
+
+// newTestPolicy returns a copy of policy that uses backend, and doesn't sleep.
+func newTestPolicy(policy Policy, backend *fakeBackend) *Policy {
+	policy.Client = iscsidsc.NewClient(iscsidsc.WithBackend(backend))
+	policy.Clock = &fakeClock{}
+	return &policy
+}
+
+// fakeClock records how long it's asked to wait, and returns right away; its time never moves.
+type fakeClock struct {
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return time.Time{}
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+func TestLoginIscsiTarget(t *testing.T) {
+	targetName := "iqn.1991-05.com.microsoft:target-1"
+	existingSession := iscsidsc.SessionInfo{
+		SessionID:      iscsidsc.SessionID{AdapterUnique: 1, AdapterSpecific: 1},
+		TargetNodeName: targetName,
+		Connections: []iscsidsc.ConnectionInfo{
+			{ConnectionID: iscsidsc.ConnectionID{AdapterUnique: 1, AdapterSpecific: 2}, TargetAddress: "10.0.0.5", TargetSocket: 3260},
+		},
+	}
+	newSession := iscsidsc.SessionInfo{
+		SessionID:      iscsidsc.SessionID{AdapterUnique: 1, AdapterSpecific: 3},
+		TargetNodeName: targetName,
+		Connections: []iscsidsc.ConnectionInfo{
+			{ConnectionID: iscsidsc.ConnectionID{AdapterUnique: 1, AdapterSpecific: 4}, TargetAddress: "10.0.0.5", TargetSocket: 3260},
+		},
+	}
+	portal := &iscsidsc.Portal{Address: "10.0.0.5"}
+	alwaysTimesOut := func(int) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+		return nil, nil, ambiguousErr
+	}
+
+	t.Run("when an attempt that timed out actually created a session, it returns it instead of logging in again", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessionLists: [][]iscsidsc.SessionInfo{{existingSession}, {existingSession, newSession}},
+			login:        alwaysTimesOut,
+		}
+
+		sessionID, connectionID, err := newTestPolicy(DefaultPolicy, backend).LoginIscsiTarget(targetName, false, nil, nil, portal, nil, nil, nil, false)
+
+		require.Nil(t, err)
+		assert.Equal(t, 1, backend.loginCalls)
+		assert.Equal(t, newSession.SessionID, *sessionID)
+		assert.Equal(t, newSession.Connections[0].ConnectionID, *connectionID)
+	})
+
+	t.Run("when the last attempt timed out but created a session, it returns it", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessionLists: [][]iscsidsc.SessionInfo{{existingSession}, {existingSession, newSession}},
+			login:        alwaysTimesOut,
+		}
+
+		sessionID, connectionID, err := newTestPolicy(Policy{MaxAttempts: 1}, backend).LoginIscsiTarget(targetName, false, nil, nil, portal, nil, nil, nil, false)
+
+		require.Nil(t, err)
+		assert.Equal(t, 1, backend.loginCalls)
+		assert.Equal(t, newSession.SessionID, *sessionID)
+		assert.Equal(t, newSession.Connections[0].ConnectionID, *connectionID)
+	})
+
+	t.Run("when the last attempt timed out without creating a session, it returns the error", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessionLists: [][]iscsidsc.SessionInfo{{existingSession}},
+			login:        alwaysTimesOut,
+		}
+
+		sessionID, _, err := newTestPolicy(DefaultPolicy, backend).LoginIscsiTarget(targetName, false, nil, nil, portal, nil, nil, nil, false)
+
+		assert.Equal(t, ambiguousErr, err)
+		assert.Nil(t, sessionID)
+		assert.Equal(t, DefaultPolicy.MaxAttempts, backend.loginCalls)
+	})
+
+	t.Run("target names are compared once normalized", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessionLists: [][]iscsidsc.SessionInfo{{existingSession}, {existingSession, newSession}},
+			login:        alwaysTimesOut,
+		}
+
+		sessionID, _, err := newTestPolicy(DefaultPolicy, backend).LoginIscsiTarget("IQN.1991-05.com.microsoft:Target-1", false, nil, nil, portal, nil, nil, nil, false)
+
+		require.Nil(t, err)
+		assert.Equal(t, 1, backend.loginCalls)
+		assert.Equal(t, newSession.SessionID, *sessionID)
+	})
+
+	t.Run("when no new session appeared, it logs in again", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessionLists: [][]iscsidsc.SessionInfo{{existingSession}, {existingSession}},
+			login: func(attempt int) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+				if attempt == 1 {
+					return nil, nil, transientErr
+				}
+				return &newSession.SessionID, &newSession.Connections[0].ConnectionID, nil
+			},
+		}
+
+		sessionID, _, err := newTestPolicy(DefaultPolicy, backend).LoginIscsiTarget(targetName, false, nil, nil, portal, nil, nil, nil, false)
+
+		require.Nil(t, err)
+		assert.Equal(t, 2, backend.loginCalls)
+		assert.Equal(t, newSession.SessionID, *sessionID)
+	})
+}
+
+func TestLogoutIScsiTarget(t *testing.T) {
+	sessionInfo := iscsidsc.SessionInfo{SessionID: iscsidsc.SessionID{AdapterUnique: 1, AdapterSpecific: 1}}
+	backend := &fakeBackend{
+		sessionLists: [][]iscsidsc.SessionInfo{{}},
+		logout: func(int) error {
+			return iscsidsc.NewWinAPICallError("LogoutIScsiTarget", 0xEFFF0040)
+		},
+	}
+
+	err := newTestPolicy(DefaultPolicy, backend).LogoutIScsiTarget(sessionInfo.SessionID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, backend.logoutCalls)
+}
+
+// scriptedOp returns an operation that returns the given errors in order.
+func scriptedOp(errs *[]error) func() error {
+	return func() error {
+		err := (*errs)[0]
+		*errs = (*errs)[1:]
+		return err
+	}
+}
@@ -0,0 +1,182 @@
+package retry
+
+import (
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// This file contains retrying wrappers around this library's mutating calls.
+// Each of them checks the system's state before retrying, and after an ambiguous failure of the last
+// attempt, so that an attempt that actually succeeded despite returning an error does not get repeated,
+// nor reported as failed.
+
+// LoginIscsiTarget calls `iscsidsc.Client.LoginIscsiTarget`, retrying according to the policy.
+// Before retrying, it looks for a session to the target that didn't exist before the first
+// attempt, and returns it if there is one, rather than logging in again.
+func (p *Policy) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	client := p.client()
+
+	var (
+		sessionID    *iscsidsc.SessionID
+		connectionID *iscsidsc.ConnectionID
+		checkDone    func() (bool, error)
+	)
+
+	// if we can't list the existing sessions, we can't tell new sessions apart from existing ones,
+	// and then we only retry on transient errors
+	if existingSessions, err := client.GetIScsiSessionList(); err == nil {
+		existingIDs := make(map[iscsidsc.SessionID]bool)
+		for _, existingSession := range existingSessions {
+			existingIDs[existingSession.SessionID] = true
+		}
+
+		checkDone = func() (bool, error) {
+			sessions, err := client.GetIScsiSessionList()
+			if err != nil {
+				return false, err
+			}
+
+			for i := range sessions {
+				sessionInfo := &sessions[i]
+				if existingIDs[sessionInfo.SessionID] || !sessionInfo.IsForTarget(targetName) {
+					continue
+				}
+
+				connection := findConnection(sessionInfo.Connections, targetPortal, nil)
+				if connection == nil && (targetPortal != nil || len(sessionInfo.Connections) != 0) {
+					continue
+				}
+
+				sessionID = &sessionInfo.SessionID
+				if connection != nil {
+					connectionID = &connection.ConnectionID
+				}
+				return true, nil
+			}
+			return false, nil
+		}
+	}
+
+	err := p.do(func() (err error) {
+		sessionID, connectionID, err = client.LoginIscsiTarget(targetName, isInformationalSession, initiatorInstance, initiatorPortNumber, targetPortal,
+			securityFlags, loginOptions, key, isPersistent)
+		return
+	}, checkDone)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sessionID, connectionID, nil
+}
+
+// LogoutIScsiTarget calls `iscsidsc.Client.LogoutIScsiTarget`, retrying according to the policy.
+// Before retrying, it checks whether the session still exists.
+func (p *Policy) LogoutIScsiTarget(sessionID iscsidsc.SessionID) error {
+	client := p.client()
+
+	return p.do(func() error {
+		return client.LogoutIScsiTarget(sessionID)
+	}, func() (bool, error) {
+		sessions, err := client.GetIScsiSessionList()
+		if err != nil {
+			return false, err
+		}
+		return findSession(sessions, sessionID) == nil, nil
+	})
+}
+
+// AddIScsiSendTargetPortal calls `iscsidsc.Client.AddIScsiSendTargetPortal`, retrying according to the policy.
+// Before retrying, it checks whether the portal has been added.
+func (p *Policy) AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions, securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) error {
+	client := p.client()
+
+	return p.do(func() error {
+		return client.AddIScsiSendTargetPortal(initiatorInstance, initiatorPortNumber, loginOptions, securityFlags, portal)
+	}, func() (bool, error) {
+		if portal == nil {
+			return false, nil
+		}
+
+		portalInfos, err := client.ReportIScsiSendTargetPortals()
+		if err != nil {
+			return false, err
+		}
+		for _, portalInfo := range portalInfos {
+			if portal.Equal(&portalInfo.Portal) {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// AddIScsiConnection calls `iscsidsc.Client.AddIScsiConnection`, retrying according to the policy.
+// Before retrying, it looks for a connection to the target portal that didn't exist on the
+// session before the first attempt, and returns it if there is one, rather than adding another one.
+func (p *Policy) AddIScsiConnection(id iscsidsc.SessionID, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret) (*iscsidsc.ConnectionID, error) {
+	client := p.client()
+
+	var (
+		connectionID *iscsidsc.ConnectionID
+		checkDone    func() (bool, error)
+	)
+
+	if existingSessions, err := client.GetIScsiSessionList(); err == nil {
+		existingIDs := make(map[iscsidsc.ConnectionID]bool)
+		if sessionInfo := findSession(existingSessions, id); sessionInfo != nil {
+			for _, connection := range sessionInfo.Connections {
+				existingIDs[connection.ConnectionID] = true
+			}
+		}
+
+		checkDone = func() (bool, error) {
+			sessions, err := client.GetIScsiSessionList()
+			if err != nil {
+				return false, err
+			}
+
+			sessionInfo := findSession(sessions, id)
+			if sessionInfo == nil {
+				return false, nil
+			}
+			if connection := findConnection(sessionInfo.Connections, targetPortal, existingIDs); connection != nil {
+				connectionID = &connection.ConnectionID
+				return true, nil
+			}
+			return false, nil
+		}
+	}
+
+	err := p.do(func() (err error) {
+		connectionID, err = client.AddIScsiConnection(id, initiatorPortNumber, targetPortal, securityFlags, loginOptions, key)
+		return
+	}, checkDone)
+	if err != nil {
+		return nil, err
+	}
+	return connectionID, nil
+}
+
+func findSession(sessions []iscsidsc.SessionInfo, id iscsidsc.SessionID) *iscsidsc.SessionInfo {
+	for i := range sessions {
+		if sessions[i].SessionID == id {
+			return &sessions[i]
+		}
+	}
+	return nil
+}
+
+// findConnection returns the first connection to the given portal that's not in excludedIDs;
+// if portal is nil, any connection matches.
+func findConnection(connections []iscsidsc.ConnectionInfo, portal *iscsidsc.Portal, excludedIDs map[iscsidsc.ConnectionID]bool) *iscsidsc.ConnectionInfo {
+	for i := range connections {
+		connection := &connections[i]
+		if excludedIDs[connection.ConnectionID] {
+			continue
+		}
+		if portal == nil || portal.Equal(connection.TargetPortal()) {
+			return connection
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package iscsidsc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// redacted is what secrets show as in any formatted output.
+const redacted = "[REDACTED]"
+
+// Secret holds a sensitive value, such as a CHAP username or password, or an IPsec pre-shared key.
+// Secrets redact themselves when formatted with the `fmt` package, or marshalled to JSON, so that they
+// can't leak into logs by mistake.
+// A secret's value is passed to Windows' API according to its encoding, see `CredentialEncoding`.
+type Secret struct {
+	value    []byte
+	encoding CredentialEncoding
+}
+
+// NewSecret builds a new UTF-8 secret with a copy of value.
+func NewSecret(value []byte) *Secret {
+	s := &Secret{value: make([]byte, len(value))}
+	copy(s.value, value)
+	return s
+}
+
+// NewSecretFromString builds a new UTF-8 secret from value.
+// Note that go strings are immutable, so value itself can't be wiped; prefer `NewSecret`,
+// `SecretFromFile` or `SecretFromEnv` when possible.
+func NewSecretFromString(value string) *Secret {
+	return &Secret{value: []byte(value)}
+}
+
+// SecretFromFile builds a new UTF-8 secret from the contents of the file at path.
+// A single trailing newline, if any, is trimmed.
+func SecretFromFile(path string) (*Secret, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read secret from %s", path)
+	}
+
+	if n := len(contents); n != 0 && contents[n-1] == '\n' {
+		contents = contents[:n-1]
+		if n := len(contents); n != 0 && contents[n-1] == '\r' {
+			contents = contents[:n-1]
+		}
+	}
+
+	return &Secret{value: contents}, nil
+}
+
+// SecretFromEnv builds a new UTF-8 secret from the value of the environment variable name,
+// and errors out if that variable isn't set.
+func SecretFromEnv(name string) (*Secret, error) {
+	value, present := os.LookupEnv(name)
+	if !present {
+		return nil, errors.Errorf("environment variable %s is not set", name)
+	}
+	return NewSecretFromString(value), nil
+}
+
+// Bytes returns the secret's value, before encoding. The returned slice is shared with the secret, and zeroed by `Wipe`.
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.value
+}
+
+// Len returns the length of the secret's value before encoding, in bytes.
+func (s *Secret) Len() int {
+	return len(s.Bytes())
+}
+
+// Wipe zeroes the secret's value in memory.
+func (s *Secret) Wipe() {
+	if s == nil {
+		return
+	}
+	wipeBytes(s.value)
+}
+
+// The redacting methods below have value receivers, so that both secrets and pointers to secrets redact
+// themselves, including when held by value in other structs; fmt prints nil pointers as "<nil>".
+
+// String implements `fmt.Stringer`, and redacts the secret.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements `fmt.GoStringer`, and redacts the secret.
+func (s Secret) GoString() string {
+	return "iscsidsc.Secret(" + redacted + ")"
+}
+
+// Format implements `fmt.Formatter`, so that the secret is redacted with any verb.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		fmt.Fprint(f, s.GoString())
+	} else {
+		fmt.Fprint(f, s.String())
+	}
+}
+
+// MarshalJSON implements `json.Marshaler`, and redacts the secret.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
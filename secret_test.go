@@ -0,0 +1,148 @@
+package iscsidsc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	secret := NewSecretFromString("hunter2")
+
+	for _, format := range []string{"%v", "%+v", "%s", "%q", "%x", "%d"} {
+		assert.Equal(t, "[REDACTED]", fmt.Sprintf(format, secret), format)
+	}
+	assert.Equal(t, "iscsidsc.Secret([REDACTED])", fmt.Sprintf("%#v", secret))
+	assert.Equal(t, "[REDACTED]", secret.String())
+
+	t.Run("in login options", func(t *testing.T) {
+		opts := &LoginOptions{Username: NewSecretFromString("alice"), Password: secret}
+
+		for _, format := range []string{"%v", "%+v", "%#v"} {
+			formatted := fmt.Sprintf(format, opts)
+			assert.NotContains(t, formatted, "alice", format)
+			assert.NotContains(t, formatted, "hunter2", format)
+			assert.Contains(t, formatted, "[REDACTED]", format)
+		}
+
+		jsonBytes, err := json.Marshal(opts)
+		require.Nil(t, err)
+		assert.Contains(t, string(jsonBytes), `"Username":"[REDACTED]","Password":"[REDACTED]"`)
+	})
+
+	t.Run("secret values", func(t *testing.T) {
+		for _, format := range []string{"%v", "%+v", "%s", "%q", "%x", "%d"} {
+			assert.Equal(t, "[REDACTED]", fmt.Sprintf(format, *secret), format)
+		}
+		assert.Equal(t, "iscsidsc.Secret([REDACTED])", fmt.Sprintf("%#v", *secret))
+
+		jsonBytes, err := json.Marshal(*secret)
+		require.Nil(t, err)
+		assert.Equal(t, `"[REDACTED]"`, string(jsonBytes))
+	})
+
+	t.Run("in structs holding secrets by value", func(t *testing.T) {
+		holder := struct {
+			Name  string
+			Value Secret
+		}{Name: "holder", Value: *secret}
+
+		for _, format := range []string{"%v", "%+v", "%#v"} {
+			for _, formatted := range []string{fmt.Sprintf(format, holder), fmt.Sprintf(format, &holder)} {
+				assert.NotContains(t, formatted, "hunter2", format)
+				assert.NotContains(t, formatted, fmt.Sprint([]byte("hunter2")), format)
+				assert.Contains(t, formatted, "[REDACTED]", format)
+			}
+		}
+
+		jsonBytes, err := json.Marshal(holder)
+		require.Nil(t, err)
+		assert.Equal(t, `{"Name":"holder","Value":"[REDACTED]"}`, string(jsonBytes))
+	})
+
+	t.Run("nil secrets", func(t *testing.T) {
+		var nilSecret *Secret
+		assert.Equal(t, "<nil>", fmt.Sprintf("%v", nilSecret))
+		assert.Equal(t, "<nil>", fmt.Sprintf("%#v", nilSecret))
+
+		jsonBytes, err := json.Marshal(nilSecret)
+		require.Nil(t, err)
+		assert.Equal(t, "null", string(jsonBytes))
+
+		assert.Equal(t, 0, nilSecret.Len())
+		assert.Nil(t, nilSecret.Bytes())
+		nilSecret.Wipe()
+	})
+}
+
+func TestNewSecret(t *testing.T) {
+	value := []byte("hunter2")
+	secret := NewSecret(value)
+
+	value[0] = 'H'
+	assert.Equal(t, []byte("hunter2"), secret.Bytes(), "should have copied the value")
+	assert.Equal(t, 7, secret.Len())
+
+	secret.Wipe()
+	assert.Equal(t, make([]byte, 7), secret.Bytes())
+}
+
+func TestSecretFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iscsidsc-secret-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for contents, expected := range map[string]string{
+		"hunter2":       "hunter2",
+		"hunter2\n":     "hunter2",
+		"hunter2\r\n":   "hunter2",
+		"hunter2\n\n":   "hunter2\n",
+		"hunt\ner2 \n":  "hunt\ner2 ",
+		"":              "",
+		"\x00binary\n":  "\x00binary",
+		"\r\n":          "",
+		"no newline\r":  "no newline\r",
+		"with spaces  ": "with spaces  ",
+	} {
+		path := filepath.Join(dir, "secret")
+		require.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600))
+
+		secret, err := SecretFromFile(path)
+		require.Nil(t, err)
+		assert.Equal(t, expected, string(secret.Bytes()), "contents: %q", contents)
+	}
+
+	_, err = SecretFromFile(filepath.Join(dir, "does-not-exist"))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unable to read secret from ")
+	}
+}
+
+func TestSecretFromEnv(t *testing.T) {
+	const name = "ISCSIDSC_TEST_SECRET"
+	previous, wasSet := os.LookupEnv(name)
+	defer func() {
+		if wasSet {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	}()
+
+	require.Nil(t, os.Setenv(name, "hunter2"))
+	secret, err := SecretFromEnv(name)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("hunter2"), secret.Bytes())
+
+	require.Nil(t, os.Unsetenv(name))
+	_, err = SecretFromEnv(name)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "environment variable ISCSIDSC_TEST_SECRET is not set", err.Error())
+	}
+}
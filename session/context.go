@@ -0,0 +1,29 @@
+package session
+
+import (
+	"context"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// This file contains the context-aware variants of this package's API.
+// They return ctx's error as soon as ctx is done, while the underlying call to Windows' API
+// finishes in the background; its outcome is then reported to ctx's `iscsidsc.OrphanHandler`, if any.
+// They use the default client, see `iscsidsc.DefaultClient`.
+
+// AddIScsiConnectionContext is the same as `AddIScsiConnection`, but it stops waiting when ctx is done.
+// Connections created after that are reported to ctx's orphan handler.
+func AddIScsiConnectionContext(ctx context.Context, id iscsidsc.SessionID, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret) (*iscsidsc.ConnectionID, error) {
+	return iscsidsc.DefaultClient().AddIScsiConnectionContext(ctx, id, initiatorPortNumber, targetPortal, securityFlags, loginOptions, key)
+}
+
+// GetDevicesForIScsiSessionContext is the same as `GetDevicesForIScsiSession`, but it stops waiting when ctx is done.
+func GetDevicesForIScsiSessionContext(ctx context.Context, id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	return iscsidsc.DefaultClient().GetDevicesForIScsiSessionContext(ctx, id)
+}
+
+// GetIScsiSessionListContext is the same as `GetIScsiSessionList`, but it stops waiting when ctx is done.
+func GetIScsiSessionListContext(ctx context.Context) ([]iscsidsc.SessionInfo, error) {
+	return iscsidsc.DefaultClient().GetIScsiSessionListContext(ctx)
+}
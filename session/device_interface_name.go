@@ -0,0 +1,105 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// DeviceInterfaceName is a decoded device interface name, as found in `iscsidsc.Device`s, e.g.
+// `\\?\scsi#disk&ven_msft&prod_virtual_hd#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`.
+// Its format is `\\?\<bus>#<device ID>#<instance path>#{<interface class GUID>}`, optionally followed by
+// a backslash and a reference string.
+// Fields are kept in the case Windows reported them in, and hardware IDs use underscores in place of spaces,
+// e.g. "Virtual_HD".
+type DeviceInterfaceName struct {
+	// the enumerator, e.g. "SCSI", "MPIO" or "USBSTOR"
+	Bus string
+	// the first component of the device ID, e.g. "Disk" or "CdRom"
+	DeviceClass string
+	// the vendor, product and revision are only set for buses that use `&ven_`, `&prod_` and `&rev_`
+	// components in their device IDs, which all storage buses do, with the notable exception of IDE
+	Vendor   string
+	Product  string
+	Revision string
+	// uniquely identifies the device on its bus, e.g. "1&1c121344&0&000000"
+	InstancePath string
+	// e.g. `iscsidsc.DeviceInterfaceDisk`
+	InterfaceClass uuid.UUID
+	// mostly empty
+	ReferenceString string
+}
+
+// the prefixes device interface names can start with
+var deviceInterfaceNamePrefixes = []string{`\\?\`, `\??\`, `\\.\`}
+
+// ParseDeviceInterfaceName decodes a device interface name, see `DeviceInterfaceName`.
+func ParseDeviceInterfaceName(name string) (*DeviceInterfaceName, error) {
+	invalidName := func(format string, args ...interface{}) error {
+		return errors.Errorf("invalid device interface name %q: "+format, append([]interface{}{name}, args...)...)
+	}
+
+	rest := ""
+	for _, prefix := range deviceInterfaceNamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			rest = name[len(prefix):]
+			break
+		}
+	}
+	if rest == "" {
+		return nil, invalidName(`expected to start with \\?\`)
+	}
+
+	// the interface class comes last, possibly followed by a reference string
+	lastHashIndex := strings.LastIndexByte(rest, '#')
+	if lastHashIndex == -1 {
+		return nil, invalidName("missing interface class")
+	}
+	classPart := rest[lastHashIndex+1:]
+	result := &DeviceInterfaceName{}
+	if backslashIndex := strings.IndexByte(classPart, '\\'); backslashIndex != -1 {
+		result.ReferenceString = classPart[backslashIndex+1:]
+		classPart = classPart[:backslashIndex]
+	}
+	if !strings.HasPrefix(classPart, "{") || !strings.HasSuffix(classPart, "}") {
+		return nil, invalidName("interface class %q is not enclosed in curly braces", classPart)
+	}
+	interfaceClass, err := uuid.Parse(classPart[1 : len(classPart)-1])
+	if err != nil {
+		return nil, invalidName("invalid interface class %q: %v", classPart, err)
+	}
+	result.InterfaceClass = interfaceClass
+
+	// instance paths shouldn't contain any hash, but let's be lenient and keep them as is if they do
+	parts := strings.SplitN(rest[:lastHashIndex], "#", 3)
+	if len(parts) != 3 {
+		return nil, invalidName("expected a bus, a device ID and an instance path")
+	}
+	result.Bus = parts[0]
+	result.InstancePath = parts[2]
+	if result.Bus == "" {
+		return nil, invalidName("empty bus")
+	}
+	if parts[1] == "" {
+		return nil, invalidName("empty device ID")
+	}
+	if result.InstancePath == "" {
+		return nil, invalidName("empty instance path")
+	}
+
+	for i, component := range strings.Split(parts[1], "&") {
+		switch lower := strings.ToLower(component); {
+		case strings.HasPrefix(lower, "ven_"):
+			result.Vendor = component[len("ven_"):]
+		case strings.HasPrefix(lower, "prod_"):
+			result.Product = component[len("prod_"):]
+		case strings.HasPrefix(lower, "rev_"):
+			result.Revision = component[len("rev_"):]
+		case i == 0:
+			result.DeviceClass = component
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestParseDeviceInterfaceName(t *testing.T) {
+	// all of these have been seen in the wild
+	for _, testCase := range []struct {
+		name     string
+		expected DeviceInterfaceName
+	}{
+		{
+			// Windows Server's iSCSI target
+			name: `\\?\scsi#disk&ven_msft&prod_virtual_hd#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "scsi",
+				DeviceClass:    "disk",
+				Vendor:         "msft",
+				Product:        "virtual_hd",
+				InstancePath:   "1&1c121344&0&000000",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			// Linux' LIO target
+			name: `\\?\SCSI#Disk&Ven_LIO-ORG&Prod_disk01&Rev_4.0#1&1c121344&0&000001#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "SCSI",
+				DeviceClass:    "Disk",
+				Vendor:         "LIO-ORG",
+				Product:        "disk01",
+				Revision:       "4.0",
+				InstancePath:   "1&1c121344&0&000001",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			// a NetApp LUN behind MPIO
+			name: `\\?\mpio#disk&ven_netapp&prod_lun_c-mode&rev_9300#1&7f6ac24&0&36304130433634354532313537353431#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "mpio",
+				DeviceClass:    "disk",
+				Vendor:         "netapp",
+				Product:        "lun_c-mode",
+				Revision:       "9300",
+				InstancePath:   "1&7f6ac24&0&36304130433634354532313537353431",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			// a Synology NAS
+			name: `\\?\SCSI#Disk&Ven_SYNOLOGY&Prod_iSCSI_Storage&Rev_4.0#1&1c121344&0&000200#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "SCSI",
+				DeviceClass:    "Disk",
+				Vendor:         "SYNOLOGY",
+				Product:        "iSCSI_Storage",
+				Revision:       "4.0",
+				InstancePath:   "1&1c121344&0&000200",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			// a vendor-less disk
+			name: `\\?\scsi#disk&ven_&prod_st1000dm003-1er1#4&2c4a0ec8&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "scsi",
+				DeviceClass:    "disk",
+				Product:        "st1000dm003-1er1",
+				InstancePath:   "4&2c4a0ec8&0&000000",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			name: `\\?\scsi#cdrom&ven_msft&prod_virtual_dvd-rom#000002#{53f56308-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "scsi",
+				DeviceClass:    "cdrom",
+				Vendor:         "msft",
+				Product:        "virtual_dvd-rom",
+				InstancePath:   "000002",
+				InterfaceClass: iscsidsc.DeviceInterfaceCDROM,
+			},
+		},
+		{
+			name: `\\?\SCSI#Sequential&Ven_IBM&Prod_ULT3580-TD5&Rev_D8E4#5&2b4f3a1c&0&000100#{53f5630b-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "SCSI",
+				DeviceClass:    "Sequential",
+				Vendor:         "IBM",
+				Product:        "ULT3580-TD5",
+				Revision:       "D8E4",
+				InstancePath:   "5&2b4f3a1c&0&000100",
+				InterfaceClass: iscsidsc.DeviceInterfaceTape,
+			},
+		},
+		{
+			name: `\\?\USBSTOR#Disk&Ven_SanDisk&Prod_Cruzer_Blade&Rev_1.00#4C530001230517115034&0#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "USBSTOR",
+				DeviceClass:    "Disk",
+				Vendor:         "SanDisk",
+				Product:        "Cruzer_Blade",
+				Revision:       "1.00",
+				InstancePath:   "4C530001230517115034&0",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			// IDE device IDs don't follow the ven/prod/rev scheme
+			name: `\\?\ide#diskwdc_wd5000aakx-00ern0_____________________15.01h15#5&1bc3b4cb&0&0.0.0#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "ide",
+				DeviceClass:    "diskwdc_wd5000aakx-00ern0_____________________15.01h15",
+				InstancePath:   "5&1bc3b4cb&0&0.0.0",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+		{
+			name: `\\?\STORAGE#Volume#{8e2c4cd5-5a0e-11e9-a9c4-806e6f6e6963}#0000000000100000#{53f5630d-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "STORAGE",
+				DeviceClass:    "Volume",
+				InstancePath:   "{8e2c4cd5-5a0e-11e9-a9c4-806e6f6e6963}#0000000000100000",
+				InterfaceClass: iscsidsc.DeviceInterfaceVolume,
+			},
+		},
+		{
+			name: `\\?\SCSI#Changer&Ven_HP&Prod_MSL_G3_Series&Rev_E.90#5&3a1b2c4d&0&000101#{53f56310-b6bf-11d0-94f2-00a0c91efb8b}\changer0`,
+			expected: DeviceInterfaceName{
+				Bus:             "SCSI",
+				DeviceClass:     "Changer",
+				Vendor:          "HP",
+				Product:         "MSL_G3_Series",
+				Revision:        "E.90",
+				InstancePath:    "5&3a1b2c4d&0&000101",
+				InterfaceClass:  iscsidsc.DeviceInterfaceMediumChanger,
+				ReferenceString: "changer0",
+			},
+		},
+		{
+			name: `\??\SCSI#Disk&Ven_PURE&Prod_FlashArray&Rev_8888#1&1c121344&0&000003#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`,
+			expected: DeviceInterfaceName{
+				Bus:            "SCSI",
+				DeviceClass:    "Disk",
+				Vendor:         "PURE",
+				Product:        "FlashArray",
+				Revision:       "8888",
+				InstancePath:   "1&1c121344&0&000003",
+				InterfaceClass: iscsidsc.DeviceInterfaceDisk,
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := ParseDeviceInterfaceName(testCase.name)
+
+			require.Nil(t, err)
+			assert.Equal(t, testCase.expected, *parsed)
+		})
+	}
+
+	t.Run("invalid names", func(t *testing.T) {
+		for name, expectedError := range map[string]string{
+			``: `expected to start with \\?\`,
+			`scsi#disk&ven_msft#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`: `expected to start with \\?\`,
+			`\\?\scsi`:                                "missing interface class",
+			`\\?\scsi#disk#1&1c121344&0&000000#foo`:   `interface class "foo" is not enclosed in curly braces`,
+			`\\?\scsi#disk#1&1c121344&0&000000#{foo}`: `invalid interface class "{foo}"`,
+			`\\?\scsi#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`:  "expected a bus, a device ID and an instance path",
+			`\\?\#disk#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`: "empty bus",
+			`\\?\scsi##1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`: "empty device ID",
+			`\\?\scsi#disk##{53f56307-b6bf-11d0-94f2-00a0c91efb8b}`:                "empty instance path",
+		} {
+			_, err := ParseDeviceInterfaceName(name)
+			if assert.NotNil(t, err, name) {
+				assert.Contains(t, err.Error(), expectedError)
+			}
+		}
+	})
+}
+
+func TestParseDeviceInterfaceNameOnDevices(t *testing.T) {
+	device := iscsidsc.Device{
+		DeviceInterfaceType: uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b"),
+		DeviceInterfaceName: "\\\\?\\scsi#disk&ven_msft&prod_virtual_hd#1&1c121344&0&000000#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}",
+	}
+
+	parsed, err := ParseDeviceInterfaceName(device.DeviceInterfaceName)
+
+	require.Nil(t, err)
+	assert.Equal(t, device.DeviceInterfaceType, parsed.InterfaceClass)
+}
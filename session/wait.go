@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// DevicesPredicate tells whether a session's devices are the ones waited for, see `WaitForDevices`.
+type DevicesPredicate func(devices []iscsidsc.Device) bool
+
+// LUNCount is satisfied when the session has exactly count distinct LUNs.
+func LUNCount(count int) DevicesPredicate {
+	return func(devices []iscsidsc.Device) bool {
+		luns := make(map[uint8]bool)
+		for _, device := range devices {
+			luns[device.ScsiAddress.Lun] = true
+		}
+		return len(luns) == count
+	}
+}
+
+// HasLUN is satisfied when the session has a device for the given LUN.
+func HasLUN(lun uint8) DevicesPredicate {
+	return func(devices []iscsidsc.Device) bool {
+		for _, device := range devices {
+			if device.ScsiAddress.Lun == lun {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AnyDisk is satisfied when the session has at least one disk.
+func AnyDisk() DevicesPredicate {
+	return HasDeviceType(iscsidsc.DeviceTypeDisk)
+}
+
+// HasDeviceType is satisfied when the session has at least one device of the given type.
+func HasDeviceType(deviceType iscsidsc.DeviceType) DevicesPredicate {
+	return func(devices []iscsidsc.Device) bool {
+		for _, device := range devices {
+			if device.StorageDeviceNumber.DeviceType == deviceType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// The default backoff for `WaitForDevices`.
+const (
+	DefaultWaitInitialBackoff = 100 * time.Millisecond
+	DefaultWaitMaxBackoff     = 2 * time.Second
+	DefaultWaitMultiplier     = 2
+)
+
+type waitConfig struct {
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	client         *iscsidsc.Client
+	clock          iscsidsc.Clock
+}
+
+// WaitOption configures `WaitForDevices`.
+type WaitOption func(config *waitConfig)
+
+// WithBackoff sets how long to wait between two polls: initialBackoff after the first one, then
+// multiplied by multiplier after each poll, up to maxBackoff.
+// Defaults to `DefaultWaitInitialBackoff`, `DefaultWaitMaxBackoff` and `DefaultWaitMultiplier`;
+// multipliers lower than 1 are treated as 1.
+func WithBackoff(initialBackoff, maxBackoff time.Duration, multiplier float64) WaitOption {
+	return func(config *waitConfig) {
+		config.initialBackoff = initialBackoff
+		config.maxBackoff = maxBackoff
+		config.multiplier = multiplier
+	}
+}
+
+// WithClient sets the client used to list the session's devices.
+// Defaults to the default client, see `iscsidsc.DefaultClient`.
+func WithClient(client *iscsidsc.Client) WaitOption {
+	return func(config *waitConfig) {
+		config.client = client
+	}
+}
+
+// WithClock sets the clock used to wait between two polls.
+// Defaults to `iscsidsc.SystemClock`.
+func WithClock(clock iscsidsc.Clock) WaitOption {
+	return func(config *waitConfig) {
+		config.clock = clock
+	}
+}
+
+// WaitForDevices polls the session's devices until they satisfy predicate, and then returns them; this
+// is useful right after logging in, as Windows can take a little while to report a session's devices.
+// It gives up when ctx is done, and then returns the last devices it got along with an error whose
+// cause is ctx's error, to help diagnosing what went wrong.
+// Errors when listing devices are returned right away, along with the last devices listed successfully.
+// It uses the default client unless told otherwise, see `WithClient`.
+func WaitForDevices(ctx context.Context, id iscsidsc.SessionID, predicate DevicesPredicate, opts ...WaitOption) ([]iscsidsc.Device, error) {
+	config := &waitConfig{
+		initialBackoff: DefaultWaitInitialBackoff,
+		maxBackoff:     DefaultWaitMaxBackoff,
+		multiplier:     DefaultWaitMultiplier,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.multiplier < 1 {
+		config.multiplier = 1
+	}
+	if config.client == nil {
+		config.client = iscsidsc.DefaultClient()
+	}
+	if config.clock == nil {
+		config.clock = iscsidsc.SystemClock{}
+	}
+
+	var lastDevices []iscsidsc.Device
+	backoff := config.initialBackoff
+	for {
+		devices, err := config.client.GetDevicesForIScsiSessionContext(ctx, id)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return lastDevices, timeoutError(ctxErr, id, lastDevices)
+			}
+			return lastDevices, errors.Wrapf(err, "unable to list devices for session %v", id)
+		}
+		lastDevices = devices
+
+		if predicate(devices) {
+			return devices, nil
+		}
+
+		select {
+		case <-config.clock.After(backoff):
+		case <-ctx.Done():
+			return lastDevices, timeoutError(ctx.Err(), id, lastDevices)
+		}
+
+		backoff = time.Duration(float64(backoff) * config.multiplier)
+		if config.maxBackoff > 0 && backoff > config.maxBackoff {
+			backoff = config.maxBackoff
+		}
+	}
+}
+
+func timeoutError(ctxErr error, id iscsidsc.SessionID, lastDevices []iscsidsc.Device) error {
+	return errors.Wrapf(ctxErr, "gave up waiting for devices on session %v, last saw %d device(s)", id, len(lastDevices))
+}
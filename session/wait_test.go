@@ -0,0 +1,185 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+var sessionID = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002}
+
+func device(lun uint8, deviceType iscsidsc.DeviceType) iscsidsc.Device {
+	return iscsidsc.Device{
+		ScsiAddress:         iscsidsc.ScsiAddress{Lun: lun},
+		StorageDeviceNumber: iscsidsc.StorageDeviceNumber{DeviceType: deviceType, DeviceNumber: uint32(lun)},
+	}
+}
+
+func TestDevicesPredicates(t *testing.T) {
+	disks := []iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk), device(1, iscsidsc.DeviceTypeDisk)}
+	tapes := []iscsidsc.Device{device(3, iscsidsc.DeviceTypeTape)}
+
+	for _, testCase := range []struct {
+		name      string
+		predicate DevicesPredicate
+		devices   []iscsidsc.Device
+		expected  bool
+	}{
+		{"LUN count, no devices", LUNCount(2), nil, false},
+		{"LUN count, matching", LUNCount(2), disks, true},
+		{"LUN count, too many", LUNCount(1), disks, false},
+		{"LUN count, several devices on the same LUN", LUNCount(1), []iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk), device(0, iscsidsc.DeviceTypeDisk)}, true},
+		{"LUN, present", HasLUN(1), disks, true},
+		{"LUN, absent", HasLUN(2), disks, false},
+		{"any disk, with disks", AnyDisk(), disks, true},
+		{"any disk, with a tape", AnyDisk(), tapes, false},
+		{"device type, present", HasDeviceType(iscsidsc.DeviceTypeTape), tapes, true},
+		{"device type, absent", HasDeviceType(iscsidsc.DeviceTypeTape), disks, false},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.predicate(testCase.devices))
+		})
+	}
+}
+
+func TestWaitForDevices(t *testing.T) {
+	t.Run("it polls with backoff until the predicate is satisfied", func(t *testing.T) {
+		backend := newFakeBackend(
+			nil,
+			[]iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)},
+			[]iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)},
+			[]iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk), device(1, iscsidsc.DeviceTypeDisk)},
+		)
+		clock := &fakeClock{}
+
+		devices, err := WaitForDevices(context.Background(), sessionID, LUNCount(2), backend.option(), WithClock(clock), WithBackoff(time.Second, 3*time.Second, 2))
+
+		require.Nil(t, err)
+		assert.Equal(t, 2, len(devices))
+		assert.Equal(t, 4, backend.calls)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, clock.waits)
+	})
+
+	t.Run("it doesn't wait if the predicate is satisfied right away", func(t *testing.T) {
+		backend := newFakeBackend([]iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)})
+		clock := &fakeClock{}
+
+		devices, err := WaitForDevices(context.Background(), sessionID, AnyDisk(), backend.option(), WithClock(clock))
+
+		require.Nil(t, err)
+		assert.Equal(t, 1, len(devices))
+		assert.Equal(t, 0, len(clock.waits))
+	})
+
+	t.Run("it uses the default backoff", func(t *testing.T) {
+		backend := newFakeBackend(nil, nil, nil, nil, nil, nil, []iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)})
+		clock := &fakeClock{}
+
+		_, err := WaitForDevices(context.Background(), sessionID, AnyDisk(), backend.option(), WithClock(clock))
+
+		require.Nil(t, err)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+			800 * time.Millisecond, 1600 * time.Millisecond, 2 * time.Second}, clock.waits)
+	})
+
+	t.Run("it uses the default client", func(t *testing.T) {
+		backend := newFakeBackend([]iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)})
+		previous := iscsidsc.SetDefaultClient(iscsidsc.NewClient(iscsidsc.WithBackend(backend)))
+		defer iscsidsc.SetDefaultClient(previous)
+
+		devices, err := WaitForDevices(context.Background(), sessionID, AnyDisk())
+
+		require.Nil(t, err)
+		assert.Equal(t, 1, len(devices))
+		assert.Equal(t, 1, backend.calls)
+	})
+
+	t.Run("on timeout, it returns the last devices it saw", func(t *testing.T) {
+		lastDevices := []iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)}
+		backend := newFakeBackend(nil, lastDevices)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		// the context gets cancelled while waiting after the 2nd poll
+		clock := &fakeClock{onWait: func(waitsCount int) {
+			if waitsCount == 2 {
+				cancel()
+			}
+		}}
+
+		devices, err := WaitForDevices(ctx, sessionID, LUNCount(2), backend.option(), WithClock(clock))
+
+		assert.Equal(t, lastDevices, devices)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, context.Canceled, errors.Cause(err))
+			assert.Equal(t, "gave up waiting for devices on session ffffe0008ff4b010-4000013700000002, last saw 1 device(s): context canceled", err.Error())
+		}
+	})
+
+	t.Run("it returns errors right away", func(t *testing.T) {
+		lastDevices := []iscsidsc.Device{device(0, iscsidsc.DeviceTypeDisk)}
+		backend := newFakeBackend(lastDevices, iscsidsc.NewWinAPICallError("GetDevicesForIScsiSessionW", uintptr(iscsidsc.ErrSessionNotFound)))
+
+		devices, err := WaitForDevices(context.Background(), sessionID, LUNCount(2), backend.option(), WithClock(&fakeClock{}))
+
+		assert.Equal(t, lastDevices, devices)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "unable to list devices for session ffffe0008ff4b010-4000013700000002")
+			assert.True(t, iscsidsc.HasErrorCode(err, iscsidsc.ErrSessionNotFound))
+		}
+	})
+}
+
+// fakeBackend returns the next of its results each time it's asked to list devices, each either
+// a device slice or an error.
+type fakeBackend struct {
+	iscsidsc.Backend
+	results []interface{}
+	calls   int
+}
+
+func newFakeBackend(results ...interface{}) *fakeBackend {
+	return &fakeBackend{results: results}
+}
+
+func (b *fakeBackend) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	if id != sessionID {
+		panic("unexpected session ID")
+	}
+	result := b.results[b.calls]
+	b.calls++
+	if err, isErr := result.(error); isErr {
+		return nil, err
+	}
+	devices, _ := result.([]iscsidsc.Device)
+	return devices, nil
+}
+
+func (b *fakeBackend) option() WaitOption {
+	return WithClient(iscsidsc.NewClient(iscsidsc.WithBackend(b)))
+}
+
+// fakeClock records how long it's asked to wait, and returns right away after calling onWait if not nil;
+// its time never moves.
+type fakeClock struct {
+	waits  []time.Duration
+	onWait func(waitsCount int)
+}
+
+func (c *fakeClock) Now() time.Time {
+	return time.Time{}
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	if c.onWait != nil {
+		c.onWait(len(c.waits))
+	}
+	ch := make(chan time.Time, 1)
+	ch <- time.Now()
+	return ch
+}
@@ -0,0 +1,45 @@
+package iscsidsc
+
+import (
+	"github.com/wk8/go-win-iscsidsc/names"
+)
+
+// TargetPortal returns the target portal the connection goes through.
+func (info *ConnectionInfo) TargetPortal() *Portal {
+	socket := info.TargetSocket
+	return &Portal{
+		Address: info.TargetAddress,
+		Socket:  &socket,
+	}
+}
+
+// IsForTarget returns true iff the session is to the given target, i.e. its target node name or target
+// name matches targetName, see `names.Match`. Empty target names never match.
+func (info *SessionInfo) IsForTarget(targetName string) bool {
+	if targetName == "" {
+		return false
+	}
+	return names.Match(info.TargetNodeName, targetName) || names.Match(info.TargetName, targetName)
+}
+
+// HasConnectionThrough returns true iff the session has a connection through the given target portal,
+// see `Portal.Equal`.
+func (info *SessionInfo) HasConnectionThrough(portal *Portal) bool {
+	for i := range info.Connections {
+		if portal.Equal(info.Connections[i].TargetPortal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSession returns the first of sessions that's to the given target, see `SessionInfo.IsForTarget`,
+// and that has a connection through portal if not nil; or nil if there's none.
+func FindSession(sessions []SessionInfo, targetName string, portal *Portal) *SessionInfo {
+	for i := range sessions {
+		if sessions[i].IsForTarget(targetName) && (portal == nil || sessions[i].HasConnectionThrough(portal)) {
+			return &sessions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package iscsidsc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionInfoIsForTarget(t *testing.T) {
+	info := &SessionInfo{TargetNodeName: "iqn.1991-05.com.microsoft:Target"}
+	assert.True(t, info.IsForTarget("IQN.1991-05.com.microsoft:target"))
+	assert.False(t, info.IsForTarget("iqn.1991-05.com.microsoft:other-target"))
+
+	// Windows reports the target name instead of the node name for some sessions
+	info = &SessionInfo{TargetName: "iqn.1991-05.com.microsoft:My Target"}
+	assert.True(t, info.IsForTarget("iqn.1991-05.com.microsoft:my target"))
+	assert.False(t, info.IsForTarget(""))
+}
+
+func TestConnectionInfoTargetPortal(t *testing.T) {
+	socket := uint16(3261)
+	info := &ConnectionInfo{TargetAddress: "10.0.0.5", TargetSocket: socket}
+	assert.Equal(t, &Portal{Address: "10.0.0.5", Socket: &socket}, info.TargetPortal())
+}
+
+func TestFindSession(t *testing.T) {
+	sessions := []SessionInfo{
+		{SessionID: SessionID{AdapterUnique: 1}, TargetNodeName: "iqn.1991-05.com.microsoft:other-target"},
+		{
+			SessionID:      SessionID{AdapterUnique: 2},
+			TargetNodeName: "iqn.1991-05.com.microsoft:target",
+			Connections:    []ConnectionInfo{{TargetAddress: "10.0.0.5", TargetSocket: DefaultPortalSocket}},
+		},
+		{
+			SessionID:      SessionID{AdapterUnique: 3},
+			TargetNodeName: "iqn.1991-05.com.microsoft:target",
+			Connections:    []ConnectionInfo{{TargetAddress: "10.0.0.6", TargetSocket: DefaultPortalSocket}},
+		},
+	}
+
+	assert.Equal(t, &sessions[1], FindSession(sessions, "IQN.1991-05.com.microsoft:Target", nil))
+	assert.Equal(t, &sessions[2], FindSession(sessions, "iqn.1991-05.com.microsoft:target", &Portal{Address: "10.0.0.6"}))
+	assert.Nil(t, FindSession(sessions, "iqn.1991-05.com.microsoft:target", &Portal{Address: "10.0.0.7"}))
+	assert.Nil(t, FindSession(sessions, "iqn.1991-05.com.microsoft:unknown-target", nil))
+}
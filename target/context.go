@@ -0,0 +1,30 @@
+package target
+
+import (
+	"context"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// This file contains the context-aware variants of this package's API.
+// They return ctx's error as soon as ctx is done, while the underlying call to Windows' API
+// finishes in the background; its outcome is then reported to ctx's `iscsidsc.OrphanHandler`, if any.
+// They use the default client, see `iscsidsc.DefaultClient`.
+
+// ReportIScsiTargetsContext is the same as `ReportIScsiTargets`, but it stops waiting when ctx is done.
+func ReportIScsiTargetsContext(ctx context.Context, forceUpdate bool) ([]string, error) {
+	return iscsidsc.DefaultClient().ReportIScsiTargetsContext(ctx, forceUpdate)
+}
+
+// LoginIscsiTargetContext is the same as `LoginIscsiTarget`, but it stops waiting when ctx is done.
+// Sessions created after that are reported to ctx's orphan handler.
+func LoginIscsiTargetContext(ctx context.Context, targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	return iscsidsc.DefaultClient().LoginIscsiTargetContext(ctx, targetName, isInformationalSession, initiatorInstance, initiatorPortNumber, targetPortal,
+		securityFlags, loginOptions, key, isPersistent)
+}
+
+// LogoutIScsiTargetContext is the same as `LogoutIScsiTarget`, but it stops waiting when ctx is done.
+func LogoutIScsiTargetContext(ctx context.Context, sessionID iscsidsc.SessionID) error {
+	return iscsidsc.DefaultClient().LogoutIScsiTargetContext(ctx, sessionID)
+}
@@ -0,0 +1,106 @@
+package target
+
+import (
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// EnsureLoggedIn logs in as per request, unless there's already a session to the target; it returns
+// the ID of the session, and whether it logged in.
+// If request sets a target portal, only sessions with a connection through that portal count.
+// Windows doesn't report sessions' login options, so existing sessions are used whatever the options
+// they were established with, and whatever the other settings in request.
+// It uses the default client, see `iscsidsc.DefaultClient`.
+func EnsureLoggedIn(request *iscsidsc.LoginRequest) (*iscsidsc.SessionID, bool, error) {
+	return EnsureLoggedInWithClient(iscsidsc.DefaultClient(), request)
+}
+
+// EnsureLoggedInWithClient is the same as `EnsureLoggedIn`, but it uses the given client.
+func EnsureLoggedInWithClient(client *iscsidsc.Client, request *iscsidsc.LoginRequest) (*iscsidsc.SessionID, bool, error) {
+	if err := request.Validate(); err != nil {
+		return nil, false, err
+	}
+
+	sessionID, err := findSession(client, request.TargetName, request.TargetPortal)
+	if err != nil || sessionID != nil {
+		return sessionID, false, err
+	}
+
+	sessionID, _, err = client.Login(request)
+	if err == nil {
+		return sessionID, true, nil
+	}
+	if !iscsidsc.IsAlreadyLoggedIn(err) {
+		return nil, false, err
+	}
+
+	// logged in concurrently
+	if sessionID, findErr := findSession(client, request.TargetName, request.TargetPortal); findErr != nil || sessionID != nil {
+		return sessionID, false, findErr
+	}
+	return nil, false, err
+}
+
+// EnsureLoggedOut logs out of all the sessions to the target; it returns whether there were any.
+// Target names are matched as per `iscsidsc.SessionInfo.IsForTarget`, so they don't have to be valid
+// iSCSI names, as Windows doesn't enforce that.
+// It tries to log out of all the sessions even if some fail, and then returns the first error.
+// It uses the default client, see `iscsidsc.DefaultClient`.
+func EnsureLoggedOut(targetName string) (bool, error) {
+	return EnsureLoggedOutWithClient(iscsidsc.DefaultClient(), targetName)
+}
+
+// EnsureLoggedOutWithClient is the same as `EnsureLoggedOut`, but it uses the given client.
+func EnsureLoggedOutWithClient(client *iscsidsc.Client, targetName string) (bool, error) {
+	if targetName == "" {
+		return false, errors.Errorf("targetName is required")
+	}
+
+	sessions, err := client.GetIScsiSessionList()
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		changed      bool
+		firstErr     error
+		failed, seen int
+	)
+	for i := range sessions {
+		if !sessions[i].IsForTarget(targetName) {
+			continue
+		}
+		seen++
+
+		err := client.LogoutIScsiTarget(sessions[i].SessionID)
+		switch {
+		case err == nil:
+			changed = true
+		case iscsidsc.HasErrorCode(err, iscsidsc.ErrSessionNotFound):
+			// already gone
+		default:
+			failed++
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "unable to log out of session %v", sessions[i].SessionID)
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return changed, errors.Wrapf(firstErr, "%d session(s) out of %d to target %s failed to log out", failed, seen, targetName)
+	}
+	return changed, nil
+}
+
+// findSession looks for an existing session to the given target, with a connection through portal
+// if not nil.
+func findSession(client *iscsidsc.Client, targetName string, portal *iscsidsc.Portal) (*iscsidsc.SessionID, error) {
+	sessions, err := client.GetIScsiSessionList()
+	if err != nil {
+		return nil, err
+	}
+	if session := iscsidsc.FindSession(sessions, targetName, portal); session != nil {
+		return &session.SessionID, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,201 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+const (
+	targetName      = "iqn.2010-01.com.example:target"
+	otherTargetName = "iqn.2010-01.com.example:other-target"
+)
+
+// fakeBackend serves canned sessions, and records logins and logouts.
+type fakeBackend struct {
+	iscsidsc.Backend
+	sessions []iscsidsc.SessionInfo
+	// sessions to add to the list when logging in
+	sessionsAfterLogin []iscsidsc.SessionInfo
+	loginErr           error
+	logoutErrs         map[iscsidsc.SessionID]error
+
+	logins  []string
+	logouts []iscsidsc.SessionID
+}
+
+func (b *fakeBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return b.sessions, nil
+}
+
+func (b *fakeBackend) LoginIscsiTarget(targetName string, isInformationalSession bool, initiatorInstance *string, initiatorPortNumber *uint32, targetPortal *iscsidsc.Portal,
+	securityFlags *iscsidsc.SecurityFlags, loginOptions *iscsidsc.LoginOptions, key *iscsidsc.Secret, isPersistent bool) (*iscsidsc.SessionID, *iscsidsc.ConnectionID, error) {
+	b.logins = append(b.logins, targetName)
+	b.sessions = append(b.sessions, b.sessionsAfterLogin...)
+	if b.loginErr != nil {
+		return nil, nil, b.loginErr
+	}
+	return &iscsidsc.SessionID{AdapterUnique: 42}, &iscsidsc.ConnectionID{}, nil
+}
+
+func (b *fakeBackend) LogoutIScsiTarget(sessionID iscsidsc.SessionID) error {
+	b.logouts = append(b.logouts, sessionID)
+	return b.logoutErrs[sessionID]
+}
+
+func newClient(backend *fakeBackend) *iscsidsc.Client {
+	return iscsidsc.NewClient(iscsidsc.WithBackend(backend))
+}
+
+func session(adapterUnique uint64, targetName string, targetAddresses ...string) iscsidsc.SessionInfo {
+	info := iscsidsc.SessionInfo{
+		SessionID:  iscsidsc.SessionID{AdapterUnique: adapterUnique},
+		TargetName: targetName,
+	}
+	for _, address := range targetAddresses {
+		info.Connections = append(info.Connections, iscsidsc.ConnectionInfo{TargetAddress: address, TargetSocket: 3260})
+	}
+	return info
+}
+
+func winAPIError(code iscsidsc.ErrorCode) error {
+	return iscsidsc.NewWinAPICallError("Dummy", uintptr(code))
+}
+
+func TestEnsureLoggedIn(t *testing.T) {
+	for _, testCase := range []struct {
+		name               string
+		sessions           []iscsidsc.SessionInfo
+		sessionsAfterLogin []iscsidsc.SessionInfo
+		loginErr           error
+		portal             *iscsidsc.Portal
+
+		expectedSessionID *iscsidsc.SessionID
+		expectedChanged   bool
+		expectedErr       error
+	}{
+		{
+			name:              "already logged in",
+			sessions:          []iscsidsc.SessionInfo{session(1, otherTargetName), session(2, "IQN.2010-01.com.example:TARGET")},
+			expectedSessionID: &iscsidsc.SessionID{AdapterUnique: 2},
+		},
+		{
+			name:              "not logged in",
+			sessions:          []iscsidsc.SessionInfo{session(1, otherTargetName)},
+			expectedSessionID: &iscsidsc.SessionID{AdapterUnique: 42},
+			expectedChanged:   true,
+		},
+		{
+			name:              "already logged in through the portal",
+			sessions:          []iscsidsc.SessionInfo{session(1, targetName, "10.0.0.5"), session(2, targetName, "10.0.0.5", "10.0.0.6")},
+			portal:            &iscsidsc.Portal{Address: "10.0.0.6"},
+			expectedSessionID: &iscsidsc.SessionID{AdapterUnique: 2},
+		},
+		{
+			name:              "logged in through another portal",
+			sessions:          []iscsidsc.SessionInfo{session(1, targetName, "10.0.0.5")},
+			portal:            &iscsidsc.Portal{Address: "10.0.0.6"},
+			expectedSessionID: &iscsidsc.SessionID{AdapterUnique: 42},
+			expectedChanged:   true,
+		},
+		{
+			name:               "logged in concurrently",
+			sessionsAfterLogin: []iscsidsc.SessionInfo{session(3, targetName)},
+			loginErr:           winAPIError(iscsidsc.ErrTargetAlreadyLoggedIn),
+			expectedSessionID:  &iscsidsc.SessionID{AdapterUnique: 3},
+		},
+		{
+			name:               "logged in concurrently, reported as an existing session",
+			sessionsAfterLogin: []iscsidsc.SessionInfo{session(3, targetName)},
+			loginErr:           winAPIError(iscsidsc.ErrSessionAlreadyExists),
+			expectedSessionID:  &iscsidsc.SessionID{AdapterUnique: 3},
+		},
+		{
+			name:        "login fails",
+			loginErr:    winAPIError(iscsidsc.ErrLoginAuthFailed),
+			expectedErr: winAPIError(iscsidsc.ErrLoginAuthFailed),
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			backend := &fakeBackend{
+				sessions:           testCase.sessions,
+				sessionsAfterLogin: testCase.sessionsAfterLogin,
+				loginErr:           testCase.loginErr,
+			}
+
+			sessionID, changed, err := EnsureLoggedInWithClient(newClient(backend), iscsidsc.NewLoginRequest(targetName, iscsidsc.WithTargetPortal(testCase.portal)))
+			assert.Equal(t, testCase.expectedSessionID, sessionID)
+			assert.Equal(t, testCase.expectedChanged, changed)
+			assert.Equal(t, testCase.expectedErr, err)
+		})
+	}
+}
+
+func TestEnsureLoggedOut(t *testing.T) {
+	t.Run("logs out of all sessions to the target", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessions: []iscsidsc.SessionInfo{session(1, targetName), session(2, otherTargetName), session(3, targetName)},
+		}
+
+		changed, err := EnsureLoggedOutWithClient(newClient(backend), targetName)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, []iscsidsc.SessionID{{AdapterUnique: 1}, {AdapterUnique: 3}}, backend.logouts)
+	})
+
+	t.Run("not logged in", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessions: []iscsidsc.SessionInfo{session(2, otherTargetName)},
+		}
+
+		changed, err := EnsureLoggedOutWithClient(newClient(backend), targetName)
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Empty(t, backend.logouts)
+	})
+
+	t.Run("sessions that are already gone don't count", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessions:   []iscsidsc.SessionInfo{session(1, targetName)},
+			logoutErrs: map[iscsidsc.SessionID]error{{AdapterUnique: 1}: winAPIError(iscsidsc.ErrSessionNotFound)},
+		}
+
+		changed, err := EnsureLoggedOutWithClient(newClient(backend), targetName)
+		require.NoError(t, err)
+		assert.False(t, changed)
+	})
+
+	t.Run("keeps going on errors", func(t *testing.T) {
+		failure := winAPIError(iscsidsc.ErrConnectionFailed)
+		backend := &fakeBackend{
+			sessions:   []iscsidsc.SessionInfo{session(1, targetName), session(3, targetName)},
+			logoutErrs: map[iscsidsc.SessionID]error{{AdapterUnique: 1}: failure},
+		}
+
+		changed, err := EnsureLoggedOutWithClient(newClient(backend), targetName)
+		assert.True(t, changed)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 session(s) out of 2 to target iqn.2010-01.com.example:target failed to log out: "+
+			"unable to log out of session 0000000000000001-0000000000000000")
+		assert.Equal(t, 2, len(backend.logouts))
+	})
+
+	t.Run("target names don't have to be valid iSCSI names", func(t *testing.T) {
+		backend := &fakeBackend{
+			sessions: []iscsidsc.SessionInfo{session(1, "iqn.2010-01.com.example:My Target"), session(2, targetName)},
+		}
+
+		changed, err := EnsureLoggedOutWithClient(newClient(backend), "iqn.2010-01.com.example:my target")
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, []iscsidsc.SessionID{{AdapterUnique: 1}}, backend.logouts)
+	})
+
+	t.Run("empty target name", func(t *testing.T) {
+		_, err := EnsureLoggedOutWithClient(newClient(&fakeBackend{}), "")
+		require.Error(t, err)
+		assert.Equal(t, "targetName is required", err.Error())
+	})
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package target
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestUnsupportedPlatform(t *testing.T) {
+	_, err := ReportIScsiTargets(true)
+	assert.Equal(t, iscsidsc.ErrNotSupported, err)
+
+	sessionID, connectionID, err := LoginIscsiTarget("iqn.1991-05.com.microsoft:target", false, nil, nil, nil, nil, nil, nil, false)
+	assert.Nil(t, sessionID)
+	assert.Nil(t, connectionID)
+	assert.Equal(t, iscsidsc.ErrNotSupported, err)
+
+	assert.Equal(t, iscsidsc.ErrNotSupported, LogoutIScsiTarget(iscsidsc.SessionID{}))
+}
+
+func TestUnsupportedPlatformWithContext(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := ReportIScsiTargetsContext(ctx, true)
+	assert.Equal(t, iscsidsc.ErrNotSupported, err)
+
+	sessionID, connectionID, err := LoginIscsiTargetContext(ctx, "iqn.1991-05.com.microsoft:target", false, nil, nil, nil, nil, nil, nil, false)
+	assert.Nil(t, sessionID)
+	assert.Nil(t, connectionID)
+	assert.Equal(t, iscsidsc.ErrNotSupported, err)
+
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, LogoutIScsiTargetContext(cancelledCtx, iscsidsc.SessionID{}))
+}
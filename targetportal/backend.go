@@ -0,0 +1,6 @@
+package targetportal
+
+import (
+	// registers the default backend, that makes calls to Windows' API
+	_ "github.com/wk8/go-win-iscsidsc/internal"
+)
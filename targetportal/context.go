@@ -0,0 +1,27 @@
+package targetportal
+
+import (
+	"context"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// This file contains the context-aware variants of this package's API.
+// They return ctx's error as soon as ctx is done, while the underlying call to Windows' API
+// finishes in the background; its outcome is then reported to ctx's `iscsidsc.OrphanHandler`, if any.
+// They use the default client, see `iscsidsc.DefaultClient`.
+
+// AddIScsiSendTargetPortalContext is the same as `AddIScsiSendTargetPortal`, but it stops waiting when ctx is done.
+func AddIScsiSendTargetPortalContext(ctx context.Context, initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions, securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) error {
+	return iscsidsc.DefaultClient().AddIScsiSendTargetPortalContext(ctx, initiatorInstance, initiatorPortNumber, loginOptions, securityFlags, portal)
+}
+
+// ReportIScsiSendTargetPortalsContext is the same as `ReportIScsiSendTargetPortals`, but it stops waiting when ctx is done.
+func ReportIScsiSendTargetPortalsContext(ctx context.Context) ([]iscsidsc.PortalInfo, error) {
+	return iscsidsc.DefaultClient().ReportIScsiSendTargetPortalsContext(ctx)
+}
+
+// RemoveIScsiSendTargetPortalContext is the same as `RemoveIScsiSendTargetPortal`, but it stops waiting when ctx is done.
+func RemoveIScsiSendTargetPortalContext(ctx context.Context, initiatorInstance *string, initiatorPortNumber *uint32, portal *iscsidsc.Portal) error {
+	return iscsidsc.DefaultClient().RemoveIScsiSendTargetPortalContext(ctx, initiatorInstance, initiatorPortNumber, portal)
+}
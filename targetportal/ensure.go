@@ -0,0 +1,137 @@
+package targetportal
+
+import (
+	"fmt"
+	"strings"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// MismatchError is returned by `EnsurePortal` when the portal is already registered, but with settings
+// different from the requested ones.
+type MismatchError struct {
+	Portal *iscsidsc.Portal
+	// Fields lists the settings that differ, e.g. "SecurityFlags" or "LoginOptions.AuthType".
+	Fields []string
+}
+
+func (err *MismatchError) Error() string {
+	return fmt.Sprintf("portal %v is already registered with different settings: %s", err.Portal, strings.Join(err.Fields, ", "))
+}
+
+// EnsurePortal adds the portal in request, unless it's already registered; it returns whether it added it.
+// A registration matches if it's for the same portal (see `iscsidsc.Portal.Equal`), and for the same
+// initiator instance and port number if the request sets them.
+// Windows can't change a registered portal's settings, so if the portal is registered with security flags
+// or login options different from the requested ones, it's left untouched and a `*MismatchError` is
+// returned; callers can then remove it and add it again if that's what they want. Login options left nil
+// in the request must also be unset on the registration, except for credentials, which are only compared
+// when Windows reports them.
+// It uses the default client, see `iscsidsc.DefaultClient`.
+func EnsurePortal(request *iscsidsc.AddPortalRequest) (bool, error) {
+	return EnsurePortalWithClient(iscsidsc.DefaultClient(), request)
+}
+
+// EnsurePortalWithClient is the same as `EnsurePortal`, but it uses the given client.
+func EnsurePortalWithClient(client *iscsidsc.Client, request *iscsidsc.AddPortalRequest) (bool, error) {
+	if err := request.Validate(); err != nil {
+		return false, err
+	}
+
+	portalInfos, err := client.ReportIScsiSendTargetPortals()
+	if err != nil {
+		return false, err
+	}
+
+	var mismatchErr *MismatchError
+	for i := range portalInfos {
+		portalInfo := &portalInfos[i]
+		if !isRegistrationFor(portalInfo, request) {
+			continue
+		}
+		fields := differingSettings(portalInfo, request)
+		if len(fields) == 0 {
+			return false, nil
+		}
+		if mismatchErr == nil {
+			mismatchErr = &MismatchError{Portal: request.Portal, Fields: fields}
+		}
+	}
+	if mismatchErr != nil {
+		return false, mismatchErr
+	}
+
+	if err := client.AddPortal(request); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func isRegistrationFor(portalInfo *iscsidsc.PortalInfo, request *iscsidsc.AddPortalRequest) bool {
+	if !portalInfo.Portal.Equal(request.Portal) {
+		return false
+	}
+	if request.InitiatorInstance != nil && !strings.EqualFold(*request.InitiatorInstance, portalInfo.InitiatorName) {
+		return false
+	}
+	return request.InitiatorPortNumber == nil || *request.InitiatorPortNumber == portalInfo.InitiatorPortNumber
+}
+
+// differingSettings returns the names of the settings that differ between the registration and the request.
+func differingSettings(portalInfo *iscsidsc.PortalInfo, request *iscsidsc.AddPortalRequest) (fields []string) {
+	var securityFlags iscsidsc.SecurityFlags
+	if request.SecurityFlags != nil {
+		securityFlags = *request.SecurityFlags
+	}
+	if securityFlags != portalInfo.SecurityFlags {
+		fields = append(fields, "SecurityFlags")
+	}
+
+	registered := &portalInfo.LoginOptions
+	requested := request.LoginOptions
+	if requested == nil {
+		requested = &iscsidsc.LoginOptions{}
+	}
+
+	if requested.LoginFlags != registered.LoginFlags {
+		fields = append(fields, "LoginOptions.LoginFlags")
+	}
+	if !equalAuthTypes(requested.AuthType, registered.AuthType) {
+		fields = append(fields, "LoginOptions.AuthType")
+	}
+	if !equalDigestTypes(requested.HeaderDigest, registered.HeaderDigest) {
+		fields = append(fields, "LoginOptions.HeaderDigest")
+	}
+	if !equalDigestTypes(requested.DataDigest, registered.DataDigest) {
+		fields = append(fields, "LoginOptions.DataDigest")
+	}
+	if !equalUint32s(requested.MaximumConnections, registered.MaximumConnections) {
+		fields = append(fields, "LoginOptions.MaximumConnections")
+	}
+	if !equalUint32s(requested.DefaultTime2Wait, registered.DefaultTime2Wait) {
+		fields = append(fields, "LoginOptions.DefaultTime2Wait")
+	}
+	if !equalUint32s(requested.DefaultTime2Retain, registered.DefaultTime2Retain) {
+		fields = append(fields, "LoginOptions.DefaultTime2Retain")
+	}
+	if registered.Username != nil && !registered.Username.Equal(requested.Username) {
+		fields = append(fields, "LoginOptions.Username")
+	}
+	if registered.Password != nil && !registered.Password.Equal(requested.Password) {
+		fields = append(fields, "LoginOptions.Password")
+	}
+
+	return
+}
+
+func equalAuthTypes(a, b *iscsidsc.AuthType) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func equalDigestTypes(a, b *iscsidsc.DigestType) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func equalUint32s(a, b *uint32) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
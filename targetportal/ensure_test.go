@@ -0,0 +1,128 @@
+package targetportal
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// fakeBackend serves canned portals, and records the portals it's asked to add.
+type fakeBackend struct {
+	iscsidsc.Backend
+	portalInfos []iscsidsc.PortalInfo
+	added       []*iscsidsc.Portal
+}
+
+func (b *fakeBackend) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	return b.portalInfos, nil
+}
+
+func (b *fakeBackend) AddIScsiSendTargetPortal(initiatorInstance *string, initiatorPortNumber *uint32, loginOptions *iscsidsc.LoginOptions, securityFlags *iscsidsc.SecurityFlags, portal *iscsidsc.Portal) error {
+	b.added = append(b.added, portal)
+	return nil
+}
+
+func newClient(backend *fakeBackend) *iscsidsc.Client {
+	return iscsidsc.NewClient(iscsidsc.WithBackend(backend))
+}
+
+func TestEnsurePortal(t *testing.T) {
+	socket := uint16(3260)
+	authType := iscsidsc.CHAPAuthType
+	maximumConnections := uint32(4)
+	securityFlags := iscsidsc.SecurityFlagIkeIpsecEnabled
+
+	registered := iscsidsc.PortalInfo{
+		Portal:              iscsidsc.Portal{Address: "10.0.0.5", Socket: &socket},
+		InitiatorName:       `ROOT\ISCSIPRT\0000_0`,
+		InitiatorPortNumber: 1,
+		SecurityFlags:       securityFlags,
+		LoginOptions: iscsidsc.LoginOptions{
+			AuthType: &authType,
+			Username: iscsidsc.NewSecretFromString("user"),
+			Password: iscsidsc.NewSecretFromString("password1234"),
+		},
+	}
+	matchingOptions := func() *iscsidsc.LoginOptions {
+		return &iscsidsc.LoginOptions{
+			AuthType: &authType,
+			Username: iscsidsc.NewSecretFromString("user"),
+			Password: iscsidsc.NewSecretFromString("password1234"),
+		}
+	}
+
+	for _, testCase := range []struct {
+		name            string
+		request         *iscsidsc.AddPortalRequest
+		expectedChanged bool
+		expectedFields  []string
+	}{
+		{
+			name: "already registered",
+			request: iscsidsc.NewAddPortalRequest(&iscsidsc.Portal{Address: "10.0.0.5"},
+				iscsidsc.WithSecurityFlags(securityFlags), iscsidsc.WithLoginOptions(matchingOptions())),
+		},
+		{
+			name: "already registered for the requested initiator",
+			request: iscsidsc.NewAddPortalRequest(&iscsidsc.Portal{Address: "10.0.0.5"},
+				iscsidsc.WithInitiatorInstance(`root\iscsiprt\0000_0`), iscsidsc.WithInitiatorPortNumber(1),
+				iscsidsc.WithSecurityFlags(securityFlags), iscsidsc.WithLoginOptions(matchingOptions())),
+		},
+		{
+			name:            "not registered",
+			request:         iscsidsc.NewAddPortalRequest(&iscsidsc.Portal{Address: "10.0.0.6"}),
+			expectedChanged: true,
+		},
+		{
+			name: "registered on another port",
+			request: iscsidsc.NewAddPortalRequest(&iscsidsc.Portal{Address: "10.0.0.5"},
+				iscsidsc.WithInitiatorPortNumber(2)),
+			expectedChanged: true,
+		},
+		{
+			name: "registered with different settings",
+			request: iscsidsc.NewAddPortalRequest(&iscsidsc.Portal{Address: "10.0.0.5"},
+				iscsidsc.WithLoginOptions(&iscsidsc.LoginOptions{
+					AuthType:           &authType,
+					MaximumConnections: &maximumConnections,
+					Username:           iscsidsc.NewSecretFromString("user"),
+					Password:           iscsidsc.NewSecretFromString("password"),
+				})),
+			expectedFields: []string{"SecurityFlags", "LoginOptions.MaximumConnections", "LoginOptions.Password"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			backend := &fakeBackend{portalInfos: []iscsidsc.PortalInfo{registered}}
+
+			changed, err := EnsurePortalWithClient(newClient(backend), testCase.request)
+			assert.Equal(t, testCase.expectedChanged, changed)
+
+			if testCase.expectedFields == nil {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				mismatchErr, ok := errors.Cause(err).(*MismatchError)
+				require.True(t, ok)
+				assert.Equal(t, testCase.expectedFields, mismatchErr.Fields)
+				assert.Equal(t, "portal 10.0.0.5:3260 is already registered with different settings: SecurityFlags, "+
+					"LoginOptions.MaximumConnections, LoginOptions.Password", err.Error())
+			}
+
+			if testCase.expectedChanged {
+				assert.Equal(t, []*iscsidsc.Portal{testCase.request.Portal}, backend.added)
+			} else {
+				assert.Empty(t, backend.added)
+			}
+		})
+	}
+}
+
+func TestEnsurePortalWithInvalidRequest(t *testing.T) {
+	changed, err := EnsurePortalWithClient(newClient(&fakeBackend{}), &iscsidsc.AddPortalRequest{})
+	assert.False(t, changed)
+	require.Error(t, err)
+	assert.Equal(t, "portal is required", err.Error())
+}
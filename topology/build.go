@@ -0,0 +1,77 @@
+package topology
+
+import (
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// Fetcher fetches the raw data snapshots are built from.
+// It's satisfied by `*iscsidsc.Client`; other implementations can e.g. serve fixtures.
+type Fetcher interface {
+	ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error)
+	ReportIScsiTargets(forceUpdate bool) ([]string, error)
+	GetIScsiSessionList() ([]iscsidsc.SessionInfo, error)
+	GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error)
+}
+
+type buildConfig struct {
+	clock iscsidsc.Clock
+}
+
+// BuildOption configures `Build`.
+type BuildOption func(config *buildConfig)
+
+// WithClock sets the clock that tells when snapshots are taken.
+// Defaults to `iscsidsc.SystemClock`.
+func WithClock(clock iscsidsc.Clock) BuildOption {
+	return func(config *buildConfig) {
+		config.clock = clock
+	}
+}
+
+// Take builds a snapshot of the current state, using the default client, see `iscsidsc.DefaultClient`.
+func Take() (*Snapshot, error) {
+	return Build(iscsidsc.DefaultClient())
+}
+
+// Build builds a snapshot from the data returned by the fetcher.
+// Sessions that go away while building the snapshot, i.e. for which listing devices fails with
+// `iscsidsc.ErrSessionNotFound`, are left out.
+func Build(fetcher Fetcher, opts ...BuildOption) (*Snapshot, error) {
+	config := &buildConfig{clock: iscsidsc.SystemClock{}}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	takenAt := config.clock.Now()
+
+	portalInfos, err := fetcher.ReportIScsiSendTargetPortals()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list target portals")
+	}
+	targetNames, err := fetcher.ReportIScsiTargets(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list targets")
+	}
+	sessionInfos, err := fetcher.GetIScsiSessionList()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sessions")
+	}
+
+	sessions := make([]*Session, 0, len(sessionInfos))
+	for _, sessionInfo := range sessionInfos {
+		devices, err := fetcher.GetDevicesForIScsiSession(sessionInfo.SessionID)
+		if err != nil {
+			if iscsidsc.HasErrorCode(err, iscsidsc.ErrSessionNotFound) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "unable to list devices for session %v", sessionInfo.SessionID)
+		}
+		sessions = append(sessions, &Session{
+			Info:    sessionInfo,
+			Devices: devices,
+		})
+	}
+
+	return newSnapshot(takenAt, portalInfos, targetNames, sessions), nil
+}
@@ -0,0 +1,481 @@
+package topology
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/names"
+)
+
+// ChangeKind is the kind of a `Change`.
+type ChangeKind int
+
+// The various kinds of changes.
+const (
+	Added ChangeKind = iota
+	Removed
+	Modified
+)
+
+var changeKindNames = []string{"added", "removed", "modified"}
+
+func (kind ChangeKind) String() string {
+	if 0 <= kind && int(kind) < len(changeKindNames) {
+		return changeKindNames[kind]
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(kind))
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (kind ChangeKind) MarshalText() ([]byte, error) {
+	return marshalName(changeKindNames, int(kind), "change kind")
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (kind *ChangeKind) UnmarshalText(text []byte) error {
+	index, err := unmarshalName(changeKindNames, text, "change kind")
+	*kind = ChangeKind(index)
+	return err
+}
+
+// ObjectType is the type of the object a `Change` applies to.
+type ObjectType int
+
+// The various types of objects in snapshots.
+const (
+	PortalObject ObjectType = iota
+	TargetObject
+	SessionObject
+	ConnectionObject
+	DeviceObject
+)
+
+var objectTypeNames = []string{"portal", "target", "session", "connection", "device"}
+
+func (objectType ObjectType) String() string {
+	if 0 <= objectType && int(objectType) < len(objectTypeNames) {
+		return objectTypeNames[objectType]
+	}
+	return fmt.Sprintf("ObjectType(%d)", int(objectType))
+}
+
+// MarshalText implements `encoding.TextMarshaler`.
+func (objectType ObjectType) MarshalText() ([]byte, error) {
+	return marshalName(objectTypeNames, int(objectType), "object type")
+}
+
+// UnmarshalText implements `encoding.TextUnmarshaler`.
+func (objectType *ObjectType) UnmarshalText(text []byte) error {
+	index, err := unmarshalName(objectTypeNames, text, "object type")
+	*objectType = ObjectType(index)
+	return err
+}
+
+func marshalName(names []string, index int, typeName string) ([]byte, error) {
+	if index < 0 || index >= len(names) {
+		return nil, errors.Errorf("unknown %s %d", typeName, index)
+	}
+	return []byte(names[index]), nil
+}
+
+func unmarshalName(names []string, text []byte, typeName string) (int, error) {
+	for index, name := range names {
+		if name == string(text) {
+			return index, nil
+		}
+	}
+	return 0, errors.Errorf("unknown %s %q", typeName, text)
+}
+
+// Change is a difference between two snapshots.
+type Change struct {
+	Kind   ChangeKind
+	Object ObjectType
+	// identifies the object, e.g. "10.0.0.5:3260" for a portal; sessions are identified by their
+	// target and initiator, e.g. "iqn.1991-05.com.microsoft:target (ROOT\ISCSIPRT\0000_0)", connections
+	// by their session and portal, and devices by their session, LUN, interface class and partition number
+	// if any, e.g. "iqn.1991-05.com.microsoft:target (ROOT\ISCSIPRT\0000_0) LUN 0 (GUID_DEVINTERFACE_DISK) partition 2";
+	// devices that would still share a key are told apart by their rank, e.g. "... #2"
+	Key string
+	// only set for modifications
+	Fields []FieldChange `json:",omitempty"`
+}
+
+// FieldChange is the change of one of a modified object's fields. Values are rendered as strings;
+// absent values are empty strings.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Changes is a list of changes; it renders as text with one change per line, and marshals to JSON
+// as a regular slice.
+type Changes []Change
+
+// String renders the change on one line, e.g.
+// `modified target iqn.1991-05.com.microsoft:target: Discovered "true" -> "false"`.
+func (change Change) String() string {
+	line := fmt.Sprintf("%v %v %s", change.Kind, change.Object, change.Key)
+	if len(change.Fields) == 0 {
+		return line
+	}
+	fields := make([]string, len(change.Fields))
+	for i, field := range change.Fields {
+		fields[i] = fmt.Sprintf("%s %q -> %q", field.Field, field.Old, field.New)
+	}
+	return line + ": " + strings.Join(fields, ", ")
+}
+
+func (changes Changes) String() string {
+	var builder strings.Builder
+	for _, change := range changes {
+		builder.WriteString(change.String())
+		builder.WriteByte('\n')
+	}
+	return builder.String()
+}
+
+// Diff returns the changes between two snapshots, in this order: registered portals, targets, then
+// sessions, each followed by the changes to their connections and devices. Objects of the same type are
+// ordered by key.
+// Only portals registered as send target portals are compared; changes to the portals sessions are
+// connected through show up as connection changes.
+// Sessions are matched by target and initiator rather than by ID, since IDs change when sessions are
+// re-established; such changes are reported as modifications of the sessions' "SessionID" field.
+// Added and removed sessions come with their connections and devices as added or removed too.
+// Nil snapshots are treated as empty snapshots.
+func Diff(old, new *Snapshot) Changes {
+	if old == nil {
+		old = &Snapshot{}
+	}
+	if new == nil {
+		new = &Snapshot{}
+	}
+
+	var changes Changes
+	changes = append(changes, diffPortals(old, new)...)
+	changes = append(changes, diffTargets(old, new)...)
+	changes = append(changes, diffSessions(old, new)...)
+	return changes
+}
+
+func diffPortals(old, new *Snapshot) (changes Changes) {
+	registeredPortals := func(snapshot *Snapshot) map[string]*Portal {
+		result := make(map[string]*Portal)
+		for _, portal := range snapshot.Portals {
+			if len(portal.Registrations) != 0 {
+				result[portal.Portal.Key()] = portal
+			}
+		}
+		return result
+	}
+	oldPortals, newPortals := registeredPortals(old), registeredPortals(new)
+
+	for _, key := range unionKeys(oldPortals, newPortals) {
+		oldPortal, newPortal := oldPortals[key], newPortals[key]
+		switch {
+		case oldPortal == nil:
+			changes = append(changes, Change{Kind: Added, Object: PortalObject, Key: key})
+		case newPortal == nil:
+			changes = append(changes, Change{Kind: Removed, Object: PortalObject, Key: key})
+		default:
+			differ := &fieldDiffer{}
+			differ.compare("SymbolicName", oldPortal.Portal.SymbolicName, newPortal.Portal.SymbolicName)
+			diffRegistrations(differ, oldPortal.Registrations, newPortal.Registrations)
+			changes = differ.appendTo(changes, PortalObject, key)
+		}
+	}
+	return
+}
+
+func diffRegistrations(differ *fieldDiffer, old, new []iscsidsc.PortalInfo) {
+	byInitiator := func(registrations []iscsidsc.PortalInfo) map[string]*iscsidsc.PortalInfo {
+		result := make(map[string]*iscsidsc.PortalInfo)
+		for i := range registrations {
+			registration := &registrations[i]
+			result[fmt.Sprintf("Registrations[%s:%d]", registration.InitiatorName, registration.InitiatorPortNumber)] = registration
+		}
+		return result
+	}
+	oldRegistrations, newRegistrations := byInitiator(old), byInitiator(new)
+
+	for _, field := range unionKeys(oldRegistrations, newRegistrations) {
+		oldRegistration, newRegistration := oldRegistrations[field], newRegistrations[field]
+		if oldRegistration == nil || newRegistration == nil {
+			differ.compare(field, describeRegistration(oldRegistration), describeRegistration(newRegistration))
+			continue
+		}
+
+		differ.compare(field+".SecurityFlags", oldRegistration.SecurityFlags, newRegistration.SecurityFlags)
+		oldOpts, newOpts := &oldRegistration.LoginOptions, &newRegistration.LoginOptions
+		differ.compare(field+".LoginOptions.LoginFlags", oldOpts.LoginFlags, newOpts.LoginFlags)
+		differ.compare(field+".LoginOptions.AuthType", oldOpts.AuthType, newOpts.AuthType)
+		differ.compare(field+".LoginOptions.HeaderDigest", oldOpts.HeaderDigest, newOpts.HeaderDigest)
+		differ.compare(field+".LoginOptions.DataDigest", oldOpts.DataDigest, newOpts.DataDigest)
+		differ.compare(field+".LoginOptions.MaximumConnections", oldOpts.MaximumConnections, newOpts.MaximumConnections)
+		differ.compare(field+".LoginOptions.DefaultTime2Wait", oldOpts.DefaultTime2Wait, newOpts.DefaultTime2Wait)
+		differ.compare(field+".LoginOptions.DefaultTime2Retain", oldOpts.DefaultTime2Retain, newOpts.DefaultTime2Retain)
+	}
+}
+
+func describeRegistration(registration *iscsidsc.PortalInfo) string {
+	if registration == nil {
+		return ""
+	}
+	return fmt.Sprintf("SecurityFlags=%v LoginFlags=%v", registration.SecurityFlags, registration.LoginOptions.LoginFlags)
+}
+
+func diffTargets(old, new *Snapshot) (changes Changes) {
+	byName := func(snapshot *Snapshot) map[string]*Target {
+		result := make(map[string]*Target)
+		for _, target := range snapshot.Targets {
+			result[names.Canonical(target.Name)] = target
+		}
+		return result
+	}
+	oldTargets, newTargets := byName(old), byName(new)
+
+	for _, key := range unionKeys(oldTargets, newTargets) {
+		oldTarget, newTarget := oldTargets[key], newTargets[key]
+		switch {
+		case oldTarget == nil:
+			changes = append(changes, Change{Kind: Added, Object: TargetObject, Key: newTarget.Name})
+		case newTarget == nil:
+			changes = append(changes, Change{Kind: Removed, Object: TargetObject, Key: oldTarget.Name})
+		default:
+			differ := &fieldDiffer{}
+			differ.compare("Discovered", oldTarget.Discovered, newTarget.Discovered)
+			changes = differ.appendTo(changes, TargetObject, newTarget.Name)
+		}
+	}
+	return
+}
+
+func diffSessions(old, new *Snapshot) (changes Changes) {
+	byKey := func(snapshot *Snapshot) map[string][]*Session {
+		result := make(map[string][]*Session)
+		for _, session := range snapshot.Sessions {
+			key := sessionKey(session)
+			result[key] = append(result[key], session)
+		}
+		return result
+	}
+	oldSessions, newSessions := byKey(old), byKey(new)
+
+	for _, key := range unionKeys(oldSessions, newSessions) {
+		for i, pair := range pairSessions(oldSessions[key], newSessions[key]) {
+			oldSession, newSession := pair[0], pair[1]
+
+			var displayKey string
+			if newSession != nil {
+				displayKey = sessionDisplayKey(newSession)
+			} else {
+				displayKey = sessionDisplayKey(oldSession)
+			}
+			if i != 0 {
+				displayKey += fmt.Sprintf(" #%d", i+1)
+			}
+
+			switch {
+			case oldSession == nil:
+				changes = append(changes, Change{Kind: Added, Object: SessionObject, Key: displayKey})
+			case newSession == nil:
+				changes = append(changes, Change{Kind: Removed, Object: SessionObject, Key: displayKey})
+			default:
+				differ := &fieldDiffer{}
+				differ.compare("SessionID", oldSession.Info.SessionID, newSession.Info.SessionID)
+				changes = differ.appendTo(changes, SessionObject, displayKey)
+			}
+
+			changes = append(changes, diffConnections(displayKey, oldSession, newSession)...)
+			changes = append(changes, diffDevices(displayKey, oldSession, newSession)...)
+		}
+	}
+	return
+}
+
+// sessionKey is what sessions are matched by.
+func sessionKey(session *Session) string {
+	return names.Canonical(session.TargetName()) + "\x00" + session.Info.InitiatorName
+}
+
+func sessionDisplayKey(session *Session) string {
+	return fmt.Sprintf("%s (%s)", session.TargetName(), session.Info.InitiatorName)
+}
+
+// pairSessions pairs sessions sharing the same key: first those connected through the same portals,
+// then the remaining ones in order. Unpaired sessions are paired with nil.
+func pairSessions(old, new []*Session) (pairs [][2]*Session) {
+	pairedOld := make([]bool, len(old))
+	pairedNew := make([]bool, len(new))
+
+	for i, oldSession := range old {
+		for j, newSession := range new {
+			if !pairedNew[j] && connectionsSignature(oldSession) == connectionsSignature(newSession) {
+				pairs = append(pairs, [2]*Session{oldSession, newSession})
+				pairedOld[i], pairedNew[j] = true, true
+				break
+			}
+		}
+	}
+
+	var remainingOld, remainingNew []*Session
+	for i, oldSession := range old {
+		if !pairedOld[i] {
+			remainingOld = append(remainingOld, oldSession)
+		}
+	}
+	for j, newSession := range new {
+		if !pairedNew[j] {
+			remainingNew = append(remainingNew, newSession)
+		}
+	}
+	for i := 0; i < len(remainingOld) || i < len(remainingNew); i++ {
+		var pair [2]*Session
+		if i < len(remainingOld) {
+			pair[0] = remainingOld[i]
+		}
+		if i < len(remainingNew) {
+			pair[1] = remainingNew[i]
+		}
+		pairs = append(pairs, pair)
+	}
+	return
+}
+
+func connectionsSignature(session *Session) string {
+	portals := connectionPortalKeys(session)
+	sort.Strings(portals)
+	return strings.Join(portals, ",")
+}
+
+func connectionPortalKeys(session *Session) []string {
+	if session == nil {
+		return nil
+	}
+	keys := make([]string, len(session.Info.Connections))
+	for i := range session.Info.Connections {
+		keys[i] = session.Info.Connections[i].TargetPortal().Key()
+	}
+	return keys
+}
+
+// diffConnections compares connections by the portals they go through.
+func diffConnections(sessionDisplayKey string, old, new *Session) (changes Changes) {
+	countByPortal := func(session *Session) map[string]int {
+		result := make(map[string]int)
+		for _, key := range connectionPortalKeys(session) {
+			result[key]++
+		}
+		return result
+	}
+	oldCounts, newCounts := countByPortal(old), countByPortal(new)
+
+	for _, portal := range unionKeys(oldCounts, newCounts) {
+		kind, count := Added, newCounts[portal]-oldCounts[portal]
+		if count < 0 {
+			kind, count = Removed, -count
+		}
+		for i := 0; i < count; i++ {
+			changes = append(changes, Change{Kind: kind, Object: ConnectionObject, Key: sessionDisplayKey + " via " + portal})
+		}
+	}
+	return
+}
+
+func diffDevices(sessionDisplayKey string, old, new *Session) (changes Changes) {
+	byKey := func(session *Session) map[string]*iscsidsc.Device {
+		result := make(map[string]*iscsidsc.Device)
+		if session == nil {
+			return result
+		}
+		// devices are sorted in snapshots, so ranks are stable
+		counts := make(map[string]int)
+		for i := range session.Devices {
+			device := &session.Devices[i]
+			key := fmt.Sprintf("%s LUN %d (%s)", sessionDisplayKey, device.ScsiAddress.Lun, device.DeviceInterfaceTypeName())
+			if partitionNumber := device.StorageDeviceNumber.PartitionNumber; partitionNumber != 0 {
+				key += fmt.Sprintf(" partition %d", partitionNumber)
+			}
+			counts[key]++
+			if count := counts[key]; count > 1 {
+				key += fmt.Sprintf(" #%d", count)
+			}
+			result[key] = device
+		}
+		return result
+	}
+	oldDevices, newDevices := byKey(old), byKey(new)
+
+	for _, key := range unionKeys(oldDevices, newDevices) {
+		oldDevice, newDevice := oldDevices[key], newDevices[key]
+		switch {
+		case oldDevice == nil:
+			changes = append(changes, Change{Kind: Added, Object: DeviceObject, Key: key})
+		case newDevice == nil:
+			changes = append(changes, Change{Kind: Removed, Object: DeviceObject, Key: key})
+		default:
+			differ := &fieldDiffer{}
+			differ.compare("DeviceType", oldDevice.StorageDeviceNumber.DeviceType, newDevice.StorageDeviceNumber.DeviceType)
+			differ.compare("DeviceNumber", oldDevice.StorageDeviceNumber.DeviceNumber, newDevice.StorageDeviceNumber.DeviceNumber)
+			differ.compare("DeviceInterfaceName", oldDevice.DeviceInterfaceName, newDevice.DeviceInterfaceName)
+			differ.compare("LegacyName", oldDevice.LegacyName, newDevice.LegacyName)
+			changes = differ.appendTo(changes, DeviceObject, key)
+		}
+	}
+	return
+}
+
+// fieldDiffer accumulates field changes.
+type fieldDiffer struct {
+	fields []FieldChange
+}
+
+// compare records a field change if the two values render differently; nil pointers render as empty
+// strings, and other pointers as the value they point to.
+func (differ *fieldDiffer) compare(field string, old, new interface{}) {
+	oldValue, newValue := renderValue(old), renderValue(new)
+	if oldValue != newValue {
+		differ.fields = append(differ.fields, FieldChange{Field: field, Old: oldValue, New: newValue})
+	}
+}
+
+// appendTo appends a modification to changes if any field changed.
+func (differ *fieldDiffer) appendTo(changes Changes, objectType ObjectType, key string) Changes {
+	if len(differ.fields) == 0 {
+		return changes
+	}
+	return append(changes, Change{Kind: Modified, Object: objectType, Key: key, Fields: differ.fields})
+}
+
+func renderValue(value interface{}) string {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Ptr {
+		if reflected.IsNil() {
+			return ""
+		}
+		value = reflected.Elem().Interface()
+	}
+	return fmt.Sprint(value)
+}
+
+// unionKeys returns the sorted union of two maps' keys; both maps must have string keys.
+func unionKeys(map1, map2 interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []interface{}{map1, map2} {
+		for _, key := range reflect.ValueOf(m).MapKeys() {
+			if k := key.String(); !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,259 @@
+package topology
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+func TestDiff(t *testing.T) {
+	const session1Key = "iqn.1991-05.com.microsoft:target-1 (ROOT\\ISCSIPRT\\0000_0)"
+
+	for _, testCase := range []struct {
+		name     string
+		mutate   func(fetcher *fakeFetcher)
+		expected Changes
+	}{
+		{
+			name:   "no changes",
+			mutate: func(fetcher *fakeFetcher) {},
+		},
+		{
+			name: "session lost",
+			mutate: func(fetcher *fakeFetcher) {
+				delete(fetcher.devices, session2)
+			},
+			expected: Changes{
+				{Kind: Removed, Object: SessionObject, Key: "iqn.2003-01.org.linux-iscsi:target-2 ()"},
+				{Kind: Removed, Object: ConnectionObject, Key: "iqn.2003-01.org.linux-iscsi:target-2 () via 10.0.0.6:3260"},
+				{Kind: Removed, Object: DeviceObject, Key: "iqn.2003-01.org.linux-iscsi:target-2 () LUN 1 (GUID_DEVINTERFACE_DISK)"},
+			},
+		},
+		{
+			name: "connection dropped",
+			mutate: func(fetcher *fakeFetcher) {
+				fetcher.sessions[1].Connections = fetcher.sessions[1].Connections[:1]
+			},
+			expected: Changes{
+				{Kind: Removed, Object: ConnectionObject, Key: session1Key + " via 10.0.0.5:3260"},
+			},
+		},
+		{
+			name: "portal removed",
+			mutate: func(fetcher *fakeFetcher) {
+				fetcher.portalInfos = fetcher.portalInfos[1:]
+			},
+			expected: Changes{
+				{Kind: Removed, Object: PortalObject, Key: "10.0.0.6:3260"},
+			},
+		},
+		{
+			name: "portal registration modified",
+			mutate: func(fetcher *fakeFetcher) {
+				dataDigest := iscsidsc.DigestTypeCRC32C
+				fetcher.portalInfos[2].SecurityFlags = iscsidsc.SecurityFlagIkeIpsecEnabled
+				fetcher.portalInfos[2].LoginOptions.DataDigest = &dataDigest
+				fetcher.portalInfos[1].InitiatorPortNumber = 2
+			},
+			expected: Changes{
+				{Kind: Modified, Object: PortalObject, Key: "10.0.0.5:3260", Fields: []FieldChange{
+					{Field: "Registrations[:0]", Old: "SecurityFlags=none LoginFlags=none", New: ""},
+					{Field: "Registrations[:1].SecurityFlags", Old: "none", New: "ike-ipsec"},
+					{Field: "Registrations[:1].LoginOptions.DataDigest", Old: "", New: "crc32c"},
+					{Field: "Registrations[:2]", Old: "", New: "SecurityFlags=none LoginFlags=none"},
+				}},
+			},
+		},
+		{
+			name: "new LUN",
+			mutate: func(fetcher *fakeFetcher) {
+				newDisk := fetcher.devices[session1][0]
+				newDisk.ScsiAddress.Lun = 2
+				newDisk.StorageDeviceNumber.DeviceNumber = 3
+				fetcher.devices[session1] = append(fetcher.devices[session1], newDisk)
+			},
+			expected: Changes{
+				{Kind: Added, Object: DeviceObject, Key: session1Key + " LUN 2 (GUID_DEVINTERFACE_DISK)"},
+			},
+		},
+		{
+			name: "new partitions on a LUN",
+			mutate: func(fetcher *fakeFetcher) {
+				for _, partitionNumber := range []uint32{1, 2} {
+					partition := fetcher.devices[session1][0]
+					partition.StorageDeviceNumber.PartitionNumber = partitionNumber
+					fetcher.devices[session1] = append(fetcher.devices[session1], partition)
+				}
+			},
+			expected: Changes{
+				{Kind: Added, Object: DeviceObject, Key: session1Key + " LUN 0 (GUID_DEVINTERFACE_DISK) partition 1"},
+				{Kind: Added, Object: DeviceObject, Key: session1Key + " LUN 0 (GUID_DEVINTERFACE_DISK) partition 2"},
+			},
+		},
+		{
+			name: "indistinguishable devices on a LUN",
+			mutate: func(fetcher *fakeFetcher) {
+				fetcher.devices[session1] = append(fetcher.devices[session1], fetcher.devices[session1][0])
+			},
+			expected: Changes{
+				{Kind: Added, Object: DeviceObject, Key: session1Key + " LUN 0 (GUID_DEVINTERFACE_DISK) #2"},
+			},
+		},
+		{
+			name: "disk renumbered",
+			mutate: func(fetcher *fakeFetcher) {
+				fetcher.devices[session1][0].StorageDeviceNumber.DeviceNumber = 4
+			},
+			expected: Changes{
+				{Kind: Modified, Object: DeviceObject, Key: session1Key + " LUN 0 (GUID_DEVINTERFACE_DISK)", Fields: []FieldChange{
+					{Field: "DeviceNumber", Old: "1", New: "4"},
+				}},
+			},
+		},
+		{
+			name: "session re-established",
+			mutate: func(fetcher *fakeFetcher) {
+				newID := iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000005}
+				fetcher.sessions[1].SessionID = newID
+				fetcher.devices[newID] = fetcher.devices[session1]
+			},
+			expected: Changes{
+				{Kind: Modified, Object: SessionObject, Key: session1Key, Fields: []FieldChange{
+					{Field: "SessionID", Old: "ffffe0008ff4b010-4000013700000001", New: "ffffe0008ff4b010-4000013700000005"},
+				}},
+			},
+		},
+		{
+			name: "target no longer discovered",
+			mutate: func(fetcher *fakeFetcher) {
+				fetcher.targets = fetcher.targets[1:]
+			},
+			expected: Changes{
+				{Kind: Modified, Object: TargetObject, Key: target2, Fields: []FieldChange{
+					{Field: "Discovered", Old: "true", New: "false"},
+				}},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			old := buildFixtureSnapshot(t)
+
+			fetcher := newFixtureFetcher()
+			fetcher.sessions[1].InitiatorName = `ROOT\ISCSIPRT\0000_0`
+			testCase.mutate(fetcher)
+			new, err := Build(fetcher)
+			require.Nil(t, err)
+
+			// let's make the initiator name the same on both sides
+			old.Session(session1).Info.InitiatorName = `ROOT\ISCSIPRT\0000_0`
+
+			assert.Equal(t, testCase.expected, Diff(old, new))
+		})
+	}
+}
+
+func TestDiffWithNilSnapshots(t *testing.T) {
+	snapshot := buildFixtureSnapshot(t)
+
+	assert.Nil(t, Diff(nil, nil))
+
+	added := Diff(nil, snapshot)
+	removed := Diff(snapshot, nil)
+	require.Equal(t, len(added), len(removed))
+	for i := range added {
+		assert.Equal(t, Added, added[i].Kind)
+		assert.Equal(t, Removed, removed[i].Kind)
+		assert.Equal(t, added[i].Key, removed[i].Key)
+	}
+
+	assert.Equal(t, Changes{
+		{Kind: Added, Object: PortalObject, Key: "10.0.0.5:3260"},
+		{Kind: Added, Object: PortalObject, Key: "10.0.0.6:3260"},
+		{Kind: Added, Object: TargetObject, Key: target1},
+		{Kind: Added, Object: TargetObject, Key: target2},
+		{Kind: Added, Object: TargetObject, Key: "IQN.2003-01.org.linux-iscsi:TARGET-3"},
+		{Kind: Added, Object: SessionObject, Key: "iqn.1991-05.com.microsoft:target-1 ()"},
+		{Kind: Added, Object: ConnectionObject, Key: "iqn.1991-05.com.microsoft:target-1 () via 10.0.0.5:3260"},
+		{Kind: Added, Object: ConnectionObject, Key: "iqn.1991-05.com.microsoft:target-1 () via 10.0.0.5:3260"},
+		{Kind: Added, Object: DeviceObject, Key: "iqn.1991-05.com.microsoft:target-1 () LUN 0 (GUID_DEVINTERFACE_DISK)"},
+		{Kind: Added, Object: DeviceObject, Key: "iqn.1991-05.com.microsoft:target-1 () LUN 1 (GUID_DEVINTERFACE_DISK)"},
+		{Kind: Added, Object: SessionObject, Key: "iqn.2003-01.org.linux-iscsi:target-2 ()"},
+		{Kind: Added, Object: ConnectionObject, Key: "iqn.2003-01.org.linux-iscsi:target-2 () via 10.0.0.6:3260"},
+		{Kind: Added, Object: DeviceObject, Key: "iqn.2003-01.org.linux-iscsi:target-2 () LUN 1 (GUID_DEVINTERFACE_DISK)"},
+		{Kind: Added, Object: SessionObject, Key: "IQN.2003-01.org.linux-iscsi:TARGET-3 ()"},
+		{Kind: Added, Object: ConnectionObject, Key: "IQN.2003-01.org.linux-iscsi:TARGET-3 () via 10.0.0.7:3260"},
+	}, added)
+}
+
+func TestDiffWithSeveralSessionsToTheSameTarget(t *testing.T) {
+	connection := func(address string) iscsidsc.ConnectionInfo {
+		return iscsidsc.ConnectionInfo{TargetAddress: address, TargetSocket: 3260}
+	}
+	snapshot := func(sessionInfos ...iscsidsc.SessionInfo) *Snapshot {
+		sessions := make([]*Session, len(sessionInfos))
+		for i, sessionInfo := range sessionInfos {
+			sessions[i] = &Session{Info: sessionInfo}
+		}
+		return newSnapshot(fixtureTime.Now(), nil, nil, sessions)
+	}
+
+	old := snapshot(
+		iscsidsc.SessionInfo{SessionID: session1, TargetNodeName: target1, Connections: []iscsidsc.ConnectionInfo{connection("10.0.0.5")}},
+		iscsidsc.SessionInfo{SessionID: session2, TargetNodeName: target1, Connections: []iscsidsc.ConnectionInfo{connection("10.0.0.6")}},
+	)
+	// the session through 10.0.0.5 went away, and the one through 10.0.0.6 got re-established
+	new := snapshot(
+		iscsidsc.SessionInfo{SessionID: session3, TargetNodeName: target1, Connections: []iscsidsc.ConnectionInfo{connection("10.0.0.6")}},
+	)
+
+	assert.Equal(t, Changes{
+		{Kind: Modified, Object: SessionObject, Key: target1 + " ()", Fields: []FieldChange{
+			{Field: "SessionID", Old: session2.String(), New: session3.String()},
+		}},
+		{Kind: Removed, Object: SessionObject, Key: target1 + " () #2"},
+		{Kind: Removed, Object: ConnectionObject, Key: target1 + " () #2 via 10.0.0.5:3260"},
+	}, Diff(old, new))
+}
+
+func TestChangesRendering(t *testing.T) {
+	changes := Changes{
+		{Kind: Removed, Object: SessionObject, Key: "iqn.1991-05.com.microsoft:target (ROOT\\ISCSIPRT\\0000_0)"},
+		{Kind: Modified, Object: TargetObject, Key: "iqn.1991-05.com.microsoft:target", Fields: []FieldChange{
+			{Field: "Discovered", Old: "true", New: "false"},
+		}},
+	}
+
+	t.Run("as text", func(t *testing.T) {
+		assert.Equal(t, `removed session iqn.1991-05.com.microsoft:target (ROOT\ISCSIPRT\0000_0)
+modified target iqn.1991-05.com.microsoft:target: Discovered "true" -> "false"
+`, changes.String())
+	})
+
+	t.Run("as JSON", func(t *testing.T) {
+		marshalled, err := json.Marshal(changes)
+		require.Nil(t, err)
+		assert.Equal(t, `[{"Kind":"removed","Object":"session","Key":"iqn.1991-05.com.microsoft:target (ROOT\\ISCSIPRT\\0000_0)"},`+
+			`{"Kind":"modified","Object":"target","Key":"iqn.1991-05.com.microsoft:target","Fields":[{"Field":"Discovered","Old":"true","New":"false"}]}]`,
+			string(marshalled))
+
+		var unmarshalled Changes
+		require.Nil(t, json.Unmarshal(marshalled, &unmarshalled))
+		assert.Equal(t, changes, unmarshalled)
+	})
+
+	t.Run("unknown values", func(t *testing.T) {
+		assert.Equal(t, "ChangeKind(12)", ChangeKind(12).String())
+		_, err := json.Marshal(Change{Object: ObjectType(12)})
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "unknown object type 12")
+		}
+		var change Change
+		err = json.Unmarshal([]byte(`{"Kind":"renamed"}`), &change)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), `unknown change kind "renamed"`)
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package topology
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/names"
+)
+
+// Snapshot is a consistent view of the initiator's portals, targets, sessions and devices, and of
+// how they relate to each other.
+// Snapshots marshal to and from JSON; all their slices are sorted so that the same state always
+// results in the same JSON.
+type Snapshot struct {
+	TakenAt  time.Time
+	Portals  []*Portal
+	Targets  []*Target
+	Sessions []*Session
+
+	portalsByKey  map[string]*Portal
+	targetsByName map[string]*Target
+	sessionsByID  map[iscsidsc.SessionID]*Session
+	disksByNumber map[uint32]*Disk
+}
+
+// Portal is a target portal that is registered as a send target portal, and/or that sessions are
+// connected through.
+// Note that registered portals and connections are only matched by address: a portal registered with a
+// DNS name is distinct from the IP address the connections to it use.
+type Portal struct {
+	Portal iscsidsc.Portal
+	// one per initiator and initiator port the portal is registered with, if any;
+	// credentials are left out of snapshots
+	Registrations []iscsidsc.PortalInfo
+	// the sessions with at least one connection through the portal
+	SessionIDs []iscsidsc.SessionID
+}
+
+// Target is a target that is either known to the initiator through discovery, or that has sessions.
+type Target struct {
+	Name string
+	// true iff the target was reported by discovery
+	Discovered bool
+	SessionIDs []iscsidsc.SessionID
+}
+
+// Session is a session, along with its devices.
+type Session struct {
+	Info    iscsidsc.SessionInfo
+	Devices []iscsidsc.Device
+}
+
+// Disk links a disk to the targets and portals it's reached through.
+// Disks are not part of the snapshots' JSON representations, as they're derived from sessions.
+type Disk struct {
+	Number uint32
+	// there is one path per session the disk is reported on, i.e. more than one when using MPIO
+	Paths []*DiskPath
+}
+
+// DiskPath is one of the ways to reach a disk.
+type DiskPath struct {
+	Device  *iscsidsc.Device
+	Session *Session
+	Target  *Target
+	Portals []*Portal
+}
+
+// Target returns the target with the given name, if any. Names are compared once canonicalized,
+// see `names.Canonical`.
+func (s *Snapshot) Target(name string) *Target {
+	return s.targetsByName[names.Canonical(name)]
+}
+
+// Portal returns the given portal, if any. Portals are compared as per `iscsidsc.Portal.Equal`.
+func (s *Snapshot) Portal(portal *iscsidsc.Portal) *Portal {
+	return s.portalsByKey[portal.Key()]
+}
+
+// Session returns the session with the given ID, if any.
+func (s *Snapshot) Session(id iscsidsc.SessionID) *Session {
+	return s.sessionsByID[id]
+}
+
+// Disk returns the disk with the given number, if any, see `iscsidsc.Device.PhysicalDrivePath`.
+func (s *Snapshot) Disk(number uint32) *Disk {
+	return s.disksByNumber[number]
+}
+
+// Disks returns all the disks, sorted by number.
+func (s *Snapshot) Disks() []*Disk {
+	disks := make([]*Disk, 0, len(s.disksByNumber))
+	for _, disk := range s.disksByNumber {
+		disks = append(disks, disk)
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		return disks[i].Number < disks[j].Number
+	})
+	return disks
+}
+
+// SessionPortals returns the portals the given session is connected through.
+func (s *Snapshot) SessionPortals(session *Session) []*Portal {
+	var portals []*Portal
+	seen := make(map[*Portal]bool)
+	for i := range session.Info.Connections {
+		portal := s.portalsByKey[session.Info.Connections[i].TargetPortal().Key()]
+		if portal != nil && !seen[portal] {
+			portals = append(portals, portal)
+			seen[portal] = true
+		}
+	}
+	return portals
+}
+
+// TargetName returns the name of the target the session is logged in to.
+func (session *Session) TargetName() string {
+	if session.Info.TargetNodeName != "" {
+		return session.Info.TargetNodeName
+	}
+	return session.Info.TargetName
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`, to rebuild the snapshot's indexes.
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	type plainSnapshot Snapshot
+	if err := json.Unmarshal(data, (*plainSnapshot)(s)); err != nil {
+		return err
+	}
+	s.index()
+	return nil
+}
+
+func newSnapshot(takenAt time.Time, portalInfos []iscsidsc.PortalInfo, targetNames []string, sessions []*Session) *Snapshot {
+	snapshot := &Snapshot{
+		TakenAt:  takenAt,
+		Sessions: sessions,
+	}
+
+	portalsByKey := make(map[string]*Portal)
+	getPortal := func(portal *iscsidsc.Portal) *Portal {
+		key := portal.Key()
+		if result, present := portalsByKey[key]; present {
+			return result
+		}
+		result := &Portal{Portal: *portal}
+		portalsByKey[key] = result
+		snapshot.Portals = append(snapshot.Portals, result)
+		return result
+	}
+	for _, portalInfo := range portalInfos {
+		portal := getPortal(&portalInfo.Portal)
+		portalInfo.LoginOptions.Username = nil
+		portalInfo.LoginOptions.Password = nil
+		portal.Registrations = append(portal.Registrations, portalInfo)
+	}
+
+	targetsByName := make(map[string]*Target)
+	getTarget := func(name string) *Target {
+		key := names.Canonical(name)
+		if result, present := targetsByName[key]; present {
+			return result
+		}
+		result := &Target{Name: name}
+		targetsByName[key] = result
+		snapshot.Targets = append(snapshot.Targets, result)
+		return result
+	}
+	for _, name := range targetNames {
+		getTarget(name).Discovered = true
+	}
+
+	for _, session := range sessions {
+		id := session.Info.SessionID
+
+		target := getTarget(session.TargetName())
+		target.SessionIDs = append(target.SessionIDs, id)
+
+		seen := make(map[*Portal]bool)
+		for i := range session.Info.Connections {
+			portal := getPortal(session.Info.Connections[i].TargetPortal())
+			if !seen[portal] {
+				portal.SessionIDs = append(portal.SessionIDs, id)
+				seen[portal] = true
+			}
+		}
+	}
+
+	snapshot.sort()
+	snapshot.index()
+	return snapshot
+}
+
+// sort sorts all of the snapshot's slices, to make them deterministic.
+func (s *Snapshot) sort() {
+	sort.Slice(s.Portals, func(i, j int) bool {
+		return s.Portals[i].Portal.Key() < s.Portals[j].Portal.Key()
+	})
+	sort.Slice(s.Targets, func(i, j int) bool {
+		return names.Canonical(s.Targets[i].Name) < names.Canonical(s.Targets[j].Name)
+	})
+	sort.Slice(s.Sessions, func(i, j int) bool {
+		return s.Sessions[i].Info.SessionID.String() < s.Sessions[j].Info.SessionID.String()
+	})
+
+	for _, portal := range s.Portals {
+		sortRegistrations(portal.Registrations)
+		sortSessionIDs(portal.SessionIDs)
+	}
+	for _, target := range s.Targets {
+		sortSessionIDs(target.SessionIDs)
+	}
+	for _, session := range s.Sessions {
+		sortConnections(session.Info.Connections)
+		sortDevices(session.Devices)
+	}
+}
+
+func sortRegistrations(registrations []iscsidsc.PortalInfo) {
+	sort.Slice(registrations, func(i, j int) bool {
+		a, b := &registrations[i], &registrations[j]
+		if a.InitiatorName != b.InitiatorName {
+			return a.InitiatorName < b.InitiatorName
+		}
+		if a.InitiatorPortNumber != b.InitiatorPortNumber {
+			return a.InitiatorPortNumber < b.InitiatorPortNumber
+		}
+		// different spellings of the same portal, see `iscsidsc.DedupePortalInfos`
+		return a.Portal.String() < b.Portal.String()
+	})
+}
+
+func sortConnections(connections []iscsidsc.ConnectionInfo) {
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ConnectionID.String() < connections[j].ConnectionID.String()
+	})
+}
+
+func sortDevices(devices []iscsidsc.Device) {
+	sort.Slice(devices, func(i, j int) bool {
+		a, b := &devices[i], &devices[j]
+		if a.ScsiAddress.Lun != b.ScsiAddress.Lun {
+			return a.ScsiAddress.Lun < b.ScsiAddress.Lun
+		}
+		if a.StorageDeviceNumber.DeviceNumber != b.StorageDeviceNumber.DeviceNumber {
+			return a.StorageDeviceNumber.DeviceNumber < b.StorageDeviceNumber.DeviceNumber
+		}
+		if a.StorageDeviceNumber.PartitionNumber != b.StorageDeviceNumber.PartitionNumber {
+			return a.StorageDeviceNumber.PartitionNumber < b.StorageDeviceNumber.PartitionNumber
+		}
+		return a.DeviceInterfaceName < b.DeviceInterfaceName
+	})
+}
+
+func sortSessionIDs(ids []iscsidsc.SessionID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+}
+
+// index (re)builds the snapshot's indexes.
+func (s *Snapshot) index() {
+	s.portalsByKey = make(map[string]*Portal, len(s.Portals))
+	for _, portal := range s.Portals {
+		s.portalsByKey[portal.Portal.Key()] = portal
+	}
+
+	s.targetsByName = make(map[string]*Target, len(s.Targets))
+	for _, target := range s.Targets {
+		s.targetsByName[names.Canonical(target.Name)] = target
+	}
+
+	s.sessionsByID = make(map[iscsidsc.SessionID]*Session, len(s.Sessions))
+	s.disksByNumber = make(map[uint32]*Disk)
+	for _, session := range s.Sessions {
+		s.sessionsByID[session.Info.SessionID] = session
+
+		for i := range session.Devices {
+			device := &session.Devices[i]
+			if !device.IsDisk() {
+				continue
+			}
+
+			number := device.StorageDeviceNumber.DeviceNumber
+			disk, present := s.disksByNumber[number]
+			if !present {
+				disk = &Disk{Number: number}
+				s.disksByNumber[number] = disk
+			}
+			disk.Paths = append(disk.Paths, &DiskPath{
+				Device:  device,
+				Session: session,
+				Target:  s.Target(session.TargetName()),
+				Portals: s.SessionPortals(session),
+			})
+		}
+	}
+}
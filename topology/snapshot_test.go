@@ -0,0 +1,309 @@
+package topology
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// fakeFetcher serves fixtures.
+type fakeFetcher struct {
+	portalInfos []iscsidsc.PortalInfo
+	targets     []string
+	sessions    []iscsidsc.SessionInfo
+	devices     map[iscsidsc.SessionID][]iscsidsc.Device
+	errs        map[string]error
+}
+
+func (f *fakeFetcher) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	return f.portalInfos, f.errs["ReportIScsiSendTargetPortals"]
+}
+
+func (f *fakeFetcher) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	return f.targets, f.errs["ReportIScsiTargets"]
+}
+
+func (f *fakeFetcher) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return f.sessions, f.errs["GetIScsiSessionList"]
+}
+
+func (f *fakeFetcher) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	if err := f.errs["GetDevicesForIScsiSession"]; err != nil {
+		return nil, err
+	}
+	devices, present := f.devices[id]
+	if !present {
+		return nil, iscsidsc.NewWinAPICallError("GetDevicesForIScsiSessionW", uintptr(iscsidsc.ErrSessionNotFound))
+	}
+	return devices, nil
+}
+
+var _ Fetcher = &iscsidsc.Client{}
+
+const (
+	target1 = "iqn.1991-05.com.microsoft:target-1"
+	target2 = "iqn.2003-01.org.linux-iscsi:target-2"
+	target3 = "iqn.2003-01.org.linux-iscsi:target-3"
+)
+
+var (
+	session1 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000001}
+	session2 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002}
+	session3 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000003}
+	// gone by the time we list its devices
+	session4 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000004}
+)
+
+func newFixtureFetcher() *fakeFetcher {
+	socket := uint16(3260)
+	authType := iscsidsc.CHAPAuthType
+
+	disk := func(number uint32, lun uint8) iscsidsc.Device {
+		return iscsidsc.Device{
+			ScsiAddress:         iscsidsc.ScsiAddress{Lun: lun},
+			DeviceInterfaceType: uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b"),
+			StorageDeviceNumber: iscsidsc.StorageDeviceNumber{DeviceType: iscsidsc.DeviceTypeDisk, DeviceNumber: number},
+		}
+	}
+	connection := func(address string) iscsidsc.ConnectionInfo {
+		return iscsidsc.ConnectionInfo{TargetAddress: address, TargetSocket: 3260}
+	}
+
+	return &fakeFetcher{
+		portalInfos: []iscsidsc.PortalInfo{
+			{
+				Portal: iscsidsc.Portal{Address: "10.0.0.6", Socket: &socket},
+				LoginOptions: iscsidsc.LoginOptions{
+					AuthType: &authType,
+					Username: iscsidsc.NewSecretFromString("username"),
+				},
+			},
+			{Portal: iscsidsc.Portal{Address: "10.0.0.5"}},
+			{Portal: iscsidsc.Portal{Address: "10.0.0.5", Socket: &socket}, InitiatorPortNumber: 1},
+		},
+		// target 3 isn't discovered, but has a session
+		targets: []string{target2, target1},
+		sessions: []iscsidsc.SessionInfo{
+			{SessionID: session2, TargetNodeName: target2, Connections: []iscsidsc.ConnectionInfo{connection("10.0.0.6")}},
+			{SessionID: session1, TargetNodeName: target1, Connections: []iscsidsc.ConnectionInfo{connection("10.0.0.5"), connection("10.0.0.5")}},
+			{SessionID: session3, TargetName: "IQN.2003-01.org.linux-iscsi:TARGET-3", Connections: []iscsidsc.ConnectionInfo{connection("10.0.0.7")}},
+			{SessionID: session4, TargetNodeName: target1},
+		},
+		devices: map[iscsidsc.SessionID][]iscsidsc.Device{
+			session1: {disk(1, 0), disk(2, 1)},
+			// MPIO: disk 2 is reachable through sessions 1 and 2
+			session2: {disk(2, 1)},
+			session3: nil,
+		},
+	}
+}
+
+func buildFixtureSnapshot(t *testing.T) *Snapshot {
+	snapshot, err := Build(newFixtureFetcher(), WithClock(fixtureTime))
+	require.Nil(t, err)
+	return snapshot
+}
+
+func TestBuild(t *testing.T) {
+	snapshot := buildFixtureSnapshot(t)
+
+	assert.Equal(t, fixtureTime.Now(), snapshot.TakenAt)
+
+	t.Run("portals", func(t *testing.T) {
+		require.Equal(t, 3, len(snapshot.Portals))
+
+		assert.Equal(t, "10.0.0.5", snapshot.Portals[0].Portal.Address)
+		assert.Equal(t, 2, len(snapshot.Portals[0].Registrations))
+		assert.Equal(t, []iscsidsc.SessionID{session1}, snapshot.Portals[0].SessionIDs)
+
+		assert.Equal(t, "10.0.0.6", snapshot.Portals[1].Portal.Address)
+		require.Equal(t, 1, len(snapshot.Portals[1].Registrations))
+		// credentials are left out
+		assert.Nil(t, snapshot.Portals[1].Registrations[0].LoginOptions.Username)
+		assert.Equal(t, iscsidsc.CHAPAuthType, *snapshot.Portals[1].Registrations[0].LoginOptions.AuthType)
+		assert.Equal(t, []iscsidsc.SessionID{session2}, snapshot.Portals[1].SessionIDs)
+
+		// only known through session 3's connection
+		assert.Equal(t, "10.0.0.7", snapshot.Portals[2].Portal.Address)
+		assert.Equal(t, 0, len(snapshot.Portals[2].Registrations))
+		assert.Equal(t, []iscsidsc.SessionID{session3}, snapshot.Portals[2].SessionIDs)
+	})
+
+	t.Run("targets", func(t *testing.T) {
+		assert.Equal(t, []*Target{
+			{Name: target1, Discovered: true, SessionIDs: []iscsidsc.SessionID{session1}},
+			{Name: target2, Discovered: true, SessionIDs: []iscsidsc.SessionID{session2}},
+			{Name: "IQN.2003-01.org.linux-iscsi:TARGET-3", SessionIDs: []iscsidsc.SessionID{session3}},
+		}, snapshot.Targets)
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		require.Equal(t, 3, len(snapshot.Sessions))
+		for i, expectedID := range []iscsidsc.SessionID{session1, session2, session3} {
+			assert.Equal(t, expectedID, snapshot.Sessions[i].Info.SessionID)
+		}
+		assert.Equal(t, 2, len(snapshot.Sessions[0].Devices))
+	})
+}
+
+func TestLookups(t *testing.T) {
+	snapshot := buildFixtureSnapshot(t)
+
+	t.Run("by target name", func(t *testing.T) {
+		assert.Equal(t, target1, snapshot.Target(target1).Name)
+		assert.Equal(t, snapshot.Targets[2], snapshot.Target(target3))
+		assert.Nil(t, snapshot.Target("iqn.2003-01.org.linux-iscsi:unknown"))
+	})
+
+	t.Run("by portal", func(t *testing.T) {
+		portal, err := iscsidsc.ParsePortal("10.0.0.6:3260")
+		require.Nil(t, err)
+		assert.Equal(t, snapshot.Portals[1], snapshot.Portal(portal))
+
+		portal, err = iscsidsc.ParsePortal("10.0.0.6:3261")
+		require.Nil(t, err)
+		assert.Nil(t, snapshot.Portal(portal))
+	})
+
+	t.Run("by session ID", func(t *testing.T) {
+		assert.Equal(t, snapshot.Sessions[1], snapshot.Session(session2))
+		assert.Nil(t, snapshot.Session(session4))
+	})
+
+	t.Run("by disk number", func(t *testing.T) {
+		disk := snapshot.Disk(1)
+		require.NotNil(t, disk)
+		require.Equal(t, 1, len(disk.Paths))
+		assert.Equal(t, snapshot.Session(session1), disk.Paths[0].Session)
+		assert.Equal(t, snapshot.Target(target1), disk.Paths[0].Target)
+		assert.Equal(t, []*Portal{snapshot.Portals[0]}, disk.Paths[0].Portals)
+
+		disk = snapshot.Disk(2)
+		require.NotNil(t, disk)
+		require.Equal(t, 2, len(disk.Paths))
+		assert.Equal(t, snapshot.Target(target1), disk.Paths[0].Target)
+		assert.Equal(t, snapshot.Target(target2), disk.Paths[1].Target)
+		assert.Equal(t, uint8(1), disk.Paths[1].Device.ScsiAddress.Lun)
+
+		assert.Nil(t, snapshot.Disk(3))
+
+		disks := snapshot.Disks()
+		require.Equal(t, 2, len(disks))
+		assert.Equal(t, uint32(1), disks[0].Number)
+		assert.Equal(t, uint32(2), disks[1].Number)
+	})
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	snapshot := buildFixtureSnapshot(t)
+
+	marshalled, err := json.Marshal(snapshot)
+	require.Nil(t, err)
+	assert.Contains(t, string(marshalled), `"SessionID":"ffffe0008ff4b010-4000013700000001"`)
+	assert.NotContains(t, string(marshalled), "username")
+
+	var unmarshalled Snapshot
+	require.Nil(t, json.Unmarshal(marshalled, &unmarshalled))
+
+	assert.True(t, snapshot.TakenAt.Equal(unmarshalled.TakenAt))
+	assert.Equal(t, snapshot.Portals, unmarshalled.Portals)
+	assert.Equal(t, snapshot.Targets, unmarshalled.Targets)
+	assert.Equal(t, snapshot.Sessions, unmarshalled.Sessions)
+
+	// indexes are rebuilt
+	assert.Equal(t, unmarshalled.Sessions[0], unmarshalled.Session(session1))
+	require.NotNil(t, unmarshalled.Disk(2))
+	assert.Equal(t, 2, len(unmarshalled.Disk(2).Paths))
+
+	// and it's deterministic
+	remarshalled, err := json.Marshal(&unmarshalled)
+	require.Nil(t, err)
+	assert.Equal(t, string(marshalled), string(remarshalled))
+}
+
+func TestSnapshotJSONIsDeterministic(t *testing.T) {
+	// the same state, as listed by Windows in different orders
+	newFetcher := func(reversed bool) *fakeFetcher {
+		fetcher := newFixtureFetcher()
+		fetcher.portalInfos = append(fetcher.portalInfos, iscsidsc.PortalInfo{Portal: iscsidsc.Portal{Address: "10.0.0.5"}, InitiatorName: "a"})
+		for i := range fetcher.sessions[1].Connections {
+			fetcher.sessions[1].Connections[i].ConnectionID.AdapterSpecific = uint64(i + 1)
+		}
+		if reversed {
+			reversePortalInfos(fetcher.portalInfos)
+			reverseConnections(fetcher.sessions[1].Connections)
+			reverseDevices(fetcher.devices[session1])
+		}
+		return fetcher
+	}
+
+	snapshot, err := Build(newFetcher(false), WithClock(fixtureTime))
+	require.Nil(t, err)
+	marshalled, err := json.Marshal(snapshot)
+	require.Nil(t, err)
+
+	reversedSnapshot, err := Build(newFetcher(true), WithClock(fixtureTime))
+	require.Nil(t, err)
+	reversedMarshalled, err := json.Marshal(reversedSnapshot)
+	require.Nil(t, err)
+
+	assert.Equal(t, string(marshalled), string(reversedMarshalled))
+}
+
+func reversePortalInfos(s []iscsidsc.PortalInfo) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func reverseConnections(s []iscsidsc.ConnectionInfo) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func reverseDevices(s []iscsidsc.Device) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for procName, expectedError := range map[string]string{
+		"ReportIScsiSendTargetPortals": "unable to list target portals",
+		"ReportIScsiTargets":           "unable to list targets",
+		"GetIScsiSessionList":          "unable to list sessions",
+		"GetDevicesForIScsiSession":    "unable to list devices for session ffffe0008ff4b010-4000013700000002",
+	} {
+		t.Run(procName, func(t *testing.T) {
+			fetcher := newFixtureFetcher()
+			fetcher.errs = map[string]error{procName: errors.New("dummy error")}
+
+			snapshot, err := Build(fetcher)
+
+			assert.Nil(t, snapshot)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, expectedError+": dummy error", err.Error())
+			}
+		})
+	}
+}
+
+// fixedClock always tells the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func (c fixedClock) After(d time.Duration) <-chan time.Time {
+	panic("not expected to wait")
+}
+
+var fixtureTime = fixedClock(time.Date(2019, 5, 12, 10, 0, 0, 0, time.UTC))
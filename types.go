@@ -59,6 +59,7 @@ const (
 
 // LoginOptions maps to the `ISCSI_LOGIN_OPTIONS` C++ struct.
 // All pointer fields are optional and can be left nil.
+// Credentials are `Secret`s, so that they don't leak when logging login options.
 // see https://docs.microsoft.com/en-us/windows/desktop/api/iscsidsc/ns-iscsidsc-iscsi_login_options
 type LoginOptions struct {
 	LoginFlags         LoginFlags
@@ -68,8 +69,8 @@ type LoginOptions struct {
 	MaximumConnections *uint32
 	DefaultTime2Wait   *uint32
 	DefaultTime2Retain *uint32
-	Username           *string
-	Password           *string
+	Username           *Secret
+	Password           *Secret
 }
 
 // SecurityFlags are one of`AddIScsiSendTargetPortalW`'s arguments.
@@ -159,7 +160,7 @@ type ScsiAddress struct {
 // StorageDeviceNumber maps to the `STORAGE_DEVICE_NUMBER` C++ struct.
 // see https://docs.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-_storage_device_number
 type StorageDeviceNumber struct {
-	DeviceType      uint32
+	DeviceType      DeviceType
 	DeviceNumber    uint32
 	PartitionNumber uint32
 }
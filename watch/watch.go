@@ -0,0 +1,227 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+	"github.com/wk8/go-win-iscsidsc/topology"
+)
+
+// EventType is the type of an `Event`.
+type EventType int
+
+// The various types of events.
+const (
+	SessionAdded EventType = iota
+	SessionRemoved
+	ConnectionAdded
+	ConnectionRemoved
+	DeviceAppeared
+	DeviceDisappeared
+	// TargetDiscovered is published when discovery reports a target it didn't report before.
+	TargetDiscovered
+)
+
+var eventTypeNames = []string{
+	"SessionAdded",
+	"SessionRemoved",
+	"ConnectionAdded",
+	"ConnectionRemoved",
+	"DeviceAppeared",
+	"DeviceDisappeared",
+	"TargetDiscovered",
+}
+
+func (eventType EventType) String() string {
+	if 0 <= eventType && int(eventType) < len(eventTypeNames) {
+		return eventTypeNames[eventType]
+	}
+	return fmt.Sprintf("EventType(%d)", int(eventType))
+}
+
+// Event is a change detected between two polls.
+type Event struct {
+	Type EventType
+	// identifies the object the event is about, see `topology.Change`'s Key field
+	Key string
+	// when the poll that detected the event started, as per the watcher's clock
+	Time time.Time
+	// the snapshot the event was detected in; objects that went away can be found in the
+	// previous one
+	Snapshot         *topology.Snapshot
+	PreviousSnapshot *topology.Snapshot
+}
+
+// DefaultInterval is how often watchers poll, unless configured otherwise.
+const DefaultInterval = 10 * time.Second
+
+// Watcher polls the initiator's state, and publishes events when it changes.
+type Watcher struct {
+	// Interval is how long to wait between two polls; defaults to `DefaultInterval`.
+	Interval time.Duration
+	// Jitter randomizes each interval by up to that fraction of its value, e.g. 0.1 for +/- 10%.
+	Jitter float64
+	// Rand returns random numbers in [0.0, 1.0) for the jitter; defaults to `rand.Float64`.
+	Rand func() float64
+	// Fetcher defaults to the default client, see `iscsidsc.DefaultClient`.
+	Fetcher topology.Fetcher
+	// Clock defaults to `iscsidsc.SystemClock`; it also tells when snapshots are taken.
+	Clock iscsidsc.Clock
+	// OnError, if not nil, is called when a poll fails; the watcher then tries again at the
+	// next interval.
+	OnError func(err error)
+}
+
+// Watch starts polling, and returns the channel events are published on.
+// The first successful poll only serves as a baseline, and doesn't result in any event.
+// Polling stops when ctx is done, at which point the channel gets closed; events are published
+// synchronously, so the channel needs to be drained until then.
+// Calls to Windows' API can't be interrupted, so a poll in progress when ctx gets done is abandoned,
+// and finishes in the background.
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go w.run(ctx, events)
+	return events
+}
+
+func (w *Watcher) run(ctx context.Context, events chan<- Event) {
+	defer close(events)
+
+	fetcher := w.Fetcher
+	if fetcher == nil {
+		fetcher = iscsidsc.DefaultClient()
+	}
+	clock := w.Clock
+	if clock == nil {
+		clock = iscsidsc.SystemClock{}
+	}
+
+	var previous *topology.Snapshot
+	for {
+		snapshot, done, err := poll(ctx, fetcher, clock)
+		if done {
+			return
+		}
+
+		if err != nil {
+			if w.OnError != nil {
+				w.OnError(err)
+			}
+		} else {
+			if previous != nil {
+				for _, event := range eventsFromChanges(topology.Diff(previous, snapshot), previous, snapshot) {
+					event.Time = snapshot.TakenAt
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			previous = snapshot
+		}
+
+		select {
+		case <-clock.After(w.interval()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll builds a snapshot in the background, so that a hung call doesn't block shutdown; done is true
+// iff ctx got done before the snapshot was built.
+func poll(ctx context.Context, fetcher topology.Fetcher, clock iscsidsc.Clock) (snapshot *topology.Snapshot, done bool, err error) {
+	type result struct {
+		snapshot *topology.Snapshot
+		err      error
+	}
+	// buffered, so that abandoned polls don't block forever
+	results := make(chan result, 1)
+	go func() {
+		snapshot, err := topology.Build(fetcher, topology.WithClock(clock))
+		results <- result{snapshot: snapshot, err: err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.snapshot, false, r.err
+	case <-ctx.Done():
+		return nil, true, nil
+	}
+}
+
+// interval returns how long to wait before the next poll.
+func (w *Watcher) interval() time.Duration {
+	interval := float64(w.Interval)
+	if interval <= 0 {
+		interval = float64(DefaultInterval)
+	}
+	if w.Jitter > 0 {
+		random := w.Rand
+		if random == nil {
+			random = rand.Float64
+		}
+		interval += interval * w.Jitter * (2*random() - 1)
+	}
+	return time.Duration(interval)
+}
+
+// eventsFromChanges converts the changes watchers care about to events.
+func eventsFromChanges(changes topology.Changes, previous, snapshot *topology.Snapshot) (events []Event) {
+	for _, change := range changes {
+		eventType, ok := eventTypeFromChange(change, snapshot)
+		if ok {
+			events = append(events, Event{
+				Type:             eventType,
+				Key:              change.Key,
+				Snapshot:         snapshot,
+				PreviousSnapshot: previous,
+			})
+		}
+	}
+	return
+}
+
+func eventTypeFromChange(change topology.Change, snapshot *topology.Snapshot) (EventType, bool) {
+	switch change.Object {
+	case topology.SessionObject:
+		switch change.Kind {
+		case topology.Added:
+			return SessionAdded, true
+		case topology.Removed:
+			return SessionRemoved, true
+		}
+	case topology.ConnectionObject:
+		switch change.Kind {
+		case topology.Added:
+			return ConnectionAdded, true
+		case topology.Removed:
+			return ConnectionRemoved, true
+		}
+	case topology.DeviceObject:
+		switch change.Kind {
+		case topology.Added:
+			return DeviceAppeared, true
+		case topology.Removed:
+			return DeviceDisappeared, true
+		}
+	case topology.TargetObject:
+		// targets also show up when they have sessions, regardless of discovery
+		target := snapshot.Target(change.Key)
+		if target != nil && target.Discovered && change.Kind != topology.Removed {
+			if change.Kind == topology.Added {
+				return TargetDiscovered, true
+			}
+			for _, field := range change.Fields {
+				if field.Field == "Discovered" {
+					return TargetDiscovered, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,323 @@
+package watch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iscsidsc "github.com/wk8/go-win-iscsidsc"
+)
+
+// fakeClock only moves forward when told to.
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// receives the duration of each timer armed with `After`
+	armed chan time.Duration
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2019, 5, 12, 10, 0, 0, 0, time.UTC),
+		armed: make(chan time.Duration, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	timer := &fakeTimer{deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	c.mutex.Unlock()
+
+	c.armed <- d
+	return timer.c
+}
+
+// Advance moves the clock forward, and fires the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	remaining := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			remaining = append(remaining, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+	c.timers = remaining
+}
+
+// waitForTimer waits for the watcher to be done polling, and to wait for the next poll.
+func (c *fakeClock) waitForTimer(t *testing.T) time.Duration {
+	select {
+	case d := <-c.armed:
+		return d
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for a timer to be armed")
+		return 0
+	}
+}
+
+// state is what the scripted backend returns for one poll.
+type state struct {
+	targets  []string
+	sessions []iscsidsc.SessionInfo
+	devices  map[iscsidsc.SessionID][]iscsidsc.Device
+	err      error
+}
+
+// scriptedBackend returns a new state each time it's polled; polls start with listing target portals.
+type scriptedBackend struct {
+	iscsidsc.Backend
+	states  []state
+	current state
+}
+
+func (b *scriptedBackend) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	if len(b.states) == 0 {
+		return nil, errors.New("no more states")
+	}
+	b.current, b.states = b.states[0], b.states[1:]
+	return nil, b.current.err
+}
+
+func (b *scriptedBackend) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	return b.current.targets, nil
+}
+
+func (b *scriptedBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return b.current.sessions, nil
+}
+
+func (b *scriptedBackend) GetDevicesForIScsiSession(id iscsidsc.SessionID) ([]iscsidsc.Device, error) {
+	return b.current.devices[id], nil
+}
+
+const (
+	target1   = "iqn.1991-05.com.microsoft:target-1"
+	target2   = "iqn.1991-05.com.microsoft:target-2"
+	initiator = `ROOT\ISCSIPRT\0000_0`
+)
+
+var (
+	session1 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000001}
+	session2 = iscsidsc.SessionID{AdapterUnique: 0xffffe0008ff4b010, AdapterSpecific: 0x4000013700000002}
+)
+
+func sessionInfo(id iscsidsc.SessionID, targetName string, addresses ...string) iscsidsc.SessionInfo {
+	info := iscsidsc.SessionInfo{SessionID: id, TargetNodeName: targetName, InitiatorName: initiator}
+	for _, address := range addresses {
+		info.Connections = append(info.Connections, iscsidsc.ConnectionInfo{TargetAddress: address, TargetSocket: 3260})
+	}
+	return info
+}
+
+func disk(lun uint8) iscsidsc.Device {
+	return iscsidsc.Device{
+		ScsiAddress:         iscsidsc.ScsiAddress{Lun: lun},
+		DeviceInterfaceType: uuid.MustParse("53f56307-b6bf-11d0-94f2-00a0c91efb8b"),
+		StorageDeviceNumber: iscsidsc.StorageDeviceNumber{DeviceType: iscsidsc.DeviceTypeDisk, DeviceNumber: uint32(lun) + 1},
+	}
+}
+
+func TestWatch(t *testing.T) {
+	pollErr := errors.New("dummy error")
+	backend := &scriptedBackend{states: []state{
+		// the baseline
+		{
+			targets:  []string{target1},
+			sessions: []iscsidsc.SessionInfo{sessionInfo(session1, target1, "10.0.0.5")},
+			devices:  map[iscsidsc.SessionID][]iscsidsc.Device{session1: {disk(0)}},
+		},
+		{err: pollErr},
+		// a new connection and a new LUN on session 1, a new target, and a new session to it
+		{
+			targets: []string{target1, target2},
+			sessions: []iscsidsc.SessionInfo{
+				sessionInfo(session1, target1, "10.0.0.5", "10.0.0.6"),
+				sessionInfo(session2, target2, "10.0.0.5"),
+			},
+			devices: map[iscsidsc.SessionID][]iscsidsc.Device{session1: {disk(0), disk(1)}},
+		},
+		// a path failure on session 1, and session 2 is gone
+		{
+			targets:  []string{target1, target2},
+			sessions: []iscsidsc.SessionInfo{sessionInfo(session1, target1, "10.0.0.5")},
+			devices:  map[iscsidsc.SessionID][]iscsidsc.Device{session1: {disk(1)}},
+		},
+	}}
+
+	clock := newFakeClock()
+	start := clock.Now()
+	var errs []error
+	watcher := &Watcher{
+		Interval: 10 * time.Second,
+		Jitter:   0.1,
+		Rand:     func() float64 { return 1 },
+		Fetcher:  iscsidsc.NewClient(iscsidsc.WithBackend(backend)),
+		Clock:    clock,
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := watcher.Watch(ctx)
+
+	// baseline
+	interval := clock.waitForTimer(t)
+	assert.Equal(t, 11*time.Second, interval)
+
+	// failed poll
+	clock.Advance(interval)
+	clock.waitForTimer(t)
+	if assert.Equal(t, 1, len(errs)) {
+		assert.Equal(t, pollErr, errors.Cause(errs[0]))
+	}
+
+	const session1Key = target1 + " (" + initiator + ")"
+	const session2Key = target2 + " (" + initiator + ")"
+
+	clock.Advance(interval)
+	pollTime := start.Add(2 * interval)
+	assertEvents(t, events, []Event{
+		{Type: TargetDiscovered, Key: target2, Time: pollTime},
+		{Type: ConnectionAdded, Key: session1Key + " via 10.0.0.6:3260", Time: pollTime},
+		{Type: DeviceAppeared, Key: session1Key + " LUN 1 (GUID_DEVINTERFACE_DISK)", Time: pollTime},
+		{Type: SessionAdded, Key: session2Key, Time: pollTime},
+		{Type: ConnectionAdded, Key: session2Key + " via 10.0.0.5:3260", Time: pollTime},
+	})
+	clock.waitForTimer(t)
+
+	clock.Advance(interval)
+	pollTime = start.Add(3 * interval)
+	assertEvents(t, events, []Event{
+		{Type: ConnectionRemoved, Key: session1Key + " via 10.0.0.6:3260", Time: pollTime},
+		{Type: DeviceDisappeared, Key: session1Key + " LUN 0 (GUID_DEVINTERFACE_DISK)", Time: pollTime},
+		{Type: SessionRemoved, Key: session2Key, Time: pollTime},
+		{Type: ConnectionRemoved, Key: session2Key + " via 10.0.0.5:3260", Time: pollTime},
+	})
+	clock.waitForTimer(t)
+
+	cancel()
+	assertClosed(t, events)
+}
+
+func TestWatchShutdownWhilePublishing(t *testing.T) {
+	backend := &scriptedBackend{states: []state{
+		{},
+		{targets: []string{target1, target2}},
+	}}
+	clock := newFakeClock()
+	watcher := &Watcher{
+		Fetcher: iscsidsc.NewClient(iscsidsc.WithBackend(backend)),
+		Clock:   clock,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := watcher.Watch(ctx)
+
+	interval := clock.waitForTimer(t)
+	assert.Equal(t, DefaultInterval, interval)
+	clock.Advance(interval)
+
+	// let's only read one of the 2 events
+	assertEvents(t, events, []Event{{Type: TargetDiscovered, Key: target1, Time: clock.Now()}})
+	cancel()
+	assertClosed(t, events)
+}
+
+// hangingBackend hangs when listing targets, until released.
+type hangingBackend struct {
+	iscsidsc.Backend
+	listing chan struct{}
+	release chan struct{}
+}
+
+func (b *hangingBackend) ReportIScsiSendTargetPortals() ([]iscsidsc.PortalInfo, error) {
+	return nil, nil
+}
+
+func (b *hangingBackend) ReportIScsiTargets(forceUpdate bool) ([]string, error) {
+	close(b.listing)
+	<-b.release
+	return nil, nil
+}
+
+func (b *hangingBackend) GetIScsiSessionList() ([]iscsidsc.SessionInfo, error) {
+	return nil, nil
+}
+
+func TestWatchShutdownWhilePolling(t *testing.T) {
+	backend := &hangingBackend{listing: make(chan struct{}), release: make(chan struct{})}
+	defer close(backend.release)
+	watcher := &Watcher{
+		Fetcher: iscsidsc.NewClient(iscsidsc.WithBackend(backend)),
+		Clock:   newFakeClock(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := watcher.Watch(ctx)
+
+	select {
+	case <-backend.listing:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for the poll to start")
+	}
+	cancel()
+	assertClosed(t, events)
+}
+
+func TestEventTypeString(t *testing.T) {
+	assert.Equal(t, "SessionAdded", SessionAdded.String())
+	assert.Equal(t, "TargetDiscovered", TargetDiscovered.String())
+	assert.Equal(t, "EventType(12)", EventType(12).String())
+}
+
+func assertEvents(t *testing.T, events <-chan Event, expected []Event) {
+	for _, expectedEvent := range expected {
+		select {
+		case event := <-events:
+			require.NotNil(t, event.Snapshot)
+			require.NotNil(t, event.PreviousSnapshot)
+			event.Snapshot, event.PreviousSnapshot = nil, nil
+			assert.Equal(t, expectedEvent, event)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for event", "%v", expectedEvent)
+		}
+	}
+}
+
+func assertClosed(t *testing.T, events <-chan Event) {
+	for {
+		select {
+		case _, open := <-events:
+			if !open {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for the events channel to be closed")
+		}
+	}
+}
@@ -22,7 +22,14 @@ func NewWinAPICallError(procName string, exitCode uintptr) *WinAPICallError {
 func (err *WinAPICallError) Error() string {
 	return fmt.Sprintf("exit code when calling %q: %s - please see Windows' documentation at https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-erref/18d8fbe8-a967-4f1c-ae50-99ca8e491d2d or https://docs.microsoft.com/en-us/windows-hardware/drivers/storage/iscsi-status-qualifiers and/or check your system logs in Windows' event viewer",
 		err.procName,
-		err.HexCode())
+		err.Code().Error())
+}
+
+// Is allows using `errors.Is` to check whether the error has a given exit code,
+// e.g. `errors.Is(err, iscsidsc.ErrTargetAlreadyLoggedIn)`.
+func (err *WinAPICallError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && err.Code() == code
 }
 
 // ProcName returns the name of the proc that failed.
@@ -35,6 +42,18 @@ func (err *WinAPICallError) ExitCode() uintptr {
 	return err.exitCode
 }
 
+// Code returns the exit code as an `ErrorCode`, which can be used to look up
+// its symbolic name and description.
+func (err *WinAPICallError) Code() ErrorCode {
+	return ErrorCode(err.exitCode)
+}
+
+// LoginStatus returns the login status the target replied with, if the call failed because
+// the target rejected a login; and false otherwise.
+func (err *WinAPICallError) LoginStatus() (LoginStatus, bool) {
+	return err.Code().LoginStatus()
+}
+
 // HexCode formats a Windows exit status into the hexadecimal representation one
 // can find in Windows' documentation.
 // see https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-erref/18d8fbe8-a967-4f1c-ae50-99ca8e491d2d
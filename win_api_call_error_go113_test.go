@@ -0,0 +1,18 @@
+//go:build go1.13
+// +build go1.13
+
+package iscsidsc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWinAPICallErrorWithErrorsIs(t *testing.T) {
+	var err error = NewWinAPICallError("LoginIScsiTargetW", 0xEFFF0009)
+
+	assert.True(t, errors.Is(err, ErrLoginAuthFailed))
+	assert.False(t, errors.Is(err, ErrTargetNotFound))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,3 +21,84 @@ func TestWinAPICallErrorHexStrings(t *testing.T) {
 		})
 	}
 }
+
+func TestWinAPICallErrorMessage(t *testing.T) {
+	t.Run("with a known exit code, it includes its symbolic name and description", func(t *testing.T) {
+		err := NewWinAPICallError("LoginIScsiTargetW", 0xEFFF003F)
+
+		assert.Contains(t, err.Error(), `exit code when calling "LoginIScsiTargetW": 0xEFFF003F (ISDSC_TARGET_ALREADY_LOGGED_IN): The target has already been logged in via an iSCSI session. - please see`)
+	})
+
+	t.Run("with an unknown exit code, it only includes the hex code", func(t *testing.T) {
+		err := NewWinAPICallError("LoginIScsiTargetW", 0xEFFFFFFF)
+
+		assert.Contains(t, err.Error(), `exit code when calling "LoginIScsiTargetW": 0xEFFFFFFF - please see`)
+	})
+}
+
+func TestWinAPICallErrorIs(t *testing.T) {
+	err := NewWinAPICallError("GetIScsiSessionListW", 0xEFFF001C)
+
+	assert.True(t, err.Is(ErrSessionNotFound))
+	assert.False(t, err.Is(ErrTargetNotFound))
+	assert.False(t, err.Is(ErrNotSupported))
+	assert.Equal(t, ErrSessionNotFound, err.Code())
+}
+
+func TestHasErrorCode(t *testing.T) {
+	err := NewWinAPICallError("GetIScsiSessionListW", 0xEFFF001C)
+	wrapped := errors.Wrap(err, "unable to list sessions")
+
+	code, ok := ErrorCodeOf(wrapped)
+	assert.True(t, ok)
+	assert.Equal(t, ErrSessionNotFound, code)
+
+	assert.True(t, HasErrorCode(err, ErrSessionNotFound))
+	assert.True(t, HasErrorCode(wrapped, ErrTargetNotFound, ErrSessionNotFound))
+	assert.False(t, HasErrorCode(wrapped, ErrTargetNotFound))
+	assert.False(t, HasErrorCode(wrapped))
+
+	_, ok = ErrorCodeOf(errors.New("dummy"))
+	assert.False(t, ok)
+	assert.False(t, HasErrorCode(nil, ErrSessionNotFound))
+	assert.False(t, HasErrorCode(ErrSessionNotFound, ErrSessionNotFound))
+}
+
+func TestIsAlreadyLoggedIn(t *testing.T) {
+	assert.True(t, IsAlreadyLoggedIn(NewWinAPICallError("LoginIScsiTargetW", uintptr(ErrTargetAlreadyLoggedIn))))
+	assert.True(t, IsAlreadyLoggedIn(errors.Wrap(NewWinAPICallError("LoginIScsiTargetW", uintptr(ErrSessionAlreadyExists)), "wrapped")))
+	assert.False(t, IsAlreadyLoggedIn(NewWinAPICallError("LoginIScsiTargetW", uintptr(ErrLoginAuthFailed))))
+	assert.False(t, IsAlreadyLoggedIn(nil))
+}
+
+func TestErrorCodes(t *testing.T) {
+	t.Run("known codes", func(t *testing.T) {
+		assert.True(t, ErrTargetAlreadyExists.Known())
+		assert.Equal(t, "ISDSC_TARGET_ALREADY_EXISTS", ErrTargetAlreadyExists.Name())
+		assert.Equal(t, "Target already exists.", ErrTargetAlreadyExists.Description())
+		assert.Equal(t, "0xEFFF0018 (ISDSC_TARGET_ALREADY_EXISTS)", ErrTargetAlreadyExists.String())
+		assert.Equal(t, "0xEFFF0018 (ISDSC_TARGET_ALREADY_EXISTS): Target already exists.", ErrTargetAlreadyExists.Error())
+
+		assert.Equal(t, "ERROR_INSUFFICIENT_BUFFER", ErrInsufficientBuffer.Name())
+		assert.Equal(t, "0x0000007A", ErrInsufficientBuffer.HexCode())
+	})
+
+	t.Run("unknown codes", func(t *testing.T) {
+		code := ErrorCode(0xEFFFFFFF)
+
+		assert.False(t, code.Known())
+		assert.Equal(t, "", code.Name())
+		assert.Equal(t, "", code.Description())
+		assert.Equal(t, "0xEFFFFFFF", code.Error())
+	})
+
+	t.Run("all codes in the catalog have a name and a description", func(t *testing.T) {
+		names := make(map[string]bool)
+		for code, info := range errorCodes {
+			assert.NotEqual(t, "", info.name, "code %s has no name", code.HexCode())
+			assert.NotEqual(t, "", info.description, "code %s has no description", code.HexCode())
+			assert.False(t, names[info.name], "duplicate name %s", info.name)
+			names[info.name] = true
+		}
+	})
+}